@@ -1,15 +1,25 @@
 package main
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"context"
+	"crypto/rand"
 	"fmt"
 	"log"
+	"maps"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
+	"simulacrum/internal/audit"
 	"simulacrum/internal/auth"
 	"simulacrum/internal/config"
+	"simulacrum/internal/data"
 	"simulacrum/internal/handlers"
+	"simulacrum/internal/rules"
 
 	"github.com/gin-gonic/gin"
 )
@@ -24,16 +34,128 @@ func main() {
 	// Set Gin mode
 	gin.SetMode(cfg.Server.GinMode)
 
-	// Load public keys for JWT validation
-	pkm, err := auth.LoadPublicKeysFromFile(cfg.Auth.PublicKeysFile)
+	// Load the secret that keys every deterministic generator
+	hashKey, err := loadHashKey(cfg)
+	if err != nil {
+		log.Fatalf("Failed to load hash key: %v", err)
+	}
+	if err := data.SetHashKey(hashKey); err != nil {
+		log.Fatalf("Invalid hash key: %v", err)
+	}
+
+	// Load public keys for JWT validation; they can be reloaded without a restart
+	keys, err := loadKeyStore(cfg)
 	if err != nil {
 		log.Fatalf("Failed to load public keys: %v", err)
 	}
+	keys.Policy = auth.TokenPolicy{
+		Issuer:         cfg.Auth.Issuer,
+		Audience:       cfg.Auth.Audience,
+		Leeway:         cfg.Auth.Leeway,
+		RequiredClaims: cfg.Auth.RequiredClaims,
+		MaxLifetime:    cfg.Auth.MaxTokenLifetime,
+	}
+	var watcher auth.FileWatcher
+	switch {
+	case cfg.Auth.JWKS == "":
+		watcher.Watch("public keys", keys.Reload, cfg.Auth.PublicKeysFile)
+	case auth.IsJWKSURL(cfg.Auth.JWKS):
+		// Reloaded on SIGHUP and refreshed on a timer
+		watcher.Watch("JWKS", keys.Reload)
+		go keys.RefreshEvery(context.Background(), "JWKS", cfg.Auth.JWKSRefreshInterval)
+	default:
+		watcher.Watch("JWKS", keys.Reload, cfg.Auth.JWKS)
+	}
+
+	// The audit log runs first so rejected requests are recorded too
+	var apiMiddleware []gin.HandlerFunc
+	if cfg.Server.AuditLog != "off" {
+		auditLog, err := openAuditLog(cfg.Server.AuditLog)
+		if err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		apiMiddleware = append(apiMiddleware, audit.Middleware(auditLog))
+	}
+
+	// Revoked tokens are rejected after their signature is checked
+	apiMiddleware = append(apiMiddleware, auth.ClientCertMiddleware(cfg.TLS.AllowedClients), auth.JWTMiddleware(keys))
+	if cfg.Auth.RevocationFile != "" {
+		revoked, err := auth.NewRevocationList(cfg.Auth.RevocationFile)
+		if err != nil {
+			log.Fatalf("Failed to load revocation list: %v", err)
+		}
+		watcher.Watch("revocation list", revoked.Reload, cfg.Auth.RevocationFile)
+		apiMiddleware = append(apiMiddleware, auth.RevocationMiddleware(revoked))
+		fmt.Printf("Using revocation list from: %s (%d entries)\n", cfg.Auth.RevocationFile, revoked.Len())
+	}
+
+	// Dictionaries replace built-in word lists and are referenced by rules,
+	// so they are loaded first
+	if len(cfg.Obscure.Dictionaries) > 0 {
+		if err := data.LoadDictionaries(cfg.Obscure.Dictionaries); err != nil {
+			log.Fatalf("Failed to load dictionaries: %v", err)
+		}
+	}
+
+	// Load field rules, falling back to the built-in table
+	ruleSet := rules.Default()
+	if cfg.Obscure.RulesFile != "" {
+		ruleSet, err = rules.LoadFromFile(cfg.Obscure.RulesFile)
+		if err != nil {
+			log.Fatalf("Failed to load rules: %v", err)
+		}
+	}
+	if err := handlers.CheckDictionaries(ruleSet); err != nil {
+		log.Fatalf("Failed to load rules: %v", err)
+	}
+
+	obscurer := handlers.NewObscurer(ruleSet, cfg.Obscure.IDKeys)
+	obscurer.Persona = cfg.Obscure.Persona
+	if err := obscurer.SetLocale(cfg.Obscure.Locale); err != nil {
+		log.Fatalf("Invalid OBSCURE_LOCALE: %v", err)
+	}
+
+	// With profiles, each request uses the rules its token's scopes grant
+	var profiles *handlers.Profiles
+	if len(cfg.Obscure.Profiles) > 0 {
+		profiles, err = loadProfiles(cfg)
+		if err != nil {
+			log.Fatalf("Failed to load profiles: %v", err)
+		}
+	}
+
+	// With tenant keys, each tenant gets fakes unlinkable to other tenants'
+	var tenants *handlers.Tenants
+	if cfg.Obscure.TenantKeysFile != "" {
+		tenantKeys, err := data.LoadTenantKeysFromFile(cfg.Obscure.TenantKeysFile)
+		if err != nil {
+			log.Fatalf("Failed to load tenant keys: %v", err)
+		}
+		tenants = handlers.NewTenants(tenantKeys, cfg.Obscure.TenantClaim)
+	}
+
+	handle := func(h handlers.ObscurerHandler) gin.HandlerFunc {
+		h = handlers.CountStats(h)
+		if tenants != nil {
+			h = tenants.Handle(h)
+		}
+		if profiles != nil {
+			return profiles.Handle(h)
+		}
+		return func(c *gin.Context) { h(obscurer, c) }
+	}
+
+	if len(cfg.TLS.AllowedClients) > 0 && !(cfg.TLS.Enabled && cfg.TLS.RequireClientCert) {
+		log.Fatal("TLS_ALLOWED_CLIENTS requires TLS_ENABLED and TLS_REQUIRE_CLIENT_CERT")
+	}
 
 	r := gin.Default()
 
-	// Apply JWT middleware to /obscure endpoint
-	r.POST("/obscure", auth.JWTMiddleware(pkm), handlers.HandleObscure)
+	// Apply auditing, client certificate and JWT checks to the /obscure endpoints
+	api := r.Group("/obscure", apiMiddleware...)
+	api.POST("", handle((*handlers.Obscurer).HandleObscure))
+	api.POST("/ndjson", handle((*handlers.Obscurer).HandleObscureNDJSON))
+	api.POST("/csv", handle((*handlers.Obscurer).HandleObscureCSV))
 
 	// Health check endpoint (no auth required)
 	r.GET("/health", func(c *gin.Context) {
@@ -41,58 +163,160 @@ func main() {
 	})
 
 	fmt.Printf("Server starting on :%s (%s)...\n", cfg.Server.Port, cfg.Server.Environment)
-	fmt.Printf("Using public keys from: %s\n", cfg.Auth.PublicKeysFile)
+	if cfg.Auth.JWKS != "" {
+		fmt.Printf("Using JWKS from: %s\n", cfg.Auth.JWKS)
+	} else {
+		fmt.Printf("Using public keys from: %s\n", cfg.Auth.PublicKeysFile)
+	}
+	if cfg.Auth.Issuer != "" || len(cfg.Auth.Audience) > 0 {
+		fmt.Printf("Expecting token issuer %q and audience %q\n", cfg.Auth.Issuer, cfg.Auth.Audience)
+	}
+	if tenants != nil {
+		fmt.Printf("Using tenant keys from: %s (claim %q)\n", cfg.Obscure.TenantKeysFile, cfg.Obscure.TenantClaim)
+	}
+	if len(cfg.Obscure.Profiles) > 0 {
+		for _, name := range slices.Sorted(maps.Keys(cfg.Obscure.Profiles)) {
+			fmt.Printf("Profile %s (scope %s%s) uses rules from: %s\n", name, handlers.ProfileScopePrefix, name, cfg.Obscure.Profiles[name])
+		}
+	} else if cfg.Obscure.RulesFile != "" {
+		fmt.Printf("Using rules from: %s\n", cfg.Obscure.RulesFile)
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Obscure.Dictionaries)) {
+		fmt.Printf("Dictionary %s uses words from: %s\n", name, cfg.Obscure.Dictionaries[name])
+	}
 	fmt.Println("Endpoints:")
 	fmt.Println("  GET  /health        - Health check (no auth)")
 	fmt.Println("  POST /obscure       - Obscure data (requires JWT)")
+	fmt.Println("  POST /obscure/ndjson - Obscure newline-delimited JSON, streamed (requires JWT)")
+	fmt.Println("  POST /obscure/csv   - Obscure CSV or TSV by header name, streamed (requires JWT)")
 
-	// Setup TLS if enabled
-	if cfg.TLS.Enabled && cfg.TLS.CertFile != "" && cfg.TLS.KeyFile != "" {
-		tlsConfig := &tls.Config{
-			MinVersion: parseTLSVersion(cfg.TLS.MinVersion),
-		}
+	srv := &http.Server{
+		Addr:              ":" + cfg.Server.Port,
+		Handler:           r,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
 
-		// Add client certificate verification if required
-		if cfg.TLS.RequireClientCert && cfg.TLS.CACertFile != "" {
-			caCertPEM, err := os.ReadFile(cfg.TLS.CACertFile)
-			if err != nil {
-				log.Fatalf("Failed to read CA certificate file: %v", err)
-			}
+	if cfg.TLS.Enabled {
+		if cfg.TLS.CertFile == "" || cfg.TLS.KeyFile == "" {
+			log.Fatal("TLS_CERT_FILE and TLS_KEY_FILE are required when TLS is enabled")
+		}
+		var certs *auth.CertStore
+		srv.TLSConfig, certs, err = auth.NewServerTLSConfig(auth.ServerTLSOptions{
+			CertFile:          cfg.TLS.CertFile,
+			KeyFile:           cfg.TLS.KeyFile,
+			CACertFile:        cfg.TLS.CACertFile,
+			RequireClientCert: cfg.TLS.RequireClientCert,
+			MinVersion:        cfg.TLS.MinVersion,
+		})
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		watcher.Watch("server certificate", certs.Reload, certs.Files()...)
+	}
 
-			caCertPool := x509.NewCertPool()
-			if !caCertPool.AppendCertsFromPEM(caCertPEM) {
-				log.Fatal("Failed to parse CA certificate")
-			}
+	watchForReloads(&watcher, cfg.Server.ReloadInterval)
 
-			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
-			tlsConfig.ClientCAs = caCertPool
-			fmt.Printf("mTLS enabled - client certificates required\n")
-		} else {
-			fmt.Printf("HTTPS enabled (without client verification)\n")
+	if !cfg.TLS.Enabled {
+		if err := srv.ListenAndServe(); err != nil {
+			log.Fatal(err)
 		}
+		return
+	}
 
-		if err := r.RunTLS(":"+cfg.Server.Port, cfg.TLS.CertFile, cfg.TLS.KeyFile); err != nil {
-			log.Fatal(err)
+	if cfg.TLS.RequireClientCert {
+		fmt.Printf("mTLS enabled - client certificates required (TLS %s+)\n", cfg.TLS.MinVersion)
+		if len(cfg.TLS.AllowedClients) > 0 {
+			fmt.Printf("Allowed clients: %s\n", strings.Join(cfg.TLS.AllowedClients, ", "))
 		}
 	} else {
-		if err := r.Run(":" + cfg.Server.Port); err != nil {
-			log.Fatal(err)
+		fmt.Printf("HTTPS enabled without client verification (TLS %s+)\n", cfg.TLS.MinVersion)
+	}
+
+	// The certificate is served by TLSConfig.GetCertificate
+	if err := srv.ListenAndServeTLS("", ""); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// loadKeyStore loads the JWT verification keys from the JWKS source if one
+// is configured, otherwise from the PEM public keys file
+func loadKeyStore(cfg *config.Config) (*auth.KeyStore, error) {
+	if cfg.Auth.JWKS != "" {
+		if cfg.Auth.JWKSAllowInsecure && strings.HasPrefix(cfg.Auth.JWKS, "http://") {
+			fmt.Println("WARNING: fetching JWKS over plain http, anyone on the network path can inject signing keys")
 		}
+		return auth.NewJWKSKeyStore(cfg.Auth.JWKS, nil, cfg.Auth.JWKSAllowInsecure)
 	}
+	return auth.NewFileKeyStore(cfg.Auth.PublicKeysFile)
 }
 
-// parseTLSVersion converts string version to tls.Version constant
-func parseTLSVersion(version string) uint16 {
-	switch version {
-	case "1.0":
-		return tls.VersionTLS10
-	case "1.1":
-		return tls.VersionTLS11
-	case "1.2":
-		return tls.VersionTLS12
-	case "1.3":
-		return tls.VersionTLS13
-	default:
-		return tls.VersionTLS12
+// loadProfiles loads the rules file of every configured profile
+func loadProfiles(cfg *config.Config) (*handlers.Profiles, error) {
+	byName := make(map[string]*handlers.Obscurer, len(cfg.Obscure.Profiles))
+	for name, file := range cfg.Obscure.Profiles {
+		ruleSet, err := rules.LoadFromFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		if err := handlers.CheckDictionaries(ruleSet); err != nil {
+			return nil, fmt.Errorf("profile %s: %w", name, err)
+		}
+		byName[name] = handlers.NewObscurer(ruleSet, cfg.Obscure.IDKeys)
+		byName[name].Persona = cfg.Obscure.Persona
+		if err := byName[name].SetLocale(cfg.Obscure.Locale); err != nil {
+			return nil, err
+		}
+	}
+	return handlers.NewProfiles(byName, cfg.Obscure.DefaultProfile)
+}
+
+// openAuditLog returns a logger writing to stdout or appending to a file
+func openAuditLog(dest string) (*audit.Logger, error) {
+	if dest == "stdout" {
+		return audit.NewLogger(os.Stdout), nil
+	}
+	f, err := os.OpenFile(dest, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	return audit.NewLogger(f), nil
+}
+
+// watchForReloads reloads keys and certificates on SIGHUP and, if interval is
+// set, whenever their files change
+func watchForReloads(watcher *auth.FileWatcher, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			watcher.ReloadAll()
+		}
+	}()
+
+	if interval > 0 {
+		go watcher.Run(context.Background(), interval)
+		fmt.Printf("Watching keys and certificates for changes every %s\n", interval)
+	}
+}
+
+// loadHashKey resolves the hashing secret from the configured file or value.
+// Outside production a random per-process key is generated when none is set,
+// which keeps output unlinkable but not stable across restarts.
+func loadHashKey(cfg *config.Config) ([]byte, error) {
+	if cfg.Obscure.SecretKeyFile != "" {
+		return data.LoadHashKeyFromFile(cfg.Obscure.SecretKeyFile)
+	}
+	if cfg.Obscure.SecretKey != "" {
+		return data.ParseHashKey([]byte(cfg.Obscure.SecretKey))
+	}
+	if cfg.Server.Environment == "production" {
+		return nil, fmt.Errorf("OBSCURE_SECRET_KEY or OBSCURE_SECRET_KEY_FILE must be set in production")
+	}
+
+	fmt.Println("WARNING: no hash key configured, using a random key for this process")
+	key := make([]byte, data.MinHashKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
 	}
+	return key, nil
 }
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"simulacrum/internal/config"
+)
+
+// runCerts implements "simulacrum certs"
+func runCerts(args []string) error {
+	flags := flag.NewFlagSet("certs", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: simulacrum certs [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "Creates a local CA with a server and a client certificate for mTLS:\n")
+		fmt.Fprintf(flags.Output(), "ca.crt, ca.key, server.crt, server.key, client.crt and client.key.\n\n")
+		flags.PrintDefaults()
+	}
+	dir := flags.String("dir", ".", "directory the files are written to")
+	hosts := flags.String("hosts", "localhost,127.0.0.1,::1", "comma-separated DNS names and IP addresses of the server")
+	client := flags.String("client", "simulacrum-client", "common name of the client certificate")
+	validFor := flags.Duration("valid-for", 365*24*time.Hour, "validity of the certificates")
+	force := flags.Bool("force", false, "overwrite existing files")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *validFor <= 0 {
+		return fmt.Errorf("--valid-for must be positive")
+	}
+	serverHosts := config.SplitList(*hosts)
+	if len(serverHosts) == 0 {
+		return fmt.Errorf("--hosts must name at least one host")
+	}
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		return err
+	}
+	// Check up front so an existing setup isn't half replaced
+	if !*force {
+		for _, name := range []string{"ca", "server", "client"} {
+			for _, ext := range []string{".crt", ".key"} {
+				if _, err := os.Stat(filepath.Join(*dir, name+ext)); err == nil {
+					return fmt.Errorf("%s already exists, use --force to overwrite it", filepath.Join(*dir, name+ext))
+				}
+			}
+		}
+	}
+
+	notBefore := time.Now().Add(-5 * time.Minute)
+	notAfter := notBefore.Add(*validFor)
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	caTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "Simulacrum Local CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+	}
+	caCert, err := issueCert(*dir, "ca", caTemplate, caKey, nil, nil, *force)
+	if err != nil {
+		return err
+	}
+
+	serverTemplate := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: serverHosts[0]},
+		NotBefore:   notBefore,
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, host := range serverHosts {
+		if ip := net.ParseIP(host); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, host)
+		}
+	}
+	if _, err := issueCert(*dir, "server", serverTemplate, nil, caCert, caKey, *force); err != nil {
+		return err
+	}
+
+	clientTemplate := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: *client},
+		NotBefore:   notBefore,
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if _, err := issueCert(*dir, "client", clientTemplate, nil, caCert, caKey, *force); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote ca, server and client certificates and keys to %s\n", *dir)
+	return nil
+}
+
+// issueCert creates name.crt and name.key in dir. The certificate is signed
+// by parent, or self-signed with key when parent is nil; a new key is
+// generated when key is nil.
+func issueCert(dir, name string, template *x509.Certificate, key *ecdsa.PrivateKey, parent *x509.Certificate, parentKey crypto.Signer, force bool) (*x509.Certificate, error) {
+	if key == nil {
+		var err error
+		if key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+			return nil, err
+		}
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber = serial
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s certificate: %w", name, err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	if err := writeNewFile(filepath.Join(dir, name+".key"), keyPEM, 0o600, force); err != nil {
+		return nil, err
+	}
+	if err := writeNewFile(filepath.Join(dir, name+".crt"), certPEM, 0o644, force); err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(der)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"simulacrum/internal/auth"
+)
+
+func TestCerts(t *testing.T) {
+	dir := t.TempDir()
+	if err := runCerts([]string{"--dir", dir, "--client", "billing-service"}); err != nil {
+		t.Fatalf("certs failed: %v", err)
+	}
+
+	// The server loads them the way run_docker_tls.sh configures it
+	file := func(name string) string { return filepath.Join(dir, name) }
+	if _, _, err := auth.NewServerTLSConfig(auth.ServerTLSOptions{
+		CertFile:          file("server.crt"),
+		KeyFile:           file("server.key"),
+		CACertFile:        file("ca.crt"),
+		RequireClientCert: true,
+		MinVersion:        "1.2",
+	}); err != nil {
+		t.Fatalf("Failed to load generated certificates: %v", err)
+	}
+
+	caPEM, _ := os.ReadFile(file("ca.crt"))
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(caPEM)
+
+	tests := []struct {
+		name  string
+		usage x509.ExtKeyUsage
+		host  string
+	}{
+		{"server", x509.ExtKeyUsageServerAuth, "localhost"},
+		{"client", x509.ExtKeyUsageClientAuth, ""},
+	}
+	for _, tt := range tests {
+		pair, err := tls.LoadX509KeyPair(file(tt.name+".crt"), file(tt.name+".key"))
+		if err != nil {
+			t.Fatalf("%s: failed to load key pair: %v", tt.name, err)
+		}
+		cert, _ := x509.ParseCertificate(pair.Certificate[0])
+		if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, DNSName: tt.host, KeyUsages: []x509.ExtKeyUsage{tt.usage}}); err != nil {
+			t.Errorf("%s: certificate doesn't verify against the CA: %v", tt.name, err)
+		}
+		if tt.name == "client" && cert.Subject.CommonName != "billing-service" {
+			t.Errorf("Expected client CN billing-service, got %s", cert.Subject.CommonName)
+		}
+	}
+
+	if info, err := os.Stat(file("server.key")); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected server.key to be private, got %v", info.Mode())
+	}
+	if err := runCerts([]string{"--dir", dir}); err == nil {
+		t.Error("Expected certs to refuse to overwrite existing files")
+	}
+	if err := runCerts([]string{"--dir", dir, "--force"}); err != nil {
+		t.Errorf("Expected --force to overwrite existing files: %v", err)
+	}
+}
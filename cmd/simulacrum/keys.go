@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// runKeys implements "simulacrum keys"
+func runKeys(args []string) error {
+	flags := flag.NewFlagSet("keys", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: simulacrum keys [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "Generates a JWT signing key pair and appends the public key to the server's public keys file.\n\n")
+		flags.PrintDefaults()
+	}
+	keyType := flags.String("type", "rsa", "key type, rsa, ec or ed25519")
+	bits := flags.Int("bits", 2048, "RSA key size in bits")
+	curve := flags.String("curve", "P-256", "EC curve, P-256 or P-384")
+	privateFile := flags.String("private", "test_private.key", "file the private key is written to")
+	publicFile := flags.String("public", "public_keys.pem", "public keys file the public key is appended to")
+	force := flags.Bool("force", false, "overwrite an existing private key file")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	key, err := generateKey(*keyType, *bits, *curve)
+	if err != nil {
+		return err
+	}
+
+	privateDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	publicDER, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return err
+	}
+
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER})
+	if err := writeNewFile(*privateFile, privatePEM, 0o600, *force); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(*publicFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %s private key to %s\n", keyDescription(*keyType, *bits, *curve), *privateFile)
+	fmt.Printf("Appended public key to %s\n", *publicFile)
+	return nil
+}
+
+// generateKey creates a signing key of the given type
+func generateKey(keyType string, bits int, curve string) (crypto.Signer, error) {
+	switch keyType {
+	case "rsa":
+		if bits < 2048 {
+			return nil, fmt.Errorf("--bits must be at least 2048")
+		}
+		return rsa.GenerateKey(rand.Reader, bits)
+	case "ec":
+		switch curve {
+		case "P-256":
+			return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		case "P-384":
+			return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+		default:
+			return nil, fmt.Errorf("unknown curve %q, expected P-256 or P-384", curve)
+		}
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	default:
+		return nil, fmt.Errorf("unknown key type %q, expected rsa, ec or ed25519", keyType)
+	}
+}
+
+func keyDescription(keyType string, bits int, curve string) string {
+	switch keyType {
+	case "rsa":
+		return fmt.Sprintf("RSA %d", bits)
+	case "ec":
+		return "EC " + curve
+	default:
+		return "Ed25519"
+	}
+}
+
+// loadPrivateKey reads a PKCS#8, PKCS#1 or SEC 1 PEM private key
+func loadPrivateKey(file string) (crypto.Signer, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	for block, rest := pem.Decode(raw); block != nil; block, rest = pem.Decode(rest) {
+		var key any
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse private key in %s: %w", file, err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T in %s", key, file)
+		}
+		return signer, nil
+	}
+	return nil, fmt.Errorf("no private key found in %s", file)
+}
+
+// writeNewFile writes data to a file, refusing to replace an existing one
+// unless force is set
+func writeNewFile(name string, data []byte, perm os.FileMode, force bool) error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(name, flags, perm)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, use --force to overwrite it", name)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simulacrum/internal/auth"
+)
+
+func TestKeysAndToken(t *testing.T) {
+	dir := t.TempDir()
+	publicFile := filepath.Join(dir, "public_keys.pem")
+
+	tests := []struct {
+		args []string
+		alg  string
+	}{
+		{[]string{"--type", "rsa"}, "RS256"},
+		{[]string{"--type", "ec", "--curve", "P-384"}, "ES384"},
+		{[]string{"--type", "ed25519"}, "EdDSA"},
+	}
+	for i, tt := range tests {
+		privateFile := filepath.Join(dir, tt.alg+".key")
+		args := append(tt.args, "--private", privateFile, "--public", publicFile)
+		if err := runKeys(args); err != nil {
+			t.Fatalf("%s: keys failed: %v", tt.alg, err)
+		}
+
+		// Public keys accumulate in the same file
+		pkm, err := auth.LoadPublicKeysFromFile(publicFile)
+		if err != nil {
+			t.Fatalf("%s: failed to load public keys: %v", tt.alg, err)
+		}
+		if len(pkm.Keys) != i+1 {
+			t.Errorf("%s: expected %d public keys, got %d", tt.alg, i+1, len(pkm.Keys))
+		}
+
+		key, err := loadPrivateKey(privateFile)
+		if err != nil {
+			t.Fatalf("%s: failed to load private key: %v", tt.alg, err)
+		}
+		method, err := signingMethod(key, "")
+		if err != nil || method.Alg() != tt.alg {
+			t.Errorf("%s: expected default algorithm %s, got %v (%v)", tt.alg, tt.alg, method, err)
+		}
+	}
+
+	if err := runKeys([]string{"--private", filepath.Join(dir, "RS256.key"), "--public", publicFile}); err == nil {
+		t.Error("Expected keys to refuse to overwrite a private key")
+	}
+	if err := runKeys([]string{"--type", "dsa", "--private", filepath.Join(dir, "dsa.key")}); err == nil {
+		t.Error("Expected error for an unknown key type")
+	}
+}
+
+func TestTokenValidates(t *testing.T) {
+	dir := t.TempDir()
+	privateFile := filepath.Join(dir, "test_private.key")
+	publicFile := filepath.Join(dir, "public_keys.pem")
+	if err := runKeys([]string{"--type", "ec", "--private", privateFile, "--public", publicFile}); err != nil {
+		t.Fatalf("keys failed: %v", err)
+	}
+
+	token := captureStdout(t, func() error {
+		return runToken([]string{
+			"--key", privateFile,
+			"--iss", "https://idp.example.com/",
+			"--aud", "simulacrum",
+			"--scope", "obscure:qa",
+			"--claim", "tenant=team-a",
+			"--claim", `roles=["obscure:export"]`,
+		})
+	})
+
+	pkm, err := auth.LoadPublicKeysFromFile(publicFile)
+	if err != nil {
+		t.Fatalf("Failed to load public keys: %v", err)
+	}
+	claims, err := pkm.ValidateTokenWithPolicy(strings.TrimSpace(token), auth.TokenPolicy{
+		Issuer:         "https://idp.example.com/",
+		Audience:       []string{"simulacrum"},
+		RequiredClaims: []string{"sub", "jti"},
+	})
+	if err != nil {
+		t.Fatalf("Expected minted token to validate: %v", err)
+	}
+	if claims["tenant"] != "team-a" || claims["scope"] != "obscure:qa" {
+		t.Errorf("Expected tenant and scope claims, got %v", claims)
+	}
+	if roles := auth.ClaimScopes(claims); len(roles) != 2 || roles[1] != "obscure:export" {
+		t.Errorf("Expected JSON claim values to be decoded, got %v", roles)
+	}
+
+	if err := runToken([]string{"--key", privateFile, "--alg", "HS256"}); err == nil {
+		t.Error("Expected error for an HMAC algorithm")
+	}
+}
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	err = fn()
+	os.Stdout = stdout
+	w.Close()
+	if err != nil {
+		t.Fatalf("Command failed: %v", err)
+	}
+
+	out, _ := io.ReadAll(r)
+	return string(out)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `Usage: simulacrum <command> [flags]
+
+Commands:
+  obscure    Obscure JSON, NDJSON, CSV and TSV files, directories or stdin
+  keys       Generate a JWT signing key pair (RSA, EC or Ed25519)
+  token      Mint a signed test token
+  certs      Create a local CA with server and client certificates for mTLS
+
+Run "simulacrum <command> -h" for the flags of a command.
+`
+
+// errUsage reports invalid command-line flags
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "obscure":
+		err = runObscure(args)
+	case "keys":
+		err = runKeys(args)
+	case "token":
+		err = runToken(args)
+	case "certs":
+		err = runCerts(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "simulacrum: unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	// The flag package has already reported usage problems
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if errors.Is(err, errUsage) {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "simulacrum: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"simulacrum/internal/config"
+	"simulacrum/internal/data"
+	"simulacrum/internal/handlers"
+	"simulacrum/internal/rules"
+)
+
+// fileFormat is an input format understood by the obscure command
+type fileFormat struct {
+	name  string
+	comma byte // field delimiter of csv and tsv
+}
+
+var (
+	formatJSON   = fileFormat{name: "json"}
+	formatNDJSON = fileFormat{name: "ndjson"}
+	formatCSV    = fileFormat{name: "csv", comma: ','}
+	formatTSV    = fileFormat{name: "tsv", comma: '\t'}
+)
+
+// formatsByName maps --format values to input formats
+var formatsByName = map[string]fileFormat{
+	"json":   formatJSON,
+	"ndjson": formatNDJSON,
+	"csv":    formatCSV,
+	"tsv":    formatTSV,
+}
+
+// formatsByExt maps file extensions to input formats
+var formatsByExt = map[string]fileFormat{
+	".json":   formatJSON,
+	".ndjson": formatNDJSON,
+	".jsonl":  formatNDJSON,
+	".csv":    formatCSV,
+	".tsv":    formatTSV,
+}
+
+// runObscure implements "simulacrum obscure"
+func runObscure(args []string) error {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return err
+	}
+
+	flags := flag.NewFlagSet("obscure", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: simulacrum obscure [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "Obscures a file, every JSON/NDJSON file below a directory, or stdin.\n\n")
+		flags.PrintDefaults()
+	}
+	in := flags.String("in", "-", "input file or directory, - for stdin")
+	out := flags.String("out", "-", "output file or directory, - for stdout")
+	formatName := flags.String("format", "", "input format, json, ndjson, csv or tsv (default: from the file extension, json for stdin)")
+	delimiter := flags.String("delimiter", "", "field delimiter for csv and tsv input (default: , for csv, tab for tsv)")
+	rulesFile := flags.String("rules", cfg.Obscure.RulesFile, "YAML rules file (default: built-in rules)")
+	keyFile := flags.String("key-file", cfg.Obscure.SecretKeyFile, "file containing the hashing secret")
+	idKeys := flags.String("id-keys", strings.Join(cfg.Obscure.IDKeys, ","), "comma-separated record identifier keys")
+	persona := flags.Bool("persona", cfg.Obscure.Persona, "generate the name, email and gender fields of a record from one fake person")
+	locale := flags.String("locale", cfg.Obscure.Locale, "generate names and addresses in a locale such as de_DE, or auto to follow each record's country")
+	dictionaries := maps.Clone(cfg.Obscure.Dictionaries)
+	flags.Func("dictionary", "word list file as name=file, repeatable, replacing a built-in list such as last_names or adding one for word rules", func(s string) error {
+		name, file, ok := strings.Cut(s, "=")
+		if !ok || name == "" || file == "" {
+			return fmt.Errorf("expected name=file")
+		}
+		if dictionaries == nil {
+			dictionaries = make(map[string]string)
+		}
+		dictionaries[name] = file
+		return nil
+	})
+	workers := flags.Int("workers", runtime.NumCPU(), "number of files processed in parallel")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var format fileFormat
+	if *formatName != "" {
+		var ok bool
+		if format, ok = formatsByName[*formatName]; !ok {
+			return fmt.Errorf("unknown format %q, expected json, ndjson, csv or tsv", *formatName)
+		}
+	}
+	var comma byte
+	if *delimiter != "" {
+		if len(*delimiter) != 1 || strings.ContainsAny(*delimiter, "\"\r\n") {
+			return fmt.Errorf("--delimiter must be a single ASCII character other than a quote or newline")
+		}
+		comma = (*delimiter)[0]
+	}
+	if *workers < 1 {
+		*workers = 1
+	}
+
+	hashKey, err := loadHashKey(*keyFile, cfg.Obscure.SecretKey)
+	if err != nil {
+		return fmt.Errorf("failed to load hash key: %w", err)
+	}
+	if err := data.SetHashKey(hashKey); err != nil {
+		return fmt.Errorf("invalid hash key: %w", err)
+	}
+
+	if err := data.LoadDictionaries(dictionaries); err != nil {
+		return err
+	}
+	ruleSet := rules.Default()
+	if *rulesFile != "" {
+		if ruleSet, err = rules.LoadFromFile(*rulesFile); err != nil {
+			return err
+		}
+	}
+	if err := handlers.CheckDictionaries(ruleSet); err != nil {
+		return err
+	}
+	o := handlers.NewObscurer(ruleSet, config.SplitList(*idKeys))
+	o.Persona = *persona
+	if err := o.SetLocale(*locale); err != nil {
+		return err
+	}
+
+	if *in == "-" {
+		return obscureStream(o, withDelimiter(orDefault(format, formatJSON), comma), os.Stdin, *out)
+	}
+
+	info, err := os.Stat(*in)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		if *out == "-" {
+			return fmt.Errorf("--out must be a directory when --in is a directory")
+		}
+		// Output written below the input would be obscured again on every run
+		if withinDir(*in, *out) {
+			return fmt.Errorf("--out must not be --in or a directory inside it")
+		}
+		return obscureDir(o, *in, *out, format, comma, *workers)
+	}
+
+	// Writing over the input would destroy it before it is read
+	if *out != "-" {
+		if outInfo, err := os.Stat(*out); err == nil && os.SameFile(info, outInfo) {
+			return fmt.Errorf("--out must not be the same file as --in")
+		}
+	}
+	f, err := os.Open(*in)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return obscureStream(o, withDelimiter(orDefault(format, formatFor(*in)), comma), f, *out)
+}
+
+// obscureStream obscures r into the file out, or stdout for "-"
+func obscureStream(o *handlers.Obscurer, format fileFormat, r io.Reader, out string) error {
+	if out == "-" {
+		return obscure(o, format, r, os.Stdout)
+	}
+	return obscureToFile(o, format, r, out)
+}
+
+// obscureDir obscures every supported file below in, mirroring the directory
+// layout under out. A non-zero format or comma overrides the one taken from
+// each file's extension. Files are processed by workers in parallel; failures
+// are reported per file and don't stop the others. Obscuring keeps no state
+// between files, so the output doesn't depend on the number of workers.
+func obscureDir(o *handlers.Obscurer, in, out string, format fileFormat, comma byte, workers int) error {
+	type job struct {
+		src, dst string
+		format   fileFormat
+	}
+	jobs := make(chan job)
+
+	var mu sync.Mutex
+	var failed, total int
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				if err := obscureFile(o, j.format, j.src, j.dst); err != nil {
+					mu.Lock()
+					failed++
+					fmt.Fprintf(os.Stderr, "%s: %v\n", j.src, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	walkErr := filepath.WalkDir(in, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		fileFormat := orDefault(format, formatFor(path))
+		if fileFormat.name == "" {
+			return nil
+		}
+		fileFormat = withDelimiter(fileFormat, comma)
+		rel, err := filepath.Rel(in, path)
+		if err != nil {
+			return err
+		}
+		total++
+		jobs <- job{src: path, dst: filepath.Join(out, rel), format: fileFormat}
+		return nil
+	})
+	close(jobs)
+	wg.Wait()
+
+	if walkErr != nil {
+		return walkErr
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, total)
+	}
+	return nil
+}
+
+// obscureFile obscures the file src into dst, creating parent directories
+func obscureFile(o *handlers.Obscurer, format fileFormat, src, dst string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return obscureToFile(o, format, f, dst)
+}
+
+// obscureToFile writes the obscured form of r to a temporary file next to dst
+// and renames it over dst on success, so neither partial output is left
+// behind nor an existing dst truncated before r is read
+func obscureToFile(o *handlers.Obscurer, format fileFormat, r io.Reader, dst string) error {
+	f, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	err = f.Chmod(0o644)
+	if err == nil {
+		err = obscure(o, format, r, f)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		os.Remove(tmp)
+	}
+	return err
+}
+
+// obscure writes the obscured form of r to w
+func obscure(o *handlers.Obscurer, format fileFormat, r io.Reader, w io.Writer) error {
+	bw := bufio.NewWriterSize(w, 64<<10)
+	var err error
+	switch {
+	case format.comma != 0:
+		err = o.ObscureCSV(r, bw, format.comma, nil)
+	case format == formatNDJSON:
+		err = o.ObscureNDJSON(r, bw, nil)
+	default:
+		err = o.ObscureJSONStream(r, bw, nil)
+	}
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// formatFor returns the input format for a file name, or the zero format if it isn't supported
+func formatFor(name string) fileFormat {
+	return formatsByExt[strings.ToLower(filepath.Ext(name))]
+}
+
+// loadHashKey resolves the hashing secret from a file or value. Without
+// either a random key is used, so output is not stable across runs.
+func loadHashKey(file, value string) ([]byte, error) {
+	if file != "" {
+		return data.LoadHashKeyFromFile(file)
+	}
+	if value != "" {
+		return data.ParseHashKey([]byte(value))
+	}
+
+	fmt.Fprintln(os.Stderr, "WARNING: no hash key configured, using a random key for this run")
+	key := make([]byte, data.MinHashKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// parseFlags parses command flags, rejecting stray arguments
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return errUsage
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(flags.Output(), "unexpected argument %q\n", flags.Arg(0))
+		flags.Usage()
+		return errUsage
+	}
+	return nil
+}
+
+// withinDir reports whether path is dir or lies below it
+func withinDir(dir, path string) bool {
+	absDir, errDir := filepath.Abs(dir)
+	absPath, errPath := filepath.Abs(path)
+	if errDir != nil || errPath != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// withDelimiter overrides the delimiter of a csv or tsv format
+func withDelimiter(format fileFormat, comma byte) fileFormat {
+	if format.comma != 0 && comma != 0 {
+		format.comma = comma
+	}
+	return format
+}
+
+func orDefault(value, def fileFormat) fileFormat {
+	if value.name != "" {
+		return value
+	}
+	return def
+}
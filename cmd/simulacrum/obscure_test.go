@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"simulacrum/internal/data"
+	"simulacrum/internal/handlers"
+	"simulacrum/internal/rules"
+)
+
+func setTestHashKey(t *testing.T) {
+	t.Helper()
+	if err := data.SetHashKey(bytes.Repeat([]byte("k"), data.MinHashKeyLength)); err != nil {
+		t.Fatalf("Failed to set hash key: %v", err)
+	}
+}
+
+func TestObscureDir(t *testing.T) {
+	setTestHashKey(t)
+	in, out := t.TempDir(), filepath.Join(t.TempDir(), "sanitized")
+
+	files := map[string]string{
+		"customers.json":       `[{"id":"c1","name":"John Doe"},{"id":"c2","name":"Jane Roe"}]`,
+		"nested/order.json":    `{"id":"o1","email":"john@example.com"}`,
+		"nested/events.ndjson": "{\"id\":\"e1\",\"ssn\":\"123-45-6789\"}\nnot json\n",
+		"people.csv":           "id,first_name,note\r\n7,\"Ann\",\"a, b\"\r\n",
+		"README.txt":           "not obscured",
+	}
+	for name, content := range files {
+		path := filepath.Join(in, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	o := handlers.NewObscurer(nil, nil)
+	if err := obscureDir(o, in, out, fileFormat{}, 0, 2); err != nil {
+		t.Fatalf("obscureDir failed: %v", err)
+	}
+
+	var customers []map[string]any
+	readJSON(t, filepath.Join(out, "customers.json"), &customers)
+	if len(customers) != 2 || customers[1]["name"] != data.GenerateDeterministicName("c2", "Jane Roe") {
+		t.Errorf("Expected obscured customers, got %v", customers)
+	}
+
+	var order map[string]any
+	readJSON(t, filepath.Join(out, "nested", "order.json"), &order)
+	if order["email"] == "john@example.com" {
+		t.Errorf("Expected email to be obscured")
+	}
+
+	events, err := os.ReadFile(filepath.Join(out, "nested", "events.ndjson"))
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(events)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], data.GenerateDeterministicSSN("e1", "123-45-6789")) {
+		t.Errorf("Expected obscured ssn on first line, got %q", events)
+	}
+	if lines[1] != `{"error":"invalid JSON","line":2}` {
+		t.Errorf("Expected per-line error, got %s", lines[1])
+	}
+
+	people, err := os.ReadFile(filepath.Join(out, "people.csv"))
+	if err != nil {
+		t.Fatalf("Failed to read people: %v", err)
+	}
+	wantPeople := "id,first_name,note\r\n7,\"" + data.GenerateDeterministicFirstName("7", "Ann") + "\",\"a, b\"\r\n"
+	if string(people) != wantPeople {
+		t.Errorf("Expected %q, got %q", wantPeople, people)
+	}
+
+	if _, err := os.Stat(filepath.Join(out, "README.txt")); !os.IsNotExist(err) {
+		t.Errorf("Expected unsupported files to be skipped")
+	}
+}
+
+func TestObscureDirReportsFailures(t *testing.T) {
+	setTestHashKey(t)
+	in, out := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(in, "good.json"), []byte(`{"name":"John"}`), 0o644)
+	os.WriteFile(filepath.Join(in, "bad.json"), []byte(`{"name":`), 0o644)
+
+	err := obscureDir(handlers.NewObscurer(nil, nil), in, out, fileFormat{}, 0, 4)
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 files failed") {
+		t.Fatalf("Expected one failure, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "good.json")); err != nil {
+		t.Errorf("Expected good file to be written: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "bad.json")); !os.IsNotExist(err) {
+		t.Errorf("Expected no partial output for the failed file")
+	}
+}
+
+func TestObscureFormats(t *testing.T) {
+	setTestHashKey(t)
+	o := handlers.NewObscurer(nil, nil)
+
+	var out bytes.Buffer
+	if err := obscure(o, formatJSON, strings.NewReader(`{"id":"1","name":"John Doe","note":"x"}`), &out); err != nil {
+		t.Fatalf("obscure failed: %v", err)
+	}
+	want := `{"id":"1","name":"` + data.GenerateDeterministicName("1", "John Doe") + `","note":"x"}`
+	if out.String() != want {
+		t.Errorf("Expected %s, got %s", want, out.String())
+	}
+
+	out.Reset()
+	if err := obscure(o, formatNDJSON, strings.NewReader("{\"name\":\"A\"}\n{\"name\":\"B\"}\n"), &out); err != nil {
+		t.Fatalf("obscure failed: %v", err)
+	}
+	if n := strings.Count(out.String(), "\n"); n != 2 {
+		t.Errorf("Expected 2 NDJSON records, got %q", out.String())
+	}
+
+	out.Reset()
+	if err := obscure(o, withDelimiter(formatCSV, ';'), strings.NewReader("id;city\n1;Paris\n"), &out); err != nil {
+		t.Fatalf("obscure failed: %v", err)
+	}
+	if want := "id;city\n1;" + data.GenerateDeterministicCity("1", "Paris") + "\n"; out.String() != want {
+		t.Errorf("Expected %q, got %q", want, out.String())
+	}
+}
+
+func TestFormatFor(t *testing.T) {
+	tests := map[string]fileFormat{
+		"a.json":       formatJSON,
+		"b.NDJSON":     formatNDJSON,
+		"dir/c.jsonl":  formatNDJSON,
+		"d.csv":        formatCSV,
+		"e.tsv":        formatTSV,
+		"f.txt":        {},
+		"no_extension": {},
+	}
+	for name, want := range tests {
+		if got := formatFor(name); got != want {
+			t.Errorf("formatFor(%q): expected %q, got %q", name, want.name, got.name)
+		}
+	}
+}
+
+func readJSON(t *testing.T, path string, v any) {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read %s: %v", path, err)
+	}
+	if err := json.Unmarshal(content, v); err != nil {
+		t.Fatalf("Invalid JSON in %s: %v", path, err)
+	}
+}
+
+func TestRunObscureRejectsOutInsideIn(t *testing.T) {
+	in := t.TempDir()
+	os.WriteFile(filepath.Join(in, "customers.json"), []byte(`{"name":"John"}`), 0o644)
+	for _, out := range []string{in, filepath.Join(in, "out"), filepath.Join(in, "a", "..", "out", "nested")} {
+		err := runObscure([]string{"--in", in, "--out", out})
+		if err == nil || !strings.Contains(err.Error(), "inside") {
+			t.Errorf("Expected --out %s to be rejected, got %v", out, err)
+		}
+	}
+	if withinDir(in, in+"..sibling") || withinDir(filepath.Join(in, "sub"), in) {
+		t.Error("Expected siblings and parents not to be inside --in")
+	}
+}
+
+func TestObscureDirWorkersDeterministic(t *testing.T) {
+	setTestHashKey(t)
+	rs, err := rules.Parse([]byte("rules:\n  - kind: email\n    fields: [email]\n    options:\n      unique: true\n  - kind: name\n    fields: [name]\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	in := t.TempDir()
+	for i := range 20 {
+		content := fmt.Sprintf(`[{"id":"a%d","name":"Ann","email":"shared@corp.example"},{"id":"b%d","email":"user%d@corp.example"}]`, i, i, i)
+		os.WriteFile(filepath.Join(in, fmt.Sprintf("part%02d.json", i)), []byte(content), 0o644)
+	}
+
+	var outputs []string
+	for _, workers := range []int{1, 8} {
+		out := t.TempDir()
+		if err := obscureDir(handlers.NewObscurer(rs, nil), in, out, fileFormat{}, 0, workers); err != nil {
+			t.Fatalf("obscureDir failed: %v", err)
+		}
+		var all strings.Builder
+		for i := range 20 {
+			b, _ := os.ReadFile(filepath.Join(out, fmt.Sprintf("part%02d.json", i)))
+			all.Write(b)
+		}
+		outputs = append(outputs, all.String())
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("Expected the same output with 1 and 8 workers, got\n%s\n%s", outputs[0], outputs[1])
+	}
+}
+
+func TestRunObscureRejectsOutSameAsIn(t *testing.T) {
+	dir := t.TempDir()
+	in := filepath.Join(dir, "x.json")
+	content := []byte(`{"name":"John"}`)
+	os.WriteFile(in, content, 0o644)
+
+	for _, out := range []string{in, filepath.Join(dir, ".", "x.json")} {
+		err := runObscure([]string{"--in", in, "--out", out})
+		if err == nil || !strings.Contains(err.Error(), "same file") {
+			t.Errorf("Expected --out %s to be rejected, got %v", out, err)
+		}
+	}
+	if got, err := os.ReadFile(in); err != nil || !bytes.Equal(got, content) {
+		t.Errorf("Expected the input to survive, got %q, %v", got, err)
+	}
+}
+
+func TestObscureToFileKeepsDestinationOnFailure(t *testing.T) {
+	setTestHashKey(t)
+	dir := t.TempDir()
+	dst := filepath.Join(dir, "out.json")
+	os.WriteFile(dst, []byte("previous"), 0o644)
+
+	err := obscureToFile(handlers.NewObscurer(nil, nil), formatJSON, strings.NewReader(`{"name":`), dst)
+	if err == nil {
+		t.Fatal("Expected invalid JSON to fail")
+	}
+	if got, _ := os.ReadFile(dst); string(got) != "previous" {
+		t.Errorf("Expected the existing output to be kept, got %q", got)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected no temporary files left behind, got %d entries", len(entries))
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+
+	"simulacrum/internal/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// runToken implements "simulacrum token"
+func runToken(args []string) error {
+	extra := map[string]any{}
+
+	flags := flag.NewFlagSet("token", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: simulacrum token [flags]\n\n")
+		fmt.Fprintf(flags.Output(), "Mints a signed test token and prints it.\n\n")
+		flags.PrintDefaults()
+	}
+	keyFile := flags.String("key", "test_private.key", "private key file to sign with")
+	alg := flags.String("alg", "", "signing algorithm (default: RS256, ES256, ES384 or EdDSA from the key)")
+	kid := flags.String("kid", "", "key id header")
+	subject := flags.String("sub", "test-user", "subject claim")
+	issuer := flags.String("iss", "", "issuer claim")
+	audience := flags.String("aud", "", "comma-separated audience claim")
+	scope := flags.String("scope", "", "space-separated scope claim, e.g. obscure:qa")
+	expires := flags.Duration("expires", 24*time.Hour, "lifetime of the token")
+	flags.Func("claim", "extra claim as name=value, repeatable, overriding the flags above; JSON values are decoded, e.g. roles=[\"obscure:qa\"]", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("expected name=value")
+		}
+		var decoded any
+		if err := json.Unmarshal([]byte(value), &decoded); err == nil {
+			extra[name] = decoded
+		} else {
+			extra[name] = value
+		}
+		return nil
+	})
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if *expires <= 0 {
+		return fmt.Errorf("--expires must be positive")
+	}
+
+	key, err := loadPrivateKey(*keyFile)
+	if err != nil {
+		return err
+	}
+	method, err := signingMethod(key, *alg)
+	if err != nil {
+		return err
+	}
+
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return err
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"jti": hex.EncodeToString(jti),
+		"iat": now.Unix(),
+		"nbf": now.Unix(),
+		"exp": now.Add(*expires).Unix(),
+	}
+	if *subject != "" {
+		claims["sub"] = *subject
+	}
+	if *issuer != "" {
+		claims["iss"] = *issuer
+	}
+	if aud := config.SplitList(*audience); len(aud) > 0 {
+		claims["aud"] = aud
+	}
+	if *scope != "" {
+		claims["scope"] = *scope
+	}
+	maps.Copy(claims, extra)
+
+	token := jwt.NewWithClaims(method, claims)
+	if *kid != "" {
+		token.Header["kid"] = *kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return fmt.Errorf("failed to sign token: %w", err)
+	}
+	fmt.Println(signed)
+	return nil
+}
+
+// signingMethod returns the requested algorithm, or the default for the key
+func signingMethod(key crypto.Signer, alg string) (jwt.SigningMethod, error) {
+	if alg == "" {
+		switch k := key.(type) {
+		case *rsa.PrivateKey:
+			alg = "RS256"
+		case *ecdsa.PrivateKey:
+			alg = fmt.Sprintf("ES%d", k.Curve.Params().BitSize)
+		case ed25519.PrivateKey:
+			alg = "EdDSA"
+		}
+	}
+	method := jwt.GetSigningMethod(alg)
+	if method == nil || strings.HasPrefix(alg, "HS") || alg == "none" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", alg)
+	}
+	return method, nil
+}
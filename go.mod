@@ -3,7 +3,6 @@ module simulacrum
 go 1.25.5
 
 require (
-	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/gin-gonic/gin v1.11.0
 	github.com/golang-jwt/jwt/v5 v5.3.0
 	github.com/valyala/fastjson v1.6.7
@@ -0,0 +1,146 @@
+// Package audit writes one structured record per obscure request: who asked,
+// with which profile, how much was obscured and how it ended. Records never
+// contain request or response values.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"simulacrum/internal/auth"
+	"simulacrum/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Outcomes of a request
+const (
+	OutcomeSuccess  = "success"
+	OutcomeDenied   = "denied"
+	OutcomeRejected = "rejected"
+	OutcomeFailed   = "failed"
+)
+
+// Entry is one audit record, written as a single line of JSON
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Method  string    `json:"method"`
+	Path    string    `json:"path"`
+	Status  int       `json:"status"`
+	Outcome string    `json:"outcome"`
+	// Code is the token error code of denied requests, e.g. token_revoked
+	Code      string  `json:"code,omitempty"`
+	LatencyMS float64 `json:"latency_ms"`
+	Subject   string  `json:"sub,omitempty"`
+	TokenID   string  `json:"jti,omitempty"`
+	Client    string  `json:"client,omitempty"`
+	Profile   string  `json:"profile,omitempty"`
+	Tenant    string  `json:"tenant,omitempty"`
+	BytesIn   int64   `json:"bytes_in"`
+	BytesOut  int     `json:"bytes_out"`
+	Records   int     `json:"records"`
+	// Fields counts transformed values per field kind
+	Fields map[string]int `json:"fields,omitempty"`
+}
+
+// Logger writes entries as JSON lines. It is safe for concurrent use.
+type Logger struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewLogger creates a Logger writing to w
+func NewLogger(w io.Writer) *Logger {
+	return &Logger{enc: json.NewEncoder(w)}
+}
+
+// Log writes one entry
+func (l *Logger) Log(e *Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(e)
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// Middleware logs an entry for every request once it has been served. It
+// must run before the authentication middleware so rejected requests are
+// logged too.
+func Middleware(l *Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		body := &countingReader{ReadCloser: c.Request.Body}
+		c.Request.Body = body
+
+		c.Next()
+
+		e := &Entry{
+			Time:      start.UTC(),
+			Method:    c.Request.Method,
+			Path:      c.FullPath(),
+			Status:    c.Writer.Status(),
+			LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+			BytesIn:   body.n,
+			BytesOut:  max(c.Writer.Size(), 0),
+		}
+		e.Outcome, e.Code = outcome(c)
+
+		if claims, ok := auth.GetClaims(c); ok {
+			e.Subject, _ = claims["sub"].(string)
+			e.TokenID, _ = claims["jti"].(string)
+		}
+		if id, ok := auth.GetClientIdentity(c); ok {
+			if names := id.Names(); len(names) > 0 {
+				e.Client = names[0]
+			}
+		}
+		e.Profile, _ = handlers.GetProfile(c)
+		e.Tenant, _ = handlers.GetTenant(c)
+		if stats, ok := handlers.GetStats(c); ok {
+			e.Records = stats.Records
+			e.Fields = stats.Fields
+		}
+
+		_ = l.Log(e)
+	}
+}
+
+// outcome classifies a served request. Streamed responses start with 200,
+// so an error attached afterwards means the stream was cut short.
+func outcome(c *gin.Context) (string, string) {
+	var tokenErr *auth.TokenError
+	for _, err := range c.Errors {
+		if errors.As(err.Err, &tokenErr) {
+			break
+		}
+	}
+
+	status := c.Writer.Status()
+	switch {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		if tokenErr != nil {
+			return OutcomeDenied, tokenErr.Code
+		}
+		return OutcomeDenied, ""
+	case status >= 500 || (status < 400 && len(c.Errors) > 0):
+		return OutcomeFailed, ""
+	case status >= 400:
+		return OutcomeRejected, ""
+	default:
+		return OutcomeSuccess, ""
+	}
+}
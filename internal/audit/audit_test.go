@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"simulacrum/internal/auth"
+	"simulacrum/internal/data"
+	"simulacrum/internal/handlers"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestRouter(t *testing.T, logs *bytes.Buffer) (*gin.Engine, *rsa.PrivateKey) {
+	t.Helper()
+	if err := data.SetHashKey(bytes.Repeat([]byte("t"), data.MinHashKeyLength)); err != nil {
+		t.Fatalf("Failed to set hash key: %v", err)
+	}
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	key, _ := auth.NewPublicKey(&privKey.PublicKey)
+	pkm := &auth.PublicKeyManager{Keys: []*auth.PublicKey{key}}
+
+	file := filepath.Join(t.TempDir(), "revoked.yaml")
+	os.WriteFile(file, []byte("jti: [stolen]\n"), 0o644)
+	revoked, err := auth.NewRevocationList(file)
+	if err != nil {
+		t.Fatalf("Failed to load revocation list: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	obscurer := handlers.NewObscurer(nil, nil)
+	handle := handlers.CountStats((*handlers.Obscurer).HandleObscure)
+	router.POST("/obscure", Middleware(NewLogger(logs)), auth.JWTMiddleware(pkm), auth.RevocationMiddleware(revoked),
+		func(c *gin.Context) { handle(obscurer, c) })
+	return router, privKey
+}
+
+func signToken(t *testing.T, key *rsa.PrivateKey, jti string) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub": "user123",
+		"jti": jti,
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	router, privKey := newTestRouter(t, &logs)
+
+	const secret = "john.doe@example.com"
+	tests := []struct {
+		name    string
+		token   string
+		body    string
+		status  int
+		outcome string
+		code    string
+		records int
+	}{
+		{"success", signToken(t, privKey, "fresh"), `[{"id":"1","email":"` + secret + `","name":"John Doe"},{"id":"2","email":"x@example.com"}]`,
+			http.StatusOK, OutcomeSuccess, "", 2},
+		{"revoked", signToken(t, privKey, "stolen"), `{"email":"` + secret + `"}`, http.StatusUnauthorized, OutcomeDenied, auth.ErrCodeTokenRevoked, 0},
+		{"missing token", "", `{}`, http.StatusUnauthorized, OutcomeDenied, auth.ErrCodeMissingToken, 0},
+		{"invalid JSON", signToken(t, privKey, "fresh"), `{"email":`, http.StatusBadRequest, OutcomeRejected, "", 0},
+	}
+	for _, tt := range tests {
+		logs.Reset()
+		req := httptest.NewRequest(http.MethodPost, "/obscure", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		if strings.Contains(logs.String(), secret) {
+			t.Errorf("%s: audit log contains a raw value: %s", tt.name, logs.String())
+		}
+		var e Entry
+		if err := json.Unmarshal(logs.Bytes(), &e); err != nil {
+			t.Fatalf("%s: invalid audit entry %q: %v", tt.name, logs.String(), err)
+		}
+		if e.Status != tt.status || e.Outcome != tt.outcome || e.Code != tt.code || e.Records != tt.records {
+			t.Errorf("%s: expected %d %s %q with %d records, got %+v", tt.name, tt.status, tt.outcome, tt.code, tt.records, e)
+		}
+		if e.Method != http.MethodPost || e.Path != "/obscure" || e.LatencyMS < 0 {
+			t.Errorf("%s: unexpected request details %+v", tt.name, e)
+		}
+		if tt.token != "" && (e.Subject != "user123" || e.TokenID == "") {
+			t.Errorf("%s: expected sub and jti, got %+v", tt.name, e)
+		}
+	}
+}
+
+func TestMiddlewareCounts(t *testing.T) {
+	var logs bytes.Buffer
+	router, privKey := newTestRouter(t, &logs)
+
+	body := `{"id":"1","email":"a@example.com","first_name":"Jane","last_name":"Doe"}`
+	req := httptest.NewRequest(http.MethodPost, "/obscure", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+signToken(t, privKey, "fresh"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var e Entry
+	if err := json.Unmarshal(logs.Bytes(), &e); err != nil {
+		t.Fatalf("Invalid audit entry %q: %v", logs.String(), err)
+	}
+	if e.BytesIn != int64(len(body)) || e.BytesOut != w.Body.Len() {
+		t.Errorf("Expected %d bytes in and %d out, got %d and %d", len(body), w.Body.Len(), e.BytesIn, e.BytesOut)
+	}
+	want := map[string]int{"email": 1, "first_name": 1, "last_name": 1}
+	if len(e.Fields) != len(want) {
+		t.Errorf("Expected fields %v, got %v", want, e.Fields)
+	}
+	for kind, n := range want {
+		if e.Fields[kind] != n {
+			t.Errorf("Expected %d %s, got %d", n, kind, e.Fields[kind])
+		}
+	}
+}
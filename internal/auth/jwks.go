@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxJWKSSize bounds the size of a fetched JWKS document
+const maxJWKSSize = 1 << 20
+
+// jsonWebKey is a single key of a JWKS document (RFC 7517)
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// errUnsupportedKeyType marks JWKS keys that are skipped rather than rejected
+var errUnsupportedKeyType = errors.New("unsupported key type")
+
+// ParseJWKS reads the signature keys of a JWKS document: RSA, EC (P-256 and
+// P-384) and OKP (Ed25519). Keys meant for encryption and key types that
+// aren't supported are skipped. A key's "alg" narrows it to that algorithm.
+func ParseJWKS(data []byte) (*PublicKeyManager, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+
+	manager := &PublicKeyManager{
+		Keys:   []*PublicKey{},
+		KeyIDs: map[string]*PublicKey{},
+	}
+
+	for i, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+
+		key, err := jwk.publicKey()
+		if errors.Is(err, errUnsupportedKeyType) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("JWKS key %d (%s): %w", i, jwk.Kid, err)
+		}
+
+		if jwk.Kid != "" {
+			if _, exists := manager.KeyIDs[jwk.Kid]; exists {
+				return nil, fmt.Errorf("JWKS key %d: duplicate kid %q", i, jwk.Kid)
+			}
+			manager.KeyIDs[jwk.Kid] = key
+		}
+		manager.Keys = append(manager.Keys, key)
+	}
+
+	if len(manager.Keys) == 0 {
+		return nil, fmt.Errorf("no usable signature keys found in JWKS")
+	}
+
+	return manager, nil
+}
+
+// publicKey decodes the key and restricts it to its "alg", if any
+func (jwk *jsonWebKey) publicKey() (*PublicKey, error) {
+	var raw crypto.PublicKey
+	var err error
+	switch jwk.Kty {
+	case "RSA":
+		raw, err = jwk.rsaPublicKey()
+	case "EC":
+		raw, err = jwk.ecdsaPublicKey()
+	case "OKP":
+		raw, err = jwk.ed25519PublicKey()
+	default:
+		return nil, errUnsupportedKeyType
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := NewPublicKey(raw)
+	if err != nil {
+		return nil, err
+	}
+	if jwk.Alg != "" {
+		if !key.Allows(jwk.Alg) {
+			return nil, fmt.Errorf("algorithm %s does not match key type %s", jwk.Alg, jwk.Kty)
+		}
+		key.Algorithms = []string{jwk.Alg}
+	}
+	return key, nil
+}
+
+// rsaPublicKey decodes the modulus and exponent of an RSA key
+func (jwk *jsonWebKey) rsaPublicKey() (*rsa.PublicKey, error) {
+	n, err := decodeBase64URL(jwk.N)
+	if err != nil || len(n) == 0 {
+		return nil, fmt.Errorf("invalid modulus")
+	}
+	e, err := decodeBase64URL(jwk.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+
+	exponent := int(new(big.Int).SetBytes(e).Int64())
+	if exponent < 3 {
+		return nil, fmt.Errorf("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}, nil
+}
+
+// ecdsaPublicKey decodes the coordinates of a P-256 or P-384 key
+func (jwk *jsonWebKey) ecdsaPublicKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch jwk.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	default:
+		return nil, errUnsupportedKeyType
+	}
+
+	size := (curve.Params().BitSize + 7) / 8
+	x, errX := decodeBase64URL(jwk.X)
+	y, errY := decodeBase64URL(jwk.Y)
+	if errX != nil || errY != nil || len(x) != size || len(y) != size {
+		return nil, fmt.Errorf("invalid coordinates")
+	}
+
+	point := append(append([]byte{4}, x...), y...)
+	key, err := ecdsa.ParseUncompressedPublicKey(curve, point)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coordinates: %w", err)
+	}
+	return key, nil
+}
+
+// ed25519PublicKey decodes an Ed25519 key
+func (jwk *jsonWebKey) ed25519PublicKey() (ed25519.PublicKey, error) {
+	if jwk.Crv != "Ed25519" {
+		return nil, errUnsupportedKeyType
+	}
+	x, err := decodeBase64URL(jwk.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("invalid public key")
+	}
+	return ed25519.PublicKey(x), nil
+}
+
+// decodeBase64URL decodes unpadded base64url, tolerating padding
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// LoadJWKSFromFile reads a JWKS document from a file
+func LoadJWKSFromFile(filepath string) (*PublicKeyManager, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// FetchJWKS downloads and parses a JWKS document over https
+func FetchJWKS(ctx context.Context, client *http.Client, url string) (*PublicKeyManager, error) {
+	return fetchJWKS(ctx, client, url, false)
+}
+
+// fetchJWKS downloads and parses a JWKS document. Whoever can tamper with
+// the response chooses the keys that verify tokens, so unless allowInsecure
+// is set both the URL and any redirect target must use https.
+func fetchJWKS(ctx context.Context, client *http.Client, url string, allowInsecure bool) (*PublicKeyManager, error) {
+	if !allowInsecure && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("JWKS URL %s must use https", url)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if !allowInsecure && resp.Request.URL.Scheme != "https" {
+		return nil, fmt.Errorf("JWKS URL %s redirected to %s, which is not https", url, resp.Request.URL)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	if len(data) > maxJWKSSize {
+		return nil, fmt.Errorf("JWKS document larger than %d bytes", maxJWKSSize)
+	}
+	return ParseJWKS(data)
+}
+
+// IsJWKSURL reports whether a JWKS source is a URL rather than a file path.
+// Plain http URLs count too, so they are refused rather than read as files.
+func IsJWKSURL(source string) bool {
+	return strings.HasPrefix(source, "https://") || strings.HasPrefix(source, "http://")
+}
+
+// NewJWKSKeyStore creates a KeyStore backed by a JWKS document at a URL or
+// file path. Keys are cached until the store is reloaded. A URL must use
+// https unless allowInsecure is set, which is only meant for local testing.
+func NewJWKSKeyStore(source string, client *http.Client, allowInsecure bool) (*KeyStore, error) {
+	if !IsJWKSURL(source) {
+		return NewKeyStore(func() (*PublicKeyManager, error) {
+			return LoadJWKSFromFile(source)
+		})
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return NewKeyStore(func() (*PublicKeyManager, error) {
+		return fetchJWKS(context.Background(), client, source, allowInsecure)
+	})
+}
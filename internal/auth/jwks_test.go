@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// rsaJWK encodes an RSA public key as a JWK
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func jwksDocument(keys ...map[string]string) []byte {
+	data, _ := json.Marshal(map[string]any{"keys": keys})
+	return data
+}
+
+// createTestTokenWithKid signs a token carrying a kid header
+func createTestTokenWithKid(privateKey *rsa.PrivateKey, kid string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub": "user123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, _ := token.SignedString(privateKey)
+	return signed
+}
+
+func TestParseJWKSKidSelection(t *testing.T) {
+	priv1, pub1, _ := generateTestKeyPair()
+	priv2, pub2, _ := generateTestKeyPair()
+
+	pkm, err := ParseJWKS(jwksDocument(rsaJWK("k1", pub1), rsaJWK("k2", pub2)))
+	if err != nil {
+		t.Fatalf("Failed to parse JWKS: %v", err)
+	}
+	if len(pkm.Keys) != 2 || len(pkm.KeyIDs) != 2 {
+		t.Fatalf("Expected 2 keys, got %d (%d ids)", len(pkm.Keys), len(pkm.KeyIDs))
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		valid bool
+	}{
+		{"matching kid", createTestTokenWithKid(priv2, "k2"), true},
+		{"no kid falls back to all keys", createTestTokenWithKid(priv2, ""), true},
+		{"unknown kid falls back to all keys", createTestTokenWithKid(priv1, "retired"), true},
+		{"kid naming another key", createTestTokenWithKid(priv2, "k1"), false},
+	}
+	for _, tt := range tests {
+		_, err := pkm.ValidateToken(tt.token)
+		if tt.valid && err != nil {
+			t.Errorf("%s: expected valid token, got %v", tt.name, err)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("%s: expected token to be rejected", tt.name)
+		}
+	}
+}
+
+func TestParseJWKSSkipsAndRejects(t *testing.T) {
+	_, pub, _ := generateTestKeyPair()
+
+	enc := rsaJWK("enc", pub)
+	enc["use"] = "enc"
+	pkm, err := ParseJWKS(jwksDocument(enc, map[string]string{"kty": "oct", "k": "c2VjcmV0"}, rsaJWK("sig", pub)))
+	if err != nil {
+		t.Fatalf("Failed to parse JWKS: %v", err)
+	}
+	if len(pkm.Keys) != 1 || pkm.KeyIDs["sig"] == nil {
+		t.Errorf("Expected only the signature key, got %d keys", len(pkm.Keys))
+	}
+
+	invalid := map[string][]byte{
+		"not json":       []byte("{"),
+		"no usable keys": jwksDocument(enc),
+		"duplicate kid":  jwksDocument(rsaJWK("k", pub), rsaJWK("k", pub)),
+		"bad modulus":    jwksDocument(map[string]string{"kty": "RSA", "n": "!!", "e": "AQAB"}),
+	}
+	for name, doc := range invalid {
+		if _, err := ParseJWKS(doc); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseJWKSKeyTypes(t *testing.T) {
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	ecJWK := func(kid, crv string, key *ecdsa.PublicKey) map[string]string {
+		point, _ := key.Bytes()
+		size := (len(point) - 1) / 2
+		return map[string]string{
+			"kty": "EC",
+			"kid": kid,
+			"crv": crv,
+			"x":   base64.RawURLEncoding.EncodeToString(point[1 : 1+size]),
+			"y":   base64.RawURLEncoding.EncodeToString(point[1+size:]),
+		}
+	}
+	okp := map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(edPub)}
+
+	pkm, err := ParseJWKS(jwksDocument(ecJWK("p256", "P-256", &p256Key.PublicKey), ecJWK("p384", "P-384", &p384Key.PublicKey), okp))
+	if err != nil {
+		t.Fatalf("Failed to parse JWKS: %v", err)
+	}
+	if len(pkm.Keys) != 3 {
+		t.Fatalf("Expected 3 keys, got %d", len(pkm.Keys))
+	}
+
+	tokens := map[string]string{
+		"ES256": signTestToken(t, jwt.SigningMethodES256, p256Key),
+		"ES384": signTestToken(t, jwt.SigningMethodES384, p384Key),
+		"EdDSA": signTestToken(t, jwt.SigningMethodEdDSA, edKey),
+	}
+	for name, token := range tokens {
+		if _, err := pkm.ValidateToken(token); err != nil {
+			t.Errorf("%s: failed to validate token: %v", name, err)
+		}
+	}
+
+	// An "alg" narrows the key to that algorithm
+	_, rsaPub, _ := generateTestKeyPair()
+	pkm, err = ParseJWKS(jwksDocument(rsaJWK("rsa", rsaPub)))
+	if err != nil {
+		t.Fatalf("Failed to parse JWKS: %v", err)
+	}
+	if algs := pkm.KeyIDs["rsa"].Algorithms; len(algs) != 1 || algs[0] != "RS256" {
+		t.Errorf("Expected key restricted to RS256, got %v", algs)
+	}
+
+	mismatched := ecJWK("p256", "P-256", &p256Key.PublicKey)
+	mismatched["alg"] = "RS256"
+	shortX := ecJWK("p256", "P-256", &p256Key.PublicKey)
+	shortX["x"] = shortX["x"][4:]
+	invalid := map[string][]byte{
+		"alg for another key type": jwksDocument(mismatched),
+		"truncated coordinate":     jwksDocument(shortX),
+	}
+	for name, doc := range invalid {
+		if _, err := ParseJWKS(doc); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	// Unsupported curves are skipped like unsupported key types
+	if _, err := ParseJWKS(jwksDocument(map[string]string{"kty": "OKP", "crv": "X25519", "x": okp["x"]})); err == nil {
+		t.Error("Expected error for a JWKS with only an X25519 key")
+	}
+}
+
+func TestJWKSKeyStoreFromURL(t *testing.T) {
+	priv1, pub1, _ := generateTestKeyPair()
+	priv2, pub2, _ := generateTestKeyPair()
+
+	var doc atomic.Pointer[[]byte]
+	var requests, failing atomic.Int32
+	first := jwksDocument(rsaJWK("k1", pub1))
+	doc.Store(&first)
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if failing.Load() != 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(*doc.Load())
+	}))
+	defer srv.Close()
+
+	ks, err := NewJWKSKeyStore(srv.URL, srv.Client(), false)
+	if err != nil {
+		t.Fatalf("Failed to load JWKS: %v", err)
+	}
+
+	// Keys are cached between refreshes
+	for range 3 {
+		if _, err := ks.ValidateToken(createTestTokenWithKid(priv1, "k1")); err != nil {
+			t.Fatalf("Expected token to validate: %v", err)
+		}
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("Expected 1 JWKS request, got %d", n)
+	}
+
+	// The identity provider rotates its key
+	rotated := jwksDocument(rsaJWK("k1", pub1), rsaJWK("k2", pub2))
+	doc.Store(&rotated)
+	if err := ks.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, err := ks.ValidateToken(createTestTokenWithKid(priv2, "k2")); err != nil {
+		t.Errorf("Expected rotated key to validate: %v", err)
+	}
+
+	// An outage keeps the cached keys
+	failing.Store(1)
+	if err := ks.Reload(); err == nil {
+		t.Error("Expected reload to fail while the JWKS endpoint is down")
+	}
+	if _, err := ks.ValidateToken(createTestTokenWithKid(priv2, "k2")); err != nil {
+		t.Errorf("Expected cached keys to stay in use: %v", err)
+	}
+}
+
+func TestJWKSKeyStoreRequiresHTTPS(t *testing.T) {
+	priv, pub, _ := generateTestKeyPair()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwksDocument(rsaJWK("k1", pub)))
+	}))
+	defer srv.Close()
+
+	if _, err := NewJWKSKeyStore(srv.URL, srv.Client(), false); err == nil || !strings.Contains(err.Error(), "https") {
+		t.Errorf("Expected a plain http JWKS URL to be rejected, got %v", err)
+	}
+
+	// An https endpoint must not redirect to plain http either
+	redirect := httptest.NewTLSServer(http.RedirectHandler(srv.URL, http.StatusFound))
+	defer redirect.Close()
+	if _, err := NewJWKSKeyStore(redirect.URL, redirect.Client(), false); err == nil || !strings.Contains(err.Error(), "redirected") {
+		t.Errorf("Expected a redirect to plain http to be rejected, got %v", err)
+	}
+
+	ks, err := NewJWKSKeyStore(srv.URL, srv.Client(), true)
+	if err != nil {
+		t.Fatalf("Expected plain http to load when allowed: %v", err)
+	}
+	if _, err := ks.ValidateToken(createTestTokenWithKid(priv, "k1")); err != nil {
+		t.Errorf("Expected token to validate: %v", err)
+	}
+}
+
+func TestJWKSKeyStoreFromFile(t *testing.T) {
+	priv, pub, _ := generateTestKeyPair()
+	file := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(file, jwksDocument(rsaJWK("k1", pub)), 0o600); err != nil {
+		t.Fatalf("Failed to write JWKS: %v", err)
+	}
+
+	ks, err := NewJWKSKeyStore(file, nil, false)
+	if err != nil {
+		t.Fatalf("Failed to load JWKS: %v", err)
+	}
+	if _, err := ks.ValidateToken(createTestTokenWithKid(priv, "k1")); err != nil {
+		t.Errorf("Expected token to validate: %v", err)
+	}
+}
@@ -1,23 +1,66 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// PublicKey is a verification key and the signing algorithms it may verify.
+// Tokens are only checked against keys that allow their "alg" header, so a
+// token can't make the server use a key with the wrong algorithm.
+type PublicKey struct {
+	Key        crypto.PublicKey
+	Algorithms []string
+}
+
 // PublicKeyManager holds multiple public keys for JWT validation
 type PublicKeyManager struct {
-	Keys []*rsa.PublicKey
+	Keys []*PublicKey
+	// KeyIDs maps a token's "kid" header to one of Keys, so a token naming
+	// a known key is verified against that key alone
+	KeyIDs map[string]*PublicKey
+}
+
+// NewPublicKey wraps an RSA, ECDSA (P-256 or P-384) or Ed25519 public key,
+// allowing the algorithms that match its type
+func NewPublicKey(key crypto.PublicKey) (*PublicKey, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &PublicKey{Key: k, Algorithms: []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512"}}, nil
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return &PublicKey{Key: k, Algorithms: []string{"ES256"}}, nil
+		case elliptic.P384():
+			return &PublicKey{Key: k, Algorithms: []string{"ES384"}}, nil
+		}
+		return nil, fmt.Errorf("unsupported ECDSA curve %s", k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return &PublicKey{Key: k, Algorithms: []string{"EdDSA"}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// Allows reports whether the key may verify tokens signed with alg
+func (k *PublicKey) Allows(alg string) bool {
+	return slices.Contains(k.Algorithms, alg)
 }
 
 // LoadPublicKeysFromFile reads a PEM file containing one or more public keys
-// Each public key should be in PEM format (-----BEGIN PUBLIC KEY-----)
+// Each public key should be in PEM format (-----BEGIN PUBLIC KEY-----) and
+// be an RSA, ECDSA P-256/P-384 or Ed25519 key
 func LoadPublicKeysFromFile(filepath string) (*PublicKeyManager, error) {
 	data, err := os.ReadFile(filepath)
 	if err != nil {
@@ -25,7 +68,7 @@ func LoadPublicKeysFromFile(filepath string) (*PublicKeyManager, error) {
 	}
 
 	manager := &PublicKeyManager{
-		Keys: []*rsa.PublicKey{},
+		Keys: []*PublicKey{},
 	}
 
 	// Parse multiple PEM blocks from the file
@@ -45,12 +88,12 @@ func LoadPublicKeysFromFile(filepath string) (*PublicKeyManager, error) {
 			return nil, fmt.Errorf("failed to parse public key: %w", err)
 		}
 
-		rsaKey, ok := pubKey.(*rsa.PublicKey)
-		if !ok {
-			return nil, fmt.Errorf("public key is not RSA")
+		key, err := NewPublicKey(pubKey)
+		if err != nil {
+			return nil, err
 		}
 
-		manager.Keys = append(manager.Keys, rsaKey)
+		manager.Keys = append(manager.Keys, key)
 		data = rest
 	}
 
@@ -64,43 +107,65 @@ func LoadPublicKeysFromFile(filepath string) (*PublicKeyManager, error) {
 // ValidateToken validates a JWT token against any of the stored public keys
 // Returns the token claims if valid, error otherwise
 func (pm *PublicKeyManager) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	return pm.ValidateTokenWithPolicy(tokenString, TokenPolicy{})
+}
+
+// ValidateTokenWithPolicy validates a JWT token and checks its claims against
+// policy. Rejections are *TokenError values carrying a code.
+func (pm *PublicKeyManager) ValidateTokenWithPolicy(tokenString string, policy TokenPolicy) (jwt.MapClaims, error) {
 	// Remove "Bearer " prefix if present
 	tokenString = strings.TrimPrefix(tokenString, "Bearer ")
 	tokenString = strings.TrimSpace(tokenString)
 
 	if tokenString == "" {
-		return nil, fmt.Errorf("empty token")
+		return nil, &TokenError{Code: ErrCodeMissingToken, Err: fmt.Errorf("empty token")}
 	}
 
-	var lastErr error
+	token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, pm.keyFunc, policy.parserOptions()...)
+	if err != nil {
+		return nil, classifyParseError(fmt.Errorf("failed to validate token: %w", err))
+	}
 
-	// Try each public key
-	for _, pubKey := range pm.Keys {
-		token, err := jwt.ParseWithClaims(tokenString, jwt.MapClaims{}, func(token *jwt.Token) (any, error) {
-			// Verify signing method is RSA
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return pubKey, nil
-		})
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, &TokenError{Code: ErrCodeInvalidToken, Err: fmt.Errorf("failed to validate token: token claims invalid")}
+	}
 
-		if err != nil {
-			lastErr = err
-			continue
-		}
+	if err := policy.check(claims); err != nil {
+		return nil, err
+	}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			return claims, nil
-		}
+	return claims, nil
+}
 
-		lastErr = fmt.Errorf("token claims invalid")
-	}
+// supportedAlgorithms are the signing algorithms any key may allow
+var supportedAlgorithms = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "EdDSA"}
+
+// keyFunc selects the verification key by the token's "kid" header, falling
+// back to trying every key allowing the token's algorithm when the kid is
+// missing or unknown
+func (pm *PublicKeyManager) keyFunc(token *jwt.Token) (any, error) {
+	alg := token.Method.Alg()
 
-	if lastErr != nil {
-		return nil, fmt.Errorf("failed to validate token: %w", lastErr)
+	if kid, ok := token.Header["kid"].(string); ok {
+		if key, ok := pm.KeyIDs[kid]; ok {
+			if !key.Allows(alg) {
+				return nil, fmt.Errorf("key %q does not allow signing method %s", kid, alg)
+			}
+			return key.Key, nil
+		}
 	}
 
-	return nil, fmt.Errorf("failed to validate token with any public key")
+	var keys []jwt.VerificationKey
+	for _, key := range pm.Keys {
+		if key.Allows(alg) {
+			keys = append(keys, key.Key)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys available for signing method %s", alg)
+	}
+	return jwt.VerificationKeySet{Keys: keys}, nil
 }
 
 // VerifyTokenSignature verifies the token signature directly
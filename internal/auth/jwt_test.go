@@ -1,12 +1,17 @@
 package auth
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -369,3 +374,143 @@ func TestValidateTokenMultipleKeys(t *testing.T) {
 		t.Errorf("Expected subject 'user_multi', got %v", resultClaims["sub"])
 	}
 }
+
+// writePublicKeysPEM writes public keys of any type to a PEM file
+func writePublicKeysPEM(t *testing.T, keys ...crypto.PublicKey) string {
+	t.Helper()
+	var data []byte
+	for _, key := range keys {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatalf("Failed to marshal public key: %v", err)
+		}
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})...)
+	}
+	file := filepath.Join(t.TempDir(), "public_keys.pem")
+	if err := os.WriteFile(file, data, 0o600); err != nil {
+		t.Fatalf("Failed to write public keys: %v", err)
+	}
+	return file
+}
+
+// signTestToken signs a token for user123 with the given method and key
+func signTestToken(t *testing.T, method jwt.SigningMethod, key any) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, jwt.MapClaims{
+		"sub": "user123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("Failed to sign %s token: %v", method.Alg(), err)
+	}
+	return signed
+}
+
+// TestValidateTokenKeyTypes tests ECDSA and Ed25519 keys alongside RSA
+func TestValidateTokenKeyTypes(t *testing.T) {
+	rsaKey, _, _ := generateTestKeyPair()
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	p384Key, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	pkm, err := LoadPublicKeysFromFile(writePublicKeysPEM(t, &rsaKey.PublicKey, &p256Key.PublicKey, &p384Key.PublicKey, edPub))
+	if err != nil {
+		t.Fatalf("Failed to load public keys: %v", err)
+	}
+	if len(pkm.Keys) != 4 {
+		t.Fatalf("Expected 4 keys, got %d", len(pkm.Keys))
+	}
+
+	tests := []struct {
+		method jwt.SigningMethod
+		key    any
+	}{
+		{jwt.SigningMethodRS256, rsaKey},
+		{jwt.SigningMethodPS256, rsaKey},
+		{jwt.SigningMethodES256, p256Key},
+		{jwt.SigningMethodES384, p384Key},
+		{jwt.SigningMethodEdDSA, edKey},
+	}
+	for _, tt := range tests {
+		claims, err := pkm.ValidateToken(signTestToken(t, tt.method, tt.key))
+		if err != nil {
+			t.Errorf("%s: failed to validate token: %v", tt.method.Alg(), err)
+			continue
+		}
+		if claims["sub"] != "user123" {
+			t.Errorf("%s: expected subject 'user123', got %v", tt.method.Alg(), claims["sub"])
+		}
+	}
+}
+
+// TestLoadPublicKeysUnsupportedCurve tests that curves other than P-256 and
+// P-384 are rejected
+func TestLoadPublicKeysUnsupportedCurve(t *testing.T) {
+	p521Key, _ := ecdsa.GenerateKey(elliptic.P521(), rand.Reader)
+	if _, err := LoadPublicKeysFromFile(writePublicKeysPEM(t, &p521Key.PublicKey)); err == nil {
+		t.Error("Expected error for a P-521 key")
+	}
+}
+
+// TestValidateTokenAlgorithmConfusion tests that a key is only used with
+// the algorithms allowed for its type
+func TestValidateTokenAlgorithmConfusion(t *testing.T) {
+	rsaKey, _, _ := generateTestKeyPair()
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	file := writePublicKeysPEM(t, &rsaKey.PublicKey, &p256Key.PublicKey)
+	pkm, err := LoadPublicKeysFromFile(file)
+	if err != nil {
+		t.Fatalf("Failed to load public keys: %v", err)
+	}
+
+	// HS256 keyed with the public key file, which an attacker can read
+	pemBytes, _ := os.ReadFile(file)
+	rsaDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+
+	tokens := map[string]string{
+		"HS256 with PEM as secret": signTestToken(t, jwt.SigningMethodHS256, pemBytes),
+		"HS256 with DER as secret": signTestToken(t, jwt.SigningMethodHS256, rsaDER),
+		"none":                     signTestToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType),
+	}
+	for name, tokenString := range tokens {
+		if _, err := pkm.ValidateToken(tokenString); err == nil {
+			t.Errorf("%s: expected token to be rejected", name)
+		}
+	}
+
+	if _, err := pkm.ValidateToken(signTestToken(t, jwt.SigningMethodES256, p256Key)); err != nil {
+		t.Errorf("Expected ES256 token to validate: %v", err)
+	}
+}
+
+// TestValidateTokenKidAlgorithm tests that a kid only selects its key for
+// algorithms that key allows
+func TestValidateTokenKidAlgorithm(t *testing.T) {
+	rsaKey, _, _ := generateTestKeyPair()
+	p256Key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	rsaPub, _ := NewPublicKey(&rsaKey.PublicKey)
+	ecPub, _ := NewPublicKey(&p256Key.PublicKey)
+	pkm := &PublicKeyManager{
+		Keys:   []*PublicKey{rsaPub, ecPub},
+		KeyIDs: map[string]*PublicKey{"rsa": rsaPub, "ec": ecPub},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"sub": "user123",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "rsa"
+	signed, _ := token.SignedString(p256Key)
+	if _, err := pkm.ValidateToken(signed); err == nil {
+		t.Error("Expected ES256 token naming the RSA key to be rejected")
+	}
+
+	token.Header["kid"] = "ec"
+	signed, _ = token.SignedString(p256Key)
+	if _, err := pkm.ValidateToken(signed); err != nil {
+		t.Errorf("Expected ES256 token naming the EC key to validate: %v", err)
+	}
+}
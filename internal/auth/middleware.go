@@ -1,18 +1,30 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
-// JWTMiddleware creates a middleware that validates JWT tokens
-func JWTMiddleware(pkm *PublicKeyManager) gin.HandlerFunc {
+// TokenValidator validates a bearer token and returns its claims. Both
+// PublicKeyManager and KeyStore implement it.
+type TokenValidator interface {
+	ValidateToken(tokenString string) (jwt.MapClaims, error)
+}
+
+// JWTMiddleware creates a middleware that validates JWT tokens. Rejections
+// answer 401 with a code such as "token_expired", see TokenErrorCode; the
+// underlying error is attached to the context for logging only.
+func JWTMiddleware(pkm TokenValidator) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
+			_ = c.Error(&TokenError{Code: ErrCodeMissingToken, Err: errors.New("missing authorization header")})
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header", "code": ErrCodeMissingToken})
 			c.Abort()
 			return
 		}
@@ -20,7 +32,8 @@ func JWTMiddleware(pkm *PublicKeyManager) gin.HandlerFunc {
 		// Validate the token
 		claims, err := pkm.ValidateToken(authHeader)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token", "details": err.Error()})
+			_ = c.Error(err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token", "code": TokenErrorCode(err)})
 			c.Abort()
 			return
 		}
@@ -38,8 +51,14 @@ func GetClaims(c *gin.Context) (map[string]any, bool) {
 		return nil, false
 	}
 
-	claimsMap, ok := claims.(map[string]any)
-	return claimsMap, ok
+	switch claimsMap := claims.(type) {
+	case jwt.MapClaims:
+		return claimsMap, true
+	case map[string]any:
+		return claimsMap, true
+	default:
+		return nil, false
+	}
 }
 
 // GetClaimString retrieves a string claim from the context
@@ -57,3 +76,34 @@ func GetClaimString(c *gin.Context, key string) (string, bool) {
 	str, ok := value.(string)
 	return str, ok
 }
+
+// ClaimScopes collects the scopes and roles granted by a token: the
+// space-separated "scope" claim and the "scp" and "roles" claims, each either
+// a string or a list of strings
+func ClaimScopes(claims map[string]any) []string {
+	var scopes []string
+	for _, name := range []string{"scope", "scp", "roles"} {
+		switch value := claims[name].(type) {
+		case string:
+			scopes = append(scopes, strings.Fields(value)...)
+		case []any:
+			for _, item := range value {
+				if s, ok := item.(string); ok && s != "" {
+					scopes = append(scopes, s)
+				}
+			}
+		case []string:
+			scopes = append(scopes, value...)
+		}
+	}
+	return scopes
+}
+
+// GetScopes retrieves the scopes and roles of the token from the context
+func GetScopes(c *gin.Context) []string {
+	claims, exists := GetClaims(c)
+	if !exists {
+		return nil
+	}
+	return ClaimScopes(claims)
+}
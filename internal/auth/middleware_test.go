@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestGetClaims(t *testing.T) {
+	c, _ := gin.CreateTestContext(nil)
+	if _, ok := GetClaims(c); ok {
+		t.Error("Expected no claims before the middleware ran")
+	}
+
+	// JWTMiddleware stores the claims as jwt.MapClaims
+	c.Set("jwt_claims", jwt.MapClaims{"sub": "user123", "exp": 1.0})
+	claims, ok := GetClaims(c)
+	if !ok || claims["sub"] != "user123" {
+		t.Errorf("Expected claims with sub user123, got %v", claims)
+	}
+	if sub, ok := GetClaimString(c, "sub"); !ok || sub != "user123" {
+		t.Errorf("Expected sub user123, got %q", sub)
+	}
+	if _, ok := GetClaimString(c, "exp"); ok {
+		t.Error("Expected a non-string claim to be reported missing")
+	}
+}
+
+func TestClaimScopes(t *testing.T) {
+	claims := map[string]any{
+		"scope": "obscure:qa  read",
+		"scp":   []any{"obscure:export", 42, ""},
+		"roles": "admin",
+	}
+	want := []string{"obscure:qa", "read", "obscure:export", "admin"}
+	if got := ClaimScopes(claims); !slices.Equal(got, want) {
+		t.Errorf("Expected scopes %v, got %v", want, got)
+	}
+	if got := ClaimScopes(map[string]any{"scope": 1}); len(got) != 0 {
+		t.Errorf("Expected no scopes, got %v", got)
+	}
+}
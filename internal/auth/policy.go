@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Error codes returned to clients when a token is rejected
+const (
+	ErrCodeMissingToken     = "missing_token"
+	ErrCodeMalformedToken   = "malformed_token"
+	ErrCodeInvalidSignature = "invalid_signature"
+	ErrCodeTokenExpired     = "token_expired"
+	ErrCodeTokenNotYetValid = "token_not_yet_valid"
+	ErrCodeInvalidIssuer    = "invalid_issuer"
+	ErrCodeInvalidAudience  = "invalid_audience"
+	ErrCodeMissingClaim     = "missing_claim"
+	ErrCodeLifetimeExceeded = "token_lifetime_exceeded"
+	ErrCodeInvalidToken     = "invalid_token"
+)
+
+// TokenError is a token rejection with a machine-readable code
+type TokenError struct {
+	Code string
+	Err  error
+}
+
+func (e *TokenError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TokenError) Unwrap() error {
+	return e.Err
+}
+
+// TokenErrorCode returns the code of a rejection, or ErrCodeInvalidToken
+// for errors that don't carry one
+func TokenErrorCode(err error) string {
+	var tokenErr *TokenError
+	if errors.As(err, &tokenErr) {
+		return tokenErr.Code
+	}
+	return ErrCodeInvalidToken
+}
+
+// TokenPolicy is what a token must satisfy besides a valid signature. The
+// zero value only checks exp and nbf when they are present.
+type TokenPolicy struct {
+	// Issuer, when set, must equal the iss claim
+	Issuer string
+	// Audience, when set, must contain one of the aud claim values
+	Audience []string
+	// Leeway allows for clock skew when checking exp, nbf and iat
+	Leeway time.Duration
+	// RequiredClaims must be present and not empty
+	RequiredClaims []string
+	// MaxLifetime, when set, requires exp and bounds how far after iat (or
+	// now, without iat) it may be
+	MaxLifetime time.Duration
+}
+
+// parserOptions returns the checks the JWT parser performs itself
+func (p TokenPolicy) parserOptions() []jwt.ParserOption {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(supportedAlgorithms),
+		jwt.WithLeeway(p.Leeway),
+		jwt.WithIssuedAt(),
+	}
+	if p.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(p.Issuer))
+	}
+	if len(p.Audience) > 0 {
+		opts = append(opts, jwt.WithAudience(p.Audience...))
+	}
+	if p.MaxLifetime > 0 {
+		opts = append(opts, jwt.WithExpirationRequired())
+	}
+	return opts
+}
+
+// check applies the rules the parser doesn't know about
+func (p TokenPolicy) check(claims jwt.MapClaims) error {
+	for _, name := range p.RequiredClaims {
+		if value, ok := claims[name]; !ok || value == nil || value == "" {
+			return &TokenError{Code: ErrCodeMissingClaim, Err: fmt.Errorf("token is missing required claim %q", name)}
+		}
+	}
+
+	if p.MaxLifetime > 0 {
+		exp, err := claims.GetExpirationTime()
+		if err != nil || exp == nil {
+			return &TokenError{Code: ErrCodeMissingClaim, Err: fmt.Errorf("token is missing required claim \"exp\"")}
+		}
+		start := time.Now()
+		if iat, err := claims.GetIssuedAt(); err == nil && iat != nil {
+			start = iat.Time
+		}
+		if lifetime := exp.Sub(start); lifetime > p.MaxLifetime+p.Leeway {
+			return &TokenError{Code: ErrCodeLifetimeExceeded, Err: fmt.Errorf("token lifetime %s exceeds %s", lifetime.Round(time.Second), p.MaxLifetime)}
+		}
+	}
+
+	return nil
+}
+
+// classifyParseError assigns a code to an error from the JWT parser. When a
+// token fails several checks, the first code in this order wins.
+func classifyParseError(err error) *TokenError {
+	codes := []struct {
+		err  error
+		code string
+	}{
+		{jwt.ErrTokenMalformed, ErrCodeMalformedToken},
+		{jwt.ErrTokenUnverifiable, ErrCodeInvalidSignature},
+		{jwt.ErrTokenSignatureInvalid, ErrCodeInvalidSignature},
+		{jwt.ErrTokenExpired, ErrCodeTokenExpired},
+		{jwt.ErrTokenNotValidYet, ErrCodeTokenNotYetValid},
+		{jwt.ErrTokenUsedBeforeIssued, ErrCodeTokenNotYetValid},
+		{jwt.ErrTokenRequiredClaimMissing, ErrCodeMissingClaim},
+		{jwt.ErrTokenInvalidIssuer, ErrCodeInvalidIssuer},
+		{jwt.ErrTokenInvalidAudience, ErrCodeInvalidAudience},
+	}
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return &TokenError{Code: c.code, Err: err}
+		}
+	}
+	return &TokenError{Code: ErrCodeInvalidToken, Err: err}
+}
@@ -0,0 +1,129 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestValidateTokenWithPolicy(t *testing.T) {
+	privKey, pubKey, _ := generateTestKeyPair()
+	otherKey, _, _ := generateTestKeyPair()
+	key, _ := NewPublicKey(pubKey)
+	pkm := &PublicKeyManager{Keys: []*PublicKey{key}}
+
+	policy := TokenPolicy{
+		Issuer:         "https://idp.example.com/",
+		Audience:       []string{"simulacrum"},
+		Leeway:         30 * time.Second,
+		RequiredClaims: []string{"sub"},
+		MaxLifetime:    time.Hour,
+	}
+	now := time.Now()
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss": "https://idp.example.com/",
+			"aud": []string{"reporting", "simulacrum"},
+			"sub": "user123",
+			"iat": now.Unix(),
+			"exp": now.Add(30 * time.Minute).Unix(),
+		}
+	}
+	with := func(name string, value any) jwt.MapClaims {
+		claims := valid()
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		signer any
+		code   string
+	}{
+		{"valid", valid(), privKey, ""},
+		{"within leeway", with("exp", now.Add(-10*time.Second).Unix()), privKey, ""},
+		{"wrong key", valid(), otherKey, ErrCodeInvalidSignature},
+		{"expired", with("exp", now.Add(-time.Minute).Unix()), privKey, ErrCodeTokenExpired},
+		{"not yet valid", with("nbf", now.Add(time.Minute).Unix()), privKey, ErrCodeTokenNotYetValid},
+		{"issued in the future", with("iat", now.Add(time.Minute).Unix()), privKey, ErrCodeTokenNotYetValid},
+		{"wrong issuer", with("iss", "https://evil.example.com/"), privKey, ErrCodeInvalidIssuer},
+		{"wrong audience", with("aud", "reporting"), privKey, ErrCodeInvalidAudience},
+		{"missing audience", with("aud", nil), privKey, ErrCodeMissingClaim},
+		{"missing subject", with("sub", nil), privKey, ErrCodeMissingClaim},
+		{"empty subject", with("sub", ""), privKey, ErrCodeMissingClaim},
+		{"no expiry", with("exp", nil), privKey, ErrCodeMissingClaim},
+		{"lifetime too long", with("exp", now.Add(2*time.Hour).Unix()), privKey, ErrCodeLifetimeExceeded},
+	}
+	for _, tt := range tests {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodRS256, tt.claims).SignedString(tt.signer)
+		_, err := pkm.ValidateTokenWithPolicy(token, policy)
+		if tt.code == "" {
+			if err != nil {
+				t.Errorf("%s: expected valid token, got %v", tt.name, err)
+			}
+			continue
+		}
+		if code := TokenErrorCode(err); code != tt.code {
+			t.Errorf("%s: expected code %s, got %s (%v)", tt.name, tt.code, code, err)
+		}
+	}
+
+	_, err := pkm.ValidateTokenWithPolicy("not-a-token", policy)
+	if code := TokenErrorCode(err); code != ErrCodeMalformedToken {
+		t.Errorf("Expected %s for a malformed token, got %s", ErrCodeMalformedToken, code)
+	}
+	if code := TokenErrorCode(errors.New("other")); code != ErrCodeInvalidToken {
+		t.Errorf("Expected %s for an uncoded error, got %s", ErrCodeInvalidToken, code)
+	}
+}
+
+func TestJWTMiddlewareErrorCodes(t *testing.T) {
+	privKey, pubKey, _ := generateTestKeyPair()
+	key, _ := NewPublicKey(pubKey)
+	pkm := &PublicKeyManager{Keys: []*PublicKey{key}}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", JWTMiddleware(pkm), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	expired, _ := createTestToken(privKey, jwt.MapClaims{"sub": "user123", "exp": time.Now().Add(-time.Hour).Unix()})
+	tests := []struct {
+		header string
+		status int
+		code   string
+	}{
+		{"", http.StatusUnauthorized, ErrCodeMissingToken},
+		{"Bearer " + expired, http.StatusUnauthorized, ErrCodeTokenExpired},
+		{"Bearer garbage", http.StatusUnauthorized, ErrCodeMalformedToken},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != tt.status || body["code"] != tt.code {
+			t.Errorf("Expected %d %s, got %d %v", tt.status, tt.code, w.Code, body)
+		}
+		if _, leaked := body["details"]; leaked {
+			t.Errorf("Expected no error details in the response, got %v", body)
+		}
+	}
+}
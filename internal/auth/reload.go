@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeyStore holds the current public keys and swaps them atomically on
+// reload, so tokens are validated against either the old or the new keys
+// but never a mix
+type KeyStore struct {
+	// Policy is checked for every token; set it before the store is used
+	Policy TokenPolicy
+
+	load    func() (*PublicKeyManager, error)
+	current atomic.Pointer[PublicKeyManager]
+}
+
+// NewKeyStore creates a KeyStore whose keys come from load, which must succeed once
+func NewKeyStore(load func() (*PublicKeyManager, error)) (*KeyStore, error) {
+	ks := &KeyStore{load: load}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// NewFileKeyStore creates a KeyStore reading a PEM file of public keys
+func NewFileKeyStore(filepath string) (*KeyStore, error) {
+	return NewKeyStore(func() (*PublicKeyManager, error) {
+		return LoadPublicKeysFromFile(filepath)
+	})
+}
+
+// Reload loads the keys again. On failure the previous keys stay in use.
+func (ks *KeyStore) Reload() error {
+	pkm, err := ks.load()
+	if err != nil {
+		return err
+	}
+	ks.current.Store(pkm)
+	return nil
+}
+
+// Current returns the keys in use
+func (ks *KeyStore) Current() *PublicKeyManager {
+	return ks.current.Load()
+}
+
+// ValidateToken validates a token against the keys in use and the policy
+func (ks *KeyStore) ValidateToken(tokenString string) (jwt.MapClaims, error) {
+	return ks.Current().ValidateTokenWithPolicy(tokenString, ks.Policy)
+}
+
+// RefreshEvery reloads the keys every interval until ctx is done. Failures
+// are logged and leave the previous keys in place.
+func (ks *KeyStore) RefreshEvery(ctx context.Context, name string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ks.Reload(); err != nil {
+				log.Printf("Failed to refresh %s, keeping previous keys: %v", name, err)
+			}
+		}
+	}
+}
+
+// CertStore serves the current server certificate through GetCertificate,
+// so renewed certificates are picked up by new connections without a restart
+type CertStore struct {
+	certFile, keyFile string
+	current           atomic.Pointer[tls.Certificate]
+}
+
+// NewCertStore loads a certificate and key pair
+func NewCertStore(certFile, keyFile string) (*CertStore, error) {
+	cs := &CertStore{certFile: certFile, keyFile: keyFile}
+	if err := cs.Reload(); err != nil {
+		return nil, err
+	}
+	return cs, nil
+}
+
+// Reload loads the certificate again. On failure the previous one stays in use.
+func (cs *CertStore) Reload() error {
+	cert, err := tls.LoadX509KeyPair(cs.certFile, cs.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+	cs.current.Store(&cert)
+	return nil
+}
+
+// GetCertificate implements tls.Config.GetCertificate
+func (cs *CertStore) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return cs.current.Load(), nil
+}
+
+// Files returns the certificate and key paths
+func (cs *CertStore) Files() []string {
+	return []string{cs.certFile, cs.keyFile}
+}
+
+// FileWatcher reloads material when the files it was read from change, or
+// on demand, e.g. on SIGHUP. Failures are logged and leave the previous
+// material in place.
+type FileWatcher struct {
+	mu      sync.Mutex
+	watches []*fileWatch
+}
+
+type fileWatch struct {
+	name   string
+	reload func() error
+	files  []string
+	stamps []fileStamp
+}
+
+// fileStamp is what a file looked like when it was last checked
+type fileStamp struct {
+	modTime int64
+	size    int64
+}
+
+// Watch registers reload to be called when any of files changes
+func (fw *FileWatcher) Watch(name string, reload func() error, files ...string) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	w := &fileWatch{name: name, reload: reload, files: files}
+	w.stamps = w.stat()
+	fw.watches = append(fw.watches, w)
+}
+
+// ReloadAll reloads everything regardless of file changes
+func (fw *FileWatcher) ReloadAll() {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	for _, w := range fw.watches {
+		w.stamps = w.stat()
+		w.run()
+	}
+}
+
+// Check reloads whatever has changed since the last check
+func (fw *FileWatcher) Check() {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	for _, w := range fw.watches {
+		stamps := w.stat()
+		if slices.Equal(stamps, w.stamps) {
+			continue
+		}
+		// Remember the new state even on failure, so a half-written
+		// file is retried on its next change rather than every tick
+		w.stamps = stamps
+		w.run()
+	}
+}
+
+// Run calls Check every interval until ctx is done
+func (fw *FileWatcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fw.Check()
+		}
+	}
+}
+
+func (w *fileWatch) run() {
+	if err := w.reload(); err != nil {
+		log.Printf("Failed to reload %s, keeping previous: %v", w.name, err)
+		return
+	}
+	log.Printf("Reloaded %s", w.name)
+}
+
+// stat returns the current stamp of every file; missing files get a zero stamp
+func (w *fileWatch) stat() []fileStamp {
+	stamps := make([]fileStamp, len(w.files))
+	for i, file := range w.files {
+		if info, err := os.Stat(file); err == nil {
+			stamps[i] = fileStamp{modTime: info.ModTime().UnixNano(), size: info.Size()}
+		}
+	}
+	return stamps
+}
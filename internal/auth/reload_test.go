@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestKeyStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	keysFile := filepath.Join(dir, "public_keys.pem")
+
+	oldPriv, oldPub, _ := generateTestKeyPair()
+	newPriv, newPub, _ := generateTestKeyPair()
+	if err := writePublicKeyToFile(oldPub, keysFile); err != nil {
+		t.Fatalf("Failed to write keys: %v", err)
+	}
+
+	ks, err := NewFileKeyStore(keysFile)
+	if err != nil {
+		t.Fatalf("Failed to load key store: %v", err)
+	}
+
+	claims := jwt.MapClaims{"sub": "user123", "exp": time.Now().Add(time.Hour).Unix()}
+	oldToken, _ := createTestToken(oldPriv, claims)
+	newToken, _ := createTestToken(newPriv, claims)
+
+	if _, err := ks.ValidateToken(oldToken); err != nil {
+		t.Errorf("Expected old token to validate: %v", err)
+	}
+	if _, err := ks.ValidateToken(newToken); err == nil {
+		t.Error("Expected new token to be rejected before reload")
+	}
+
+	// Rotate the key
+	if err := writePublicKeyToFile(newPub, keysFile); err != nil {
+		t.Fatalf("Failed to write keys: %v", err)
+	}
+	if err := ks.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if _, err := ks.ValidateToken(newToken); err != nil {
+		t.Errorf("Expected new token to validate after reload: %v", err)
+	}
+	if _, err := ks.ValidateToken(oldToken); err == nil {
+		t.Error("Expected old token to be rejected after reload")
+	}
+
+	// A broken file keeps the last good keys
+	os.WriteFile(keysFile, []byte("garbage"), 0o600)
+	if err := ks.Reload(); err == nil {
+		t.Error("Expected reload of an invalid file to fail")
+	}
+	if _, err := ks.ValidateToken(newToken); err != nil {
+		t.Errorf("Expected previous keys to stay in use: %v", err)
+	}
+}
+
+func TestCertStoreReload(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+
+	certPEM, keyPEM := ca.issue(t, "first", x509.ExtKeyUsageServerAuth)
+	certFile := writeTestFile(t, dir, "server.crt", certPEM)
+	keyFile := writeTestFile(t, dir, "server.key", keyPEM)
+
+	cs, err := NewCertStore(certFile, keyFile)
+	if err != nil {
+		t.Fatalf("Failed to load certificate: %v", err)
+	}
+	if cn := leafCommonName(t, cs); cn != "first" {
+		t.Fatalf("Expected first certificate, got %s", cn)
+	}
+
+	certPEM, keyPEM = ca.issue(t, "renewed", x509.ExtKeyUsageServerAuth)
+	writeTestFile(t, dir, "server.crt", certPEM)
+	writeTestFile(t, dir, "server.key", keyPEM)
+	if err := cs.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if cn := leafCommonName(t, cs); cn != "renewed" {
+		t.Errorf("Expected renewed certificate, got %s", cn)
+	}
+
+	// A certificate that doesn't match its key keeps the last good pair
+	otherCert, _ := ca.issue(t, "mismatched", x509.ExtKeyUsageServerAuth)
+	writeTestFile(t, dir, "server.crt", otherCert)
+	if err := cs.Reload(); err == nil {
+		t.Error("Expected reload of a mismatched pair to fail")
+	}
+	if cn := leafCommonName(t, cs); cn != "renewed" {
+		t.Errorf("Expected renewed certificate to stay in use, got %s", cn)
+	}
+}
+
+func leafCommonName(t *testing.T, cs *CertStore) string {
+	t.Helper()
+	cert, _ := cs.GetCertificate(&tls.ClientHelloInfo{})
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestFileWatcherCheck(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "watched")
+	os.WriteFile(file, []byte("v1"), 0o600)
+
+	var fw FileWatcher
+	reloads := 0
+	fw.Watch("test", func() error { reloads++; return nil }, file)
+
+	fw.Check()
+	if reloads != 0 {
+		t.Fatalf("Expected no reload without changes, got %d", reloads)
+	}
+
+	os.WriteFile(file, []byte("version 2"), 0o600)
+	fw.Check()
+	fw.Check()
+	if reloads != 1 {
+		t.Errorf("Expected one reload after a change, got %d", reloads)
+	}
+
+	fw.ReloadAll()
+	if reloads != 2 {
+		t.Errorf("Expected ReloadAll to reload, got %d", reloads)
+	}
+}
@@ -0,0 +1,139 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestRevocationListCheck(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "revoked.yaml")
+	os.WriteFile(file, []byte("jti: [stolen]\nsub: [contractor]\n"), 0o644)
+
+	rl, err := NewRevocationList(file)
+	if err != nil {
+		t.Fatalf("Failed to load revocation list: %v", err)
+	}
+	if rl.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", rl.Len())
+	}
+
+	tests := []struct {
+		claims  map[string]any
+		revoked bool
+	}{
+		{map[string]any{"jti": "stolen", "sub": "user123"}, true},
+		{map[string]any{"jti": "fresh", "sub": "contractor"}, true},
+		{map[string]any{"jti": "fresh", "sub": "user123"}, false},
+		{map[string]any{"sub": "user123"}, false},
+	}
+	for _, tt := range tests {
+		err := rl.Check(tt.claims)
+		if tt.revoked && TokenErrorCode(err) != ErrCodeTokenRevoked {
+			t.Errorf("Expected %v to be revoked, got %v", tt.claims, err)
+		}
+		if !tt.revoked && err != nil {
+			t.Errorf("Expected %v to pass, got %v", tt.claims, err)
+		}
+	}
+
+	// A broken reload keeps the previous list
+	os.WriteFile(file, []byte("jti: [\"\"]\n"), 0o644)
+	if err := rl.Reload(); err == nil {
+		t.Error("Expected error for empty jti")
+	}
+	if rl.Check(map[string]any{"jti": "stolen"}) == nil {
+		t.Error("Expected previous list to stay in use")
+	}
+
+	os.WriteFile(file, []byte("sub: [user123]\n"), 0o644)
+	if err := rl.Reload(); err != nil {
+		t.Fatalf("Failed to reload revocation list: %v", err)
+	}
+	if rl.Check(map[string]any{"jti": "stolen"}) != nil {
+		t.Error("Expected jti to be reinstated after reload")
+	}
+	if rl.Check(map[string]any{"sub": "user123"}) == nil {
+		t.Error("Expected newly revoked subject to be rejected")
+	}
+}
+
+func TestLoadRevocationListInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"unknown field": "tokens: [a]\n",
+		"empty sub":     "sub: [\"\"]\n",
+		"not yaml":      "jti: [a\n",
+	} {
+		file := filepath.Join(dir, "revoked.yaml")
+		os.WriteFile(file, []byte(content), 0o644)
+		if _, err := NewRevocationList(file); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := NewRevocationList(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Error("Expected error for missing file")
+	}
+
+	empty := filepath.Join(dir, "empty.yaml")
+	os.WriteFile(empty, nil, 0o644)
+	rl, err := NewRevocationList(empty)
+	if err != nil || rl.Len() != 0 {
+		t.Errorf("Expected empty list, got %v", err)
+	}
+}
+
+func TestRevocationMiddleware(t *testing.T) {
+	privKey, pubKey, _ := generateTestKeyPair()
+	otherKey, _, _ := generateTestKeyPair()
+	key, _ := NewPublicKey(pubKey)
+	pkm := &PublicKeyManager{Keys: []*PublicKey{key}}
+
+	file := filepath.Join(t.TempDir(), "revoked.yaml")
+	os.WriteFile(file, []byte("jti: [stolen]\n"), 0o644)
+	rl, err := NewRevocationList(file)
+	if err != nil {
+		t.Fatalf("Failed to load revocation list: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/", JWTMiddleware(pkm), RevocationMiddleware(rl), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	exp := time.Now().Add(time.Hour).Unix()
+	stolen, _ := createTestToken(privKey, jwt.MapClaims{"jti": "stolen", "exp": exp})
+	forged, _ := createTestToken(otherKey, jwt.MapClaims{"jti": "stolen", "exp": exp})
+	fresh, _ := createTestToken(privKey, jwt.MapClaims{"jti": "fresh", "exp": exp})
+	tests := []struct {
+		token  string
+		status int
+		code   string
+	}{
+		{stolen, http.StatusUnauthorized, ErrCodeTokenRevoked},
+		// The signature is checked first
+		{forged, http.StatusUnauthorized, ErrCodeInvalidSignature},
+		{fresh, http.StatusNoContent, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var body map[string]any
+		json.Unmarshal(w.Body.Bytes(), &body)
+		if w.Code != tt.status || (tt.code != "" && body["code"] != tt.code) {
+			t.Errorf("Expected %d %s, got %d %v", tt.status, tt.code, w.Code, body)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseTLSVersion converts a version such as "1.2" to its tls constant
+func ParseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "1.0":
+		return tls.VersionTLS10, nil
+	case "1.1":
+		return tls.VersionTLS11, nil
+	case "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS version %q, expected 1.0, 1.1, 1.2 or 1.3", version)
+	}
+}
+
+// ServerTLSOptions are the files and settings of the server TLS configuration
+type ServerTLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// CACertFile is the CA that must sign client certificates
+	CACertFile        string
+	RequireClientCert bool
+	// MinVersion is a version such as "1.2", see ParseTLSVersion
+	MinVersion string
+}
+
+// NewServerTLSConfig builds the server TLS configuration. The server
+// certificate is served from the returned CertStore so it can be reloaded.
+// When client certificates are required they must be signed by the
+// configured CA.
+func NewServerTLSConfig(cfg ServerTLSOptions) (*tls.Config, *CertStore, error) {
+	minVersion, err := ParseTLSVersion(cfg.MinVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certs, err := NewCertStore(cfg.CertFile, cfg.KeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:     minVersion,
+		GetCertificate: certs.GetCertificate,
+	}
+
+	if cfg.RequireClientCert {
+		if cfg.CACertFile == "" {
+			return nil, nil, fmt.Errorf("a CA certificate is required to verify client certificates")
+		}
+
+		caCertPEM, err := os.ReadFile(cfg.CACertFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read CA certificate file: %w", err)
+		}
+
+		caCertPool := x509.NewCertPool()
+		if !caCertPool.AppendCertsFromPEM(caCertPEM) {
+			return nil, nil, fmt.Errorf("failed to parse CA certificate")
+		}
+
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		tlsConfig.ClientCAs = caCertPool
+	}
+
+	return tlsConfig, certs, nil
+}
+
+// ClientIdentity identifies the caller by its verified client certificate
+type ClientIdentity struct {
+	CommonName     string
+	DNSNames       []string
+	EmailAddresses []string
+	URIs           []string
+}
+
+// ClientIdentityFromCert extracts the subject CN and SANs of a certificate
+func ClientIdentityFromCert(cert *x509.Certificate) *ClientIdentity {
+	id := &ClientIdentity{
+		CommonName:     cert.Subject.CommonName,
+		DNSNames:       cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+	}
+	for _, uri := range cert.URIs {
+		id.URIs = append(id.URIs, uri.String())
+	}
+	return id
+}
+
+// Names returns the common name followed by every SAN
+func (id *ClientIdentity) Names() []string {
+	var names []string
+	if id.CommonName != "" {
+		names = append(names, id.CommonName)
+	}
+	names = append(names, id.DNSNames...)
+	names = append(names, id.EmailAddresses...)
+	return append(names, id.URIs...)
+}
+
+// String returns the common name, or the first SAN if there is none
+func (id *ClientIdentity) String() string {
+	if names := id.Names(); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// ClientCertMiddleware exposes the verified client certificate identity to
+// handlers, see GetClientIdentity. When allowed is not empty, only callers
+// whose common name or any SAN is listed are let through.
+func ClientCertMiddleware(allowed []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var id *ClientIdentity
+		if tlsState := c.Request.TLS; tlsState != nil && len(tlsState.VerifiedChains) > 0 {
+			id = ClientIdentityFromCert(tlsState.VerifiedChains[0][0])
+			c.Set("client_identity", id)
+		}
+
+		if len(allowed) > 0 {
+			if id == nil {
+				c.JSON(http.StatusForbidden, gin.H{"error": "client certificate required"})
+				c.Abort()
+				return
+			}
+			if !slices.ContainsFunc(id.Names(), func(name string) bool { return slices.Contains(allowed, name) }) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "client certificate not allowed"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// GetClientIdentity retrieves the client certificate identity from the context
+func GetClientIdentity(c *gin.Context) (*ClientIdentity, bool) {
+	value, exists := c.Get("client_identity")
+	if !exists {
+		return nil, false
+	}
+
+	id, ok := value.(*ClientIdentity)
+	return id, ok
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// testCA is a throwaway certificate authority for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a leaf certificate, returning PEM-encoded certificate and key
+func (ca *testCA) issue(t *testing.T, cn string, usage x509.ExtKeyUsage, dnsNames ...string) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		DNSNames:     dnsNames,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeTestFile(t *testing.T, dir, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// startMTLSServer serves a handler echoing the client identity over mTLS
+func startMTLSServer(t *testing.T, ca *testCA, allowed []string) *httptest.Server {
+	t.Helper()
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth, "localhost")
+
+	tlsConfig, _, err := NewServerTLSConfig(ServerTLSOptions{
+		CertFile:          writeTestFile(t, dir, "server.crt", certPEM),
+		KeyFile:           writeTestFile(t, dir, "server.key", keyPEM),
+		CACertFile:        writeTestFile(t, dir, "ca.crt", ca.pem),
+		RequireClientCert: true,
+		MinVersion:        "1.3",
+	})
+	if err != nil {
+		t.Fatalf("Failed to build TLS config: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/whoami", ClientCertMiddleware(allowed), func(c *gin.Context) {
+		id, _ := GetClientIdentity(c)
+		c.JSON(http.StatusOK, gin.H{"cn": id.CommonName, "dns": id.DNSNames})
+	})
+
+	srv := httptest.NewUnstartedServer(router)
+	srv.TLS = tlsConfig
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newMTLSClient returns a client trusting ca and presenting the given certificate
+func newMTLSClient(t *testing.T, ca *testCA, certPEM, keyPEM []byte) *http.Client {
+	t.Helper()
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	// Send SNI so the server picks its certificate with GetCertificate
+	tlsConfig := &tls.Config{RootCAs: roots, ServerName: "localhost"}
+	if certPEM != nil {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			t.Fatalf("Failed to load client certificate: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+}
+
+func TestMTLSClientIdentity(t *testing.T) {
+	ca := newTestCA(t)
+	srv := startMTLSServer(t, ca, nil)
+
+	certPEM, keyPEM := ca.issue(t, "billing-service", x509.ExtKeyUsageClientAuth, "billing.internal")
+	resp, err := newMTLSClient(t, ca, certPEM, keyPEM).Get(srv.URL + "/whoami")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", resp.StatusCode)
+	}
+	var body struct {
+		CN  string   `json:"cn"`
+		DNS []string `json:"dns"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	if body.CN != "billing-service" || len(body.DNS) != 1 || body.DNS[0] != "billing.internal" {
+		t.Errorf("Expected identity billing-service/billing.internal, got %+v", body)
+	}
+}
+
+func TestMTLSRejectsMissingOrUntrustedCert(t *testing.T) {
+	ca := newTestCA(t)
+	srv := startMTLSServer(t, ca, nil)
+
+	if _, err := newMTLSClient(t, ca, nil, nil).Get(srv.URL + "/whoami"); err == nil {
+		t.Error("Expected request without a client certificate to fail")
+	}
+
+	otherCA := newTestCA(t)
+	certPEM, keyPEM := otherCA.issue(t, "intruder", x509.ExtKeyUsageClientAuth)
+	if _, err := newMTLSClient(t, ca, certPEM, keyPEM).Get(srv.URL + "/whoami"); err == nil {
+		t.Error("Expected request with an untrusted client certificate to fail")
+	}
+}
+
+func TestMTLSAllowedClients(t *testing.T) {
+	ca := newTestCA(t)
+	srv := startMTLSServer(t, ca, []string{"billing.internal"})
+
+	tests := []struct {
+		cn, dns string
+		want    int
+	}{
+		{"billing-service", "billing.internal", http.StatusOK},
+		{"reporting-service", "reporting.internal", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		certPEM, keyPEM := ca.issue(t, tt.cn, x509.ExtKeyUsageClientAuth, tt.dns)
+		resp, err := newMTLSClient(t, ca, certPEM, keyPEM).Get(srv.URL + "/whoami")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: expected status %d, got %d", tt.cn, tt.want, resp.StatusCode)
+		}
+	}
+}
+
+func TestNewServerTLSConfigInvalid(t *testing.T) {
+	ca := newTestCA(t)
+	dir := t.TempDir()
+	certPEM, keyPEM := ca.issue(t, "localhost", x509.ExtKeyUsageServerAuth)
+	base := ServerTLSOptions{
+		CertFile:   writeTestFile(t, dir, "server.crt", certPEM),
+		KeyFile:    writeTestFile(t, dir, "server.key", keyPEM),
+		CACertFile: writeTestFile(t, dir, "ca.crt", ca.pem),
+		MinVersion: "1.2",
+	}
+
+	if _, _, err := NewServerTLSConfig(base); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	badVersion := base
+	badVersion.MinVersion = "1.4"
+	if _, _, err := NewServerTLSConfig(badVersion); err == nil {
+		t.Error("Expected error for unsupported TLS version")
+	}
+
+	noCA := base
+	noCA.RequireClientCert = true
+	noCA.CACertFile = ""
+	if _, _, err := NewServerTLSConfig(noCA); err == nil {
+		t.Error("Expected error when client certs are required without a CA")
+	}
+
+	badCA := base
+	badCA.RequireClientCert = true
+	badCA.CACertFile = writeTestFile(t, dir, "bad.crt", []byte("not a certificate"))
+	if _, _, err := NewServerTLSConfig(badCA); err == nil {
+		t.Error("Expected error for an invalid CA certificate")
+	}
+}
@@ -1,25 +1,52 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Server ServerConfig
-	Auth   AuthConfig
-	TLS    TLSConfig
+	Server  ServerConfig
+	Auth    AuthConfig
+	TLS     TLSConfig
+	Obscure ObscureConfig
 }
 
 type ServerConfig struct {
 	Port        string
 	Environment string
 	GinMode     string
+	// ReloadInterval is how often key and certificate files are checked for
+	// changes; zero disables polling, SIGHUP always reloads
+	ReloadInterval time.Duration
+	// AuditLog is where audit records go: "stdout", a file path, or "off"
+	AuditLog string
 }
 
 type AuthConfig struct {
 	PublicKeysFile string
 	Enabled        bool
+	// JWKS is a JWKS document URL or file path; when set it replaces PublicKeysFile
+	JWKS string
+	// JWKSRefreshInterval is how often a JWKS URL is fetched again
+	JWKSRefreshInterval time.Duration
+	// JWKSAllowInsecure permits a plain http JWKS URL, for local testing only
+	JWKSAllowInsecure bool
+	// Issuer, when set, is the required iss claim
+	Issuer string
+	// Audience, when set, lists accepted aud claim values
+	Audience []string
+	// Leeway allows for clock skew when checking exp, nbf and iat
+	Leeway time.Duration
+	// RequiredClaims must be present in every token
+	RequiredClaims []string
+	// MaxTokenLifetime bounds exp - iat; zero allows any lifetime
+	MaxTokenLifetime time.Duration
+	// RevocationFile is a YAML file of revoked token ids and subjects
+	RevocationFile string
 }
 
 type TLSConfig struct {
@@ -29,6 +56,42 @@ type TLSConfig struct {
 	CACertFile        string
 	RequireClientCert bool
 	MinVersion        string
+	// AllowedClients restricts callers to client certificates whose common
+	// name or any SAN is listed; empty allows any certificate signed by the CA
+	AllowedClients []string
+}
+
+// ObscureConfig controls how values are obscured
+type ObscureConfig struct {
+	// SecretKey keys the deterministic hash (hex or raw string)
+	SecretKey string
+	// SecretKeyFile is a file containing the secret key, takes precedence over SecretKey
+	SecretKeyFile string
+	// IDKeys are the object keys that identify a record, checked in order
+	IDKeys []string
+	// RulesFile is a YAML rules file replacing the built-in field rules
+	RulesFile string
+	// Profiles maps rule profile names to rules files; when set, tokens must
+	// grant a profile with an obscure:<name> scope or role
+	Profiles map[string]string
+	// DefaultProfile is used when a request doesn't name a profile
+	DefaultProfile string
+	// TenantKeysFile is a YAML file of per-tenant secrets; when set, every
+	// caller must belong to a tenant
+	TenantKeysFile string
+	// TenantClaim is the JWT claim naming the caller's tenant
+	TenantClaim string
+	// Persona generates the name, email and gender fields of a record from
+	// one fake person
+	Persona bool
+	// Locale generates names and addresses in the conventions of a locale
+	// such as de_DE, unless a record's country selects another one; "auto"
+	// only follows country fields
+	Locale string
+	// Dictionaries maps dictionary names to word list files loaded at
+	// startup, replacing built-in lists such as last_names or adding lists
+	// for word rules
+	Dictionaries map[string]string
 }
 
 func LoadConfig() (*Config, error) {
@@ -44,9 +107,60 @@ func LoadConfig() (*Config, error) {
 	if v := os.Getenv("GIN_MODE"); v != "" {
 		cfg.Server.GinMode = v
 	}
+	if v := os.Getenv("SERVER_RELOAD_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval < 0 {
+			return nil, fmt.Errorf("invalid SERVER_RELOAD_INTERVAL %q: expected a duration such as 30s", v)
+		}
+		cfg.Server.ReloadInterval = interval
+	}
+	if v := os.Getenv("SERVER_AUDIT_LOG"); v != "" {
+		cfg.Server.AuditLog = v
+	}
 	if v := os.Getenv("AUTH_PUBLIC_KEYS_FILE"); v != "" {
 		cfg.Auth.PublicKeysFile = v
 	}
+	if v := os.Getenv("AUTH_JWKS"); v != "" {
+		cfg.Auth.JWKS = v
+	}
+	if v := os.Getenv("AUTH_JWKS_REFRESH_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil || interval <= 0 {
+			return nil, fmt.Errorf("invalid AUTH_JWKS_REFRESH_INTERVAL %q: expected a duration such as 15m", v)
+		}
+		cfg.Auth.JWKSRefreshInterval = interval
+	}
+	if v := os.Getenv("AUTH_JWKS_ALLOW_INSECURE"); v != "" {
+		if boolVal, err := strconv.ParseBool(v); err == nil {
+			cfg.Auth.JWKSAllowInsecure = boolVal
+		}
+	}
+	if v := os.Getenv("AUTH_ISSUER"); v != "" {
+		cfg.Auth.Issuer = v
+	}
+	if v := os.Getenv("AUTH_AUDIENCE"); v != "" {
+		cfg.Auth.Audience = SplitList(v)
+	}
+	if v := os.Getenv("AUTH_LEEWAY"); v != "" {
+		leeway, err := time.ParseDuration(v)
+		if err != nil || leeway < 0 {
+			return nil, fmt.Errorf("invalid AUTH_LEEWAY %q: expected a duration such as 30s", v)
+		}
+		cfg.Auth.Leeway = leeway
+	}
+	if v := os.Getenv("AUTH_REQUIRED_CLAIMS"); v != "" {
+		cfg.Auth.RequiredClaims = SplitList(v)
+	}
+	if v := os.Getenv("AUTH_MAX_TOKEN_LIFETIME"); v != "" {
+		lifetime, err := time.ParseDuration(v)
+		if err != nil || lifetime < 0 {
+			return nil, fmt.Errorf("invalid AUTH_MAX_TOKEN_LIFETIME %q: expected a duration such as 24h", v)
+		}
+		cfg.Auth.MaxTokenLifetime = lifetime
+	}
+	if v := os.Getenv("AUTH_REVOCATION_FILE"); v != "" {
+		cfg.Auth.RevocationFile = v
+	}
 	if v := os.Getenv("TLS_ENABLED"); v != "" {
 		if boolVal, err := strconv.ParseBool(v); err == nil {
 			cfg.TLS.Enabled = boolVal
@@ -66,6 +180,57 @@ func LoadConfig() (*Config, error) {
 			cfg.TLS.RequireClientCert = boolVal
 		}
 	}
+	if v := os.Getenv("TLS_MIN_VERSION"); v != "" {
+		cfg.TLS.MinVersion = v
+	}
+	if v := os.Getenv("TLS_ALLOWED_CLIENTS"); v != "" {
+		cfg.TLS.AllowedClients = SplitList(v)
+	}
+	if v := os.Getenv("OBSCURE_SECRET_KEY"); v != "" {
+		cfg.Obscure.SecretKey = v
+	}
+	if v := os.Getenv("OBSCURE_SECRET_KEY_FILE"); v != "" {
+		cfg.Obscure.SecretKeyFile = v
+	}
+	if v := os.Getenv("OBSCURE_ID_KEYS"); v != "" {
+		cfg.Obscure.IDKeys = SplitList(v)
+	}
+	if v := os.Getenv("OBSCURE_RULES_FILE"); v != "" {
+		cfg.Obscure.RulesFile = v
+	}
+
+	if v := os.Getenv("OBSCURE_PROFILES"); v != "" {
+		profiles, err := parseNamedFiles(v, "profile")
+		if err != nil {
+			return nil, fmt.Errorf("invalid OBSCURE_PROFILES: %w", err)
+		}
+		cfg.Obscure.Profiles = profiles
+	}
+	if v := os.Getenv("OBSCURE_DEFAULT_PROFILE"); v != "" {
+		cfg.Obscure.DefaultProfile = v
+	}
+
+	if v := os.Getenv("OBSCURE_TENANT_KEYS_FILE"); v != "" {
+		cfg.Obscure.TenantKeysFile = v
+	}
+	if v := os.Getenv("OBSCURE_TENANT_CLAIM"); v != "" {
+		cfg.Obscure.TenantClaim = v
+	}
+	if v := os.Getenv("OBSCURE_PERSONA"); v != "" {
+		if boolVal, err := strconv.ParseBool(v); err == nil {
+			cfg.Obscure.Persona = boolVal
+		}
+	}
+	if v := os.Getenv("OBSCURE_LOCALE"); v != "" {
+		cfg.Obscure.Locale = v
+	}
+	if v := os.Getenv("OBSCURE_DICTIONARIES"); v != "" {
+		dictionaries, err := parseNamedFiles(v, "dictionary")
+		if err != nil {
+			return nil, fmt.Errorf("invalid OBSCURE_DICTIONARIES: %w", err)
+		}
+		cfg.Obscure.Dictionaries = dictionaries
+	}
 
 	if cfg.Server.Port == "" {
 		cfg.Server.Port = "8080"
@@ -80,13 +245,54 @@ func LoadConfig() (*Config, error) {
 			cfg.Server.GinMode = "debug"
 		}
 	}
+	if cfg.Server.AuditLog == "" {
+		cfg.Server.AuditLog = "stdout"
+	}
 	if cfg.Auth.PublicKeysFile == "" {
 		cfg.Auth.PublicKeysFile = "public_keys.pem"
 
 	}
+	if cfg.Auth.JWKSRefreshInterval == 0 {
+		cfg.Auth.JWKSRefreshInterval = 15 * time.Minute
+	}
 	if cfg.TLS.MinVersion == "" {
 		cfg.TLS.MinVersion = "1.2"
 	}
+	if cfg.Obscure.TenantClaim == "" {
+		cfg.Obscure.TenantClaim = "tenant"
+	}
+	if len(cfg.Obscure.IDKeys) == 0 {
+		cfg.Obscure.IDKeys = []string{"id"}
+	}
 
 	return &cfg, nil
 }
+
+// SplitList parses a comma-separated list, trimming items and dropping empty ones
+func SplitList(v string) []string {
+	var items []string
+	for item := range strings.SplitSeq(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseNamedFiles parses comma-separated name=file pairs, such as profiles
+// and their rules files
+func parseNamedFiles(v, what string) (map[string]string, error) {
+	files := make(map[string]string)
+	for _, item := range SplitList(v) {
+		name, file, ok := strings.Cut(item, "=")
+		name, file = strings.TrimSpace(name), strings.TrimSpace(file)
+		if !ok || name == "" || file == "" {
+			return nil, fmt.Errorf("expected name=file, got %q", item)
+		}
+		if _, exists := files[name]; exists {
+			return nil, fmt.Errorf("duplicate %s %q", what, name)
+		}
+		files[name] = file
+	}
+	return files, nil
+}
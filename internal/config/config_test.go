@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadConfigValid(t *testing.T) {
@@ -10,11 +11,34 @@ func TestLoadConfigValid(t *testing.T) {
 	os.Setenv("SERVER_ENVIRONMENT", "production")
 	os.Setenv("GIN_MODE", "release")
 	os.Setenv("AUTH_PUBLIC_KEYS_FILE", "/path/to/keys.pem")
+	os.Setenv("SERVER_RELOAD_INTERVAL", "30s")
+	os.Setenv("SERVER_AUDIT_LOG", "/var/log/simulacrum/audit.log")
+	os.Setenv("AUTH_JWKS", "https://idp.example.com/.well-known/jwks.json")
+	os.Setenv("AUTH_JWKS_REFRESH_INTERVAL", "5m")
+	os.Setenv("AUTH_JWKS_ALLOW_INSECURE", "true")
+	os.Setenv("AUTH_ISSUER", "https://idp.example.com/")
+	os.Setenv("AUTH_AUDIENCE", "simulacrum, simulacrum-staging")
+	os.Setenv("AUTH_LEEWAY", "30s")
+	os.Setenv("AUTH_REQUIRED_CLAIMS", "sub,jti")
+	os.Setenv("AUTH_MAX_TOKEN_LIFETIME", "24h")
+	os.Setenv("AUTH_REVOCATION_FILE", "revoked.yaml")
 	os.Setenv("TLS_ENABLED", "true")
 	os.Setenv("TLS_CERT_FILE", "server.crt")
 	os.Setenv("TLS_KEY_FILE", "server.key")
 	os.Setenv("TLS_CA_CERT_FILE", "ca.crt")
 	os.Setenv("TLS_REQUIRE_CLIENT_CERT", "true")
+	os.Setenv("TLS_MIN_VERSION", "1.3")
+	os.Setenv("TLS_ALLOWED_CLIENTS", "billing-service,spiffe://prod/etl")
+	os.Setenv("OBSCURE_SECRET_KEY_FILE", "secret.key")
+	os.Setenv("OBSCURE_ID_KEYS", "user_id, customer_uuid,")
+	os.Setenv("OBSCURE_RULES_FILE", "rules.yaml")
+	os.Setenv("OBSCURE_PROFILES", "qa=rules.qa.yaml, export = rules.export.yaml")
+	os.Setenv("OBSCURE_DEFAULT_PROFILE", "qa")
+	os.Setenv("OBSCURE_TENANT_KEYS_FILE", "tenants.yaml")
+	os.Setenv("OBSCURE_TENANT_CLAIM", "org")
+	os.Setenv("OBSCURE_PERSONA", "true")
+	os.Setenv("OBSCURE_LOCALE", "de_DE")
+	os.Setenv("OBSCURE_DICTIONARIES", "last_names=surnames.txt, companies=companies.yaml")
 	defer os.Clearenv()
 
 	cfg, err := LoadConfig()
@@ -27,12 +51,78 @@ func TestLoadConfigValid(t *testing.T) {
 	if cfg.Server.Environment != "production" {
 		t.Errorf("Expected production, got %s", cfg.Server.Environment)
 	}
+	if cfg.Server.ReloadInterval != 30*time.Second {
+		t.Errorf("Expected reload interval 30s, got %s", cfg.Server.ReloadInterval)
+	}
+	if cfg.Server.AuditLog != "/var/log/simulacrum/audit.log" {
+		t.Errorf("Expected audit log path, got %s", cfg.Server.AuditLog)
+	}
+	if cfg.Auth.JWKS != "https://idp.example.com/.well-known/jwks.json" {
+		t.Errorf("Expected JWKS URL, got %s", cfg.Auth.JWKS)
+	}
+	if cfg.Auth.JWKSRefreshInterval != 5*time.Minute {
+		t.Errorf("Expected JWKS refresh interval 5m, got %s", cfg.Auth.JWKSRefreshInterval)
+	}
+	if !cfg.Auth.JWKSAllowInsecure {
+		t.Error("Expected insecure JWKS to be allowed")
+	}
+	if cfg.Auth.Issuer != "https://idp.example.com/" {
+		t.Errorf("Expected issuer, got %s", cfg.Auth.Issuer)
+	}
+	if len(cfg.Auth.Audience) != 2 || cfg.Auth.Audience[1] != "simulacrum-staging" {
+		t.Errorf("Expected two audiences, got %v", cfg.Auth.Audience)
+	}
+	if cfg.Auth.Leeway != 30*time.Second {
+		t.Errorf("Expected leeway 30s, got %s", cfg.Auth.Leeway)
+	}
+	if len(cfg.Auth.RequiredClaims) != 2 || cfg.Auth.RequiredClaims[1] != "jti" {
+		t.Errorf("Expected required claims [sub jti], got %v", cfg.Auth.RequiredClaims)
+	}
+	if cfg.Auth.MaxTokenLifetime != 24*time.Hour {
+		t.Errorf("Expected max token lifetime 24h, got %s", cfg.Auth.MaxTokenLifetime)
+	}
+	if cfg.Auth.RevocationFile != "revoked.yaml" {
+		t.Errorf("Expected revocation file revoked.yaml, got %s", cfg.Auth.RevocationFile)
+	}
 	if !cfg.TLS.Enabled {
 		t.Errorf("Expected TLS enabled")
 	}
 	if cfg.TLS.RequireClientCert != true {
 		t.Errorf("Expected RequireClientCert true")
 	}
+	if cfg.TLS.MinVersion != "1.3" {
+		t.Errorf("Expected TLS min version 1.3, got %s", cfg.TLS.MinVersion)
+	}
+	if len(cfg.TLS.AllowedClients) != 2 || cfg.TLS.AllowedClients[1] != "spiffe://prod/etl" {
+		t.Errorf("Expected two allowed clients, got %v", cfg.TLS.AllowedClients)
+	}
+	if cfg.Obscure.SecretKeyFile != "secret.key" {
+		t.Errorf("Expected secret key file secret.key, got %s", cfg.Obscure.SecretKeyFile)
+	}
+	if len(cfg.Obscure.IDKeys) != 2 || cfg.Obscure.IDKeys[0] != "user_id" || cfg.Obscure.IDKeys[1] != "customer_uuid" {
+		t.Errorf("Expected ID keys [user_id customer_uuid], got %v", cfg.Obscure.IDKeys)
+	}
+	if cfg.Obscure.RulesFile != "rules.yaml" {
+		t.Errorf("Expected rules file rules.yaml, got %s", cfg.Obscure.RulesFile)
+	}
+	if len(cfg.Obscure.Profiles) != 2 || cfg.Obscure.Profiles["export"] != "rules.export.yaml" {
+		t.Errorf("Expected qa and export profiles, got %v", cfg.Obscure.Profiles)
+	}
+	if cfg.Obscure.DefaultProfile != "qa" {
+		t.Errorf("Expected default profile qa, got %s", cfg.Obscure.DefaultProfile)
+	}
+	if cfg.Obscure.TenantKeysFile != "tenants.yaml" || cfg.Obscure.TenantClaim != "org" {
+		t.Errorf("Expected tenant keys tenants.yaml with claim org, got %s / %s", cfg.Obscure.TenantKeysFile, cfg.Obscure.TenantClaim)
+	}
+	if !cfg.Obscure.Persona {
+		t.Error("Expected persona mode enabled")
+	}
+	if cfg.Obscure.Locale != "de_DE" {
+		t.Errorf("Expected locale de_DE, got %s", cfg.Obscure.Locale)
+	}
+	if len(cfg.Obscure.Dictionaries) != 2 || cfg.Obscure.Dictionaries["companies"] != "companies.yaml" {
+		t.Errorf("Expected last_names and companies dictionaries, got %v", cfg.Obscure.Dictionaries)
+	}
 }
 
 func TestLoadConfigDefaults(t *testing.T) {
@@ -51,9 +141,24 @@ func TestLoadConfigDefaults(t *testing.T) {
 	if cfg.Server.GinMode != "debug" {
 		t.Errorf("Expected default debug, got %s", cfg.Server.GinMode)
 	}
+	if cfg.Server.AuditLog != "stdout" {
+		t.Errorf("Expected default audit log stdout, got %s", cfg.Server.AuditLog)
+	}
 	if cfg.Auth.PublicKeysFile != "public_keys.pem" {
 		t.Errorf("Expected default public_keys.pem, got %s", cfg.Auth.PublicKeysFile)
 	}
+	if cfg.Auth.JWKSRefreshInterval != 15*time.Minute {
+		t.Errorf("Expected default JWKS refresh interval 15m, got %s", cfg.Auth.JWKSRefreshInterval)
+	}
+	if cfg.TLS.MinVersion != "1.2" {
+		t.Errorf("Expected default TLS min version 1.2, got %s", cfg.TLS.MinVersion)
+	}
+	if len(cfg.Obscure.IDKeys) != 1 || cfg.Obscure.IDKeys[0] != "id" {
+		t.Errorf("Expected default ID keys [id], got %v", cfg.Obscure.IDKeys)
+	}
+	if cfg.Obscure.TenantClaim != "tenant" {
+		t.Errorf("Expected default tenant claim tenant, got %s", cfg.Obscure.TenantClaim)
+	}
 }
 
 func TestLoadConfigProductionMode(t *testing.T) {
@@ -69,3 +174,49 @@ func TestLoadConfigProductionMode(t *testing.T) {
 		t.Errorf("Expected release mode for production, got %s", cfg.Server.GinMode)
 	}
 }
+
+func TestLoadConfigInvalidReloadInterval(t *testing.T) {
+	os.Clearenv()
+	os.Setenv("SERVER_RELOAD_INTERVAL", "often")
+	defer os.Clearenv()
+
+	if _, err := LoadConfig(); err == nil {
+		t.Error("Expected error for invalid reload interval")
+	}
+}
+
+func TestLoadConfigInvalidTokenDurations(t *testing.T) {
+	for _, name := range []string{"AUTH_LEEWAY", "AUTH_MAX_TOKEN_LIFETIME"} {
+		os.Clearenv()
+		os.Setenv(name, "-1m")
+
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("Expected error for negative %s", name)
+		}
+	}
+	os.Clearenv()
+}
+
+func TestLoadConfigInvalidProfiles(t *testing.T) {
+	for _, v := range []string{"qa", "qa=", "=rules.yaml", "qa=a.yaml,qa=b.yaml"} {
+		os.Clearenv()
+		os.Setenv("OBSCURE_PROFILES", v)
+
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("Expected error for OBSCURE_PROFILES=%q", v)
+		}
+	}
+	os.Clearenv()
+}
+
+func TestLoadConfigInvalidDictionaries(t *testing.T) {
+	for _, v := range []string{"companies", "=companies.txt", "companies=a.txt,companies=b.txt"} {
+		os.Clearenv()
+		os.Setenv("OBSCURE_DICTIONARIES", v)
+
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("Expected error for OBSCURE_DICTIONARIES=%q", v)
+		}
+	}
+	os.Clearenv()
+}
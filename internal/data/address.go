@@ -59,6 +59,9 @@ func GenerateDeterministicCity(id, realCity string) string {
 		return ""
 	}
 	hash := hashField(id, "city", realCity)
+	if replacedDictionaries["cities"] {
+		return selectFromList(hash, 1, CityNames)
+	}
 	// Select a region first to ensure consistency
 	region := AddressRegions[int(hash[0])%len(AddressRegions)]
 	city := selectFromList(hash, 1, region.Cities)
@@ -71,6 +74,9 @@ func GenerateDeterministicState(id, realState string) string {
 		return ""
 	}
 	hash := hashField(id, "state", realState)
+	if replacedDictionaries["states"] {
+		return selectFromList(hash, 1, StateProvinces)
+	}
 	// Select a region first to ensure consistency
 	region := AddressRegions[int(hash[0])%len(AddressRegions)]
 	state := selectFromList(hash, 1, region.States)
@@ -103,6 +109,9 @@ func GenerateDeterministicCountry(id, realCountry string) string {
 		return ""
 	}
 	hash := hashField(id, "country", realCountry)
+	if replacedDictionaries["countries"] {
+		return selectFromList(hash, 1, CountryNames)
+	}
 	// Select a region and return its country for consistency
 	region := AddressRegions[int(hash[0])%len(AddressRegions)]
 	return region.Country
@@ -0,0 +1,215 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// The GenerateBijective* functions encrypt a value rather than pick a fake
+// for it, so distinct real values always get distinct fakes of the same
+// shape, which keeps unique constraints intact when obscured data is loaded
+// into a database. Unlike the other generators they don't hash the whole
+// value into a few bytes, so they should be seeded with an id that is the
+// same for every record, or fakes of different records may still collide.
+
+// fpeRounds is the number of Feistel rounds, as in FF1
+const fpeRounds = 10
+
+// fpePermute encrypts numerals, x[i] being below radices[i], with a Feistel
+// network in the style of FF1 (NIST SP 800-38G) generalised to mixed radices,
+// using HMAC-SHA256 keyed with the hash key as the round function. Each round
+// adds a function of one half to the other, so every numeral depends on all
+// others. It permutes the numeral strings of each radix sequence, so distinct
+// inputs give distinct outputs.
+func fpePermute(tweak string, radices []int, x []byte) []byte {
+	u := len(x) / 2
+	a, b := slices.Clone(x[:u]), slices.Clone(x[u:])
+	ra, rb := radices[:u], radices[u:]
+	for i := range fpeRounds {
+		mac := hmac.New(sha256.New, currentHashKey())
+		fmt.Fprintf(mac, "fpe:%s:%v:%d:", tweak, radices, i)
+		mac.Write(b)
+		c := new(big.Int).SetBytes(mac.Sum(nil))
+		c.Add(c, numeralValue(a, ra))
+		c.Mod(c, radixProduct(ra))
+		a, b, ra, rb = b, numeralString(c, ra), rb, ra
+	}
+	return append(a, b...)
+}
+
+// numeralValue reads numerals as a big-endian number, x[i] in radix radices[i]
+func numeralValue(x []byte, radices []int) *big.Int {
+	v := new(big.Int)
+	for i, d := range x {
+		v.Mul(v, big.NewInt(int64(radices[i])))
+		v.Add(v, big.NewInt(int64(d)))
+	}
+	return v
+}
+
+// numeralString writes v as big-endian numerals, the i-th in radix radices[i]
+func numeralString(v *big.Int, radices []int) []byte {
+	out := make([]byte, len(radices))
+	v = new(big.Int).Set(v)
+	d := new(big.Int)
+	for i := len(radices) - 1; i >= 0; i-- {
+		v.DivMod(v, big.NewInt(int64(radices[i])), d)
+		out[i] = byte(d.Int64())
+	}
+	return out
+}
+
+// radixProduct returns the number of numeral strings with the given radices
+func radixProduct(radices []int) *big.Int {
+	p := big.NewInt(1)
+	for _, r := range radices {
+		p.Mul(p, big.NewInt(int64(r)))
+	}
+	return p
+}
+
+// fpeClasses are the character classes that are encrypted
+var fpeClasses = []struct {
+	first byte
+	radix int
+}{{'0', 10}, {'A', 26}, {'a', 26}}
+
+// encryptShape encrypts the ASCII digits, upper case and lower case letters
+// of b together as one mixed-radix numeral string, each character staying in
+// its class, and keeps all other bytes in place. As the whole string is
+// permuted, so are the values of each shape, and values sharing their letters
+// or their digits don't share them in their fakes.
+func encryptShape(tweak string, b []byte) []byte {
+	out := slices.Clone(b)
+	var pos, radices []int
+	var firsts, x []byte
+	for i, c := range out {
+		for _, class := range fpeClasses {
+			if c >= class.first && c < class.first+byte(class.radix) {
+				pos = append(pos, i)
+				radices = append(radices, class.radix)
+				firsts = append(firsts, class.first)
+				x = append(x, c-class.first)
+			}
+		}
+	}
+	if len(x) == 0 {
+		return out
+	}
+	for i, d := range fpePermute(tweak, radices, x) {
+		out[pos[i]] = firsts[i] + d
+	}
+	return out
+}
+
+// cycleWalk encrypts b until the result is valid if b is, or invalid if b
+// isn't. The walk ends at the latest on b itself, and maps the valid and the
+// invalid values of each shape onto themselves, so fakes of valid values are
+// valid and still never collide.
+func cycleWalk(tweak string, b []byte, valid func([]byte) bool) []byte {
+	want := valid(b)
+	out := encryptShape(tweak, b)
+	for valid(out) != want {
+		out = encryptShape(tweak, out)
+	}
+	return out
+}
+
+// GenerateBijective encrypts a value of any format, keeping its separators,
+// length and digit and letter positions, e.g. a passport or account number
+func GenerateBijective(id, fieldType, realValue string) string {
+	if realValue == "" {
+		return ""
+	}
+	return string(encryptShape(id+":"+fieldType, []byte(realValue)))
+}
+
+// GenerateBijectiveEmail encrypts an email address, keeping its separators,
+// length and top-level domain, e.g. "jane.doe@corp.com" gives
+// "qmvt.hzk@xlpd.com". Addresses are lower-cased first, as those differing
+// only in case reach the same mailbox.
+func GenerateBijectiveEmail(id, realEmail string) string {
+	if realEmail == "" {
+		return ""
+	}
+	email := strings.ToLower(realEmail)
+	keep := len(email)
+	if at, dot := strings.LastIndex(email, "@"), strings.LastIndex(email, "."); at >= 0 && dot > at {
+		keep = dot
+	}
+	return string(encryptShape(id+":email", []byte(email[:keep]))) + email[keep:]
+}
+
+// GenerateBijectivePhone encrypts a phone number, keeping its country code,
+// separators and number of digits
+func GenerateBijectivePhone(id, realPhone string) string {
+	if realPhone == "" {
+		return ""
+	}
+	from := countryCodeLength(realPhone)
+	return realPhone[:from] + string(encryptShape(id+":phone", []byte(realPhone[from:])))
+}
+
+// GenerateBijectiveSSN encrypts an SSN, keeping its separators. Valid SSNs,
+// see validSSNDigits, give valid SSNs.
+func GenerateBijectiveSSN(id, realSSN string) string {
+	if realSSN == "" {
+		return ""
+	}
+	return string(cycleWalk(id+":ssn", []byte(realSSN), func(b []byte) bool {
+		digits := digitsOf(b)
+		return len(digits) == 9 && validSSNDigits(digits)
+	}))
+}
+
+// GenerateBijectiveCardNumber encrypts a card number, keeping its length,
+// separators and first two digits. If the real number passes the Luhn check,
+// so does the result; if it doesn't, neither does the result.
+func GenerateBijectiveCardNumber(id, realCCNumber string) string {
+	if realCCNumber == "" {
+		return ""
+	}
+	// Keep the network prefix
+	from, kept := 0, 0
+	for from < len(realCCNumber) && kept < 2 {
+		if c := realCCNumber[from]; c >= '0' && c <= '9' {
+			kept++
+		}
+		from++
+	}
+	out := []byte(realCCNumber)
+	tweak := id + ":credit_card_number"
+
+	if digits := digitsOf(out); len(digits) > kept && luhnValid(digits) {
+		// Encrypt everything but the check digit, which follows from the rest
+		last := strings.LastIndexAny(realCCNumber, "0123456789")
+		copy(out[from:last], encryptShape(tweak, out[from:last]))
+		setLuhnCheckDigit(out)
+		return string(out)
+	}
+	copy(out[from:], cycleWalk(tweak, out[from:], func(b []byte) bool {
+		return luhnValid(digitsOf(append([]byte(realCCNumber[:from]), b...)))
+	}))
+	return string(out)
+}
+
+// GenerateBijectiveInteger encrypts the digits of an integer, keeping its
+// sign and number of digits
+func GenerateBijectiveInteger(id string, realValue int64) int64 {
+	out := cycleWalk(id+":integer", strconv.AppendInt(nil, realValue, 10), func(b []byte) bool {
+		digits := strings.TrimPrefix(string(b), "-")
+		// No leading zeros, and no -0
+		if (len(digits) > 1 && digits[0] == '0') || (len(digits) < len(b) && digits == "0") {
+			return false
+		}
+		_, err := strconv.ParseInt(string(b), 10, 64)
+		return err == nil
+	})
+	n, _ := strconv.ParseInt(string(out), 10, 64)
+	return n
+}
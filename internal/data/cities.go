@@ -12,18 +12,18 @@ var CityNames = []string{
 	"Atlanta", "Miami", "Arlington", "New Orleans", "Bakersfield",
 	"Tampa", "Aurora", "Anaheim", "Santa Ana", "Riverside",
 	"Corpus Christi", "Lexington", "Henderson", "Plano", "Stockton",
-	"St. Louis", "Cincinnati", "Irvine", "Pittsburgh", "Arlington",
+	"St. Louis", "Cincinnati", "Irvine", "Pittsburgh",
 	"Chula Vista", "Cleveland", "Garland", "Irving", "Scottsdale",
 	"North Las Vegas", "Winston-Salem", "Glendale", "Chesapeake", "Gilbert",
 	"Laredo", "Madison", "Montgomery", "Lubbock", "Akron",
 	"Augusta", "Vancouver", "Toronto", "Mexico City", "Montreal",
-	"Vancouver", "Mexico City", "Guadalajara", "Cancun", "Monterrey",
+	"Guadalajara", "Cancun", "Monterrey",
 	"Paris", "London", "Berlin", "Madrid", "Rome",
 	"Amsterdam", "Vienna", "Brussels", "Prague", "Budapest",
 	"Warsaw", "Athens", "Helsinki", "Dublin", "Stockholm",
 	"Copenhagen", "Zurich", "Geneva", "Lisbon", "Barcelona",
 	"Munich", "Hamburg", "Cologne", "Frankfurt", "Stuttgart",
-	"Düsseldorf", "Bremen", "Hannover", "Nuremberg", "Munich",
+	"Düsseldorf", "Bremen", "Hannover", "Nuremberg",
 	"Tokyo", "Beijing", "Shanghai", "Hong Kong", "Singapore",
 	"Bangkok", "Seoul", "Manila", "Jakarta", "Bangalore",
 	"Mumbai", "Delhi", "Kolkata", "Chennai", "Hyderabad",
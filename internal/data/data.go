@@ -1,10 +1,10 @@
 package data
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
 	"fmt"
 	"strings"
-
-	"github.com/cespare/xxhash/v2"
 )
 
 type PersonalData struct {
@@ -93,20 +93,25 @@ func ObscureData(real PersonalData) PersonalData {
 	return fake
 }
 
-// hashField generates an xxHash for a field, returning 8 bytes
+// hashField generates an HMAC-SHA256 for a field keyed with the server secret,
+// returning the first 8 bytes
 func hashField(id, fieldType, value string) [8]byte {
-	h := xxhash.Sum64([]byte(id + ":" + fieldType + ":" + value))
+	mac := hmac.New(sha256.New, currentHashKey())
+	mac.Write([]byte(id + ":" + fieldType + ":" + value))
 	var result [8]byte
-	for i := range 8 {
-		result[i] = byte(h >> (8 * i))
-	}
+	copy(result[:], mac.Sum(nil))
 	return result
 }
 
-// selectFromList uses hash bytes to deterministically select from a list
+// selectFromList uses hash bytes to deterministically select from a list.
+// Lists longer than 256 entries take as many following bytes as needed, so
+// every entry of a large dictionary can be selected.
 func selectFromList(hash [8]byte, byteIndex int, list []string) string {
-	idx := int(hash[byteIndex]) % len(list)
-	return list[idx]
+	idx := 0
+	for i, size := 0, len(list); size > 0 && i < 4; i, size = i+1, size>>8 {
+		idx = idx<<8 | int(hash[(byteIndex+i)%8])
+	}
+	return list[idx%len(list)]
 }
 
 // bytesToInt converts two hash bytes to an integer
@@ -118,14 +123,21 @@ func bytesToInt(hash [8]byte, byteIndex int, max int) int {
 	return val
 }
 
+// EmailDomains are the domains used for generated email addresses by default
+var EmailDomains = []string{"example.com", "test.org", "fake.net", "mail.com"}
+
 func GenerateDeterministicEmail(id, realEmail string) string {
+	return GenerateDeterministicEmailWithDomains(id, realEmail, EmailDomains)
+}
+
+// GenerateDeterministicEmailWithDomains generates a deterministic email using one of the given domains
+func GenerateDeterministicEmailWithDomains(id, realEmail string, domains []string) string {
 	if realEmail == "" {
 		return ""
 	}
 	hash := hashField(id, "email", realEmail)
 	first := strings.ToLower(selectFromList(hash, 0, FirstNames))
 	last := strings.ToLower(selectFromList(hash, 1, LastNames))
-	domains := []string{"example.com", "test.org", "fake.net", "mail.com"}
 	domain := selectFromList(hash, 2, domains)
 	return fmt.Sprintf("%s.%s@%s", first, last, domain)
 }
@@ -2,10 +2,22 @@ package data
 
 import (
 	"fmt"
+	"os"
+	"slices"
 	"strings"
 	"testing"
 )
 
+// testHashKey keys the generators for every test in the package
+var testHashKey = []byte(strings.Repeat("t", MinHashKeyLength))
+
+func TestMain(m *testing.M) {
+	if err := SetHashKey(testHashKey); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
 func TestObscureDataDeterministic(t *testing.T) {
 	real := PersonalData{
 		ID:          "user123",
@@ -491,6 +503,132 @@ func TestHashFieldConsistency(t *testing.T) {
 	}
 }
 
+func TestHashFieldKeyed(t *testing.T) {
+	defer SetHashKey(testHashKey)
+
+	if err := SetHashKey([]byte(strings.Repeat("a", MinHashKeyLength))); err != nil {
+		t.Fatalf("Failed to set hash key: %v", err)
+	}
+	keyA := hashField("id1", "field", "value1")
+
+	if err := SetHashKey([]byte(strings.Repeat("b", MinHashKeyLength))); err != nil {
+		t.Fatalf("Failed to set hash key: %v", err)
+	}
+	keyB := hashField("id1", "field", "value1")
+
+	if keyA == keyB {
+		t.Error("Different keys should produce different hashes")
+	}
+	if keyB != hashField("id1", "field", "value1") {
+		t.Error("Same key should produce same hash")
+	}
+}
+
+func TestHashFieldRequiresKey(t *testing.T) {
+	hashKey.Store(nil)
+	defer SetHashKey(testHashKey)
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected generating without a hash key to panic")
+		}
+	}()
+	GenerateDeterministicName("id1", "John Doe")
+}
+
+func TestParseHashKey(t *testing.T) {
+	if key, err := ParseHashKey([]byte(" " + strings.Repeat("ab", MinHashKeyLength) + "\n")); err != nil || len(key) != MinHashKeyLength {
+		t.Errorf("Expected a decoded hex key, got %x, %v", key, err)
+	}
+	if key, err := ParseHashKey([]byte(strings.Repeat("raw secret ", 4))); err != nil || string(key) != strings.TrimSpace(strings.Repeat("raw secret ", 4)) {
+		t.Errorf("Expected a raw key, got %q, %v", key, err)
+	}
+	// A 32-character hex passphrase is only 16 bytes once decoded
+	_, err := ParseHashKey([]byte(strings.Repeat("ab", MinHashKeyLength/2)))
+	if err == nil || !strings.Contains(err.Error(), "decoded as hex") {
+		t.Errorf("Expected the error to mention hex decoding, got %v", err)
+	}
+}
+
+func TestSetHashKeyTooShort(t *testing.T) {
+	if err := SetHashKey([]byte("short")); err == nil {
+		t.Error("Expected error for short hash key")
+	}
+}
+
+func TestLoadHashKeyFromFile(t *testing.T) {
+	dir := t.TempDir()
+
+	hexFile := dir + "/hex.key"
+	os.WriteFile(hexFile, []byte(strings.Repeat("ab", MinHashKeyLength)+"\n"), 0600)
+	key, err := LoadHashKeyFromFile(hexFile)
+	if err != nil {
+		t.Fatalf("Failed to load hex key: %v", err)
+	}
+	if len(key) != MinHashKeyLength || key[0] != 0xab {
+		t.Errorf("Expected hex key to be decoded, got %x", key)
+	}
+
+	shortFile := dir + "/short.key"
+	os.WriteFile(shortFile, []byte("tooshort"), 0600)
+	if _, err := LoadHashKeyFromFile(shortFile); err == nil {
+		t.Error("Expected error for short key file")
+	}
+
+	if _, err := LoadHashKeyFromFile(dir + "/missing.key"); err == nil {
+		t.Error("Expected error for missing key file")
+	}
+}
+
+func TestLoadTenantKeysFromFile(t *testing.T) {
+	dir := t.TempDir()
+	keyA := strings.Repeat("a1", MinHashKeyLength)
+	keyB := strings.Repeat("b2", MinHashKeyLength)
+
+	file := dir + "/tenants.yaml"
+	os.WriteFile(file, []byte("team-a: "+keyA+"\nteam-b: "+keyB+"\n"), 0600)
+	keys, err := LoadTenantKeysFromFile(file)
+	if err != nil {
+		t.Fatalf("Failed to load tenant keys: %v", err)
+	}
+	if len(keys) != 2 || len(keys["team-a"]) != MinHashKeyLength || keys["team-b"][0] != 0xb2 {
+		t.Errorf("Expected two decoded tenant keys, got %x", keys)
+	}
+
+	invalid := map[string]string{
+		"empty":      "",
+		"not a map":  "- team-a\n",
+		"short key":  "team-a: tooshort\n",
+		"shared key": "team-a: " + keyA + "\nteam-b: " + keyA + "\n",
+	}
+	for name, content := range invalid {
+		file := dir + "/" + strings.ReplaceAll(name, " ", "_") + ".yaml"
+		os.WriteFile(file, []byte(content), 0600)
+		if _, err := LoadTenantKeysFromFile(file); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+
+	if _, err := LoadTenantKeysFromFile(dir + "/missing.yaml"); err == nil {
+		t.Error("Expected error for missing tenant keys file")
+	}
+}
+
+func TestScopeID(t *testing.T) {
+	keyA := []byte(strings.Repeat("a", MinHashKeyLength))
+	keyB := []byte(strings.Repeat("b", MinHashKeyLength))
+
+	if ScopeID(keyA, "user123") != ScopeID(keyA, "user123") {
+		t.Error("Expected scoped ids to be deterministic")
+	}
+	if ScopeID(keyA, "user123") == ScopeID(keyB, "user123") {
+		t.Error("Expected different tenants to get different scoped ids")
+	}
+	if ScopeID(keyA, "user123") == ScopeID(keyA, "user456") {
+		t.Error("Expected different records to get different scoped ids")
+	}
+}
+
 func TestSelectFromListBounds(t *testing.T) {
 	lists := [][]string{
 		{"A"},
@@ -1871,10 +2009,11 @@ func TestObscureDataWithBankAccounts(t *testing.T) {
 	real := PersonalData{
 		ID:   "user_bank_001",
 		Name: "David Chen",
+		// Names outside the generated account types, which a fake may repeat
 		BankAccounts: []*BankAccount{
-			{Name: "Checking Account", Amount: "5000.00"},
-			{Name: "Savings Account", Amount: "25000.50"},
-			{Name: "Money Market Account", Amount: "75000.00"},
+			{Name: "Joint Checking", Amount: "5000.00"},
+			{Name: "Emergency Fund", Amount: "25000.50"},
+			{Name: "Vacation Savings", Amount: "75000.00"},
 		},
 	}
 
@@ -2355,3 +2494,561 @@ func isValidLuhn(number string) bool {
 	}
 	return sum%10 == 0
 }
+
+func TestMaskString(t *testing.T) {
+	tests := []struct {
+		value     string
+		keepFirst int
+		keepLast  int
+		want      string
+	}{
+		{"123-45-6789", 0, 4, "***-**-6789"},
+		{"4111111111111111", 1, 4, "4***********1111"},
+		{"john@example.com", 1, 0, "j***@*******.***"},
+		{"12", 0, 4, "**"},
+		{"1234", 0, 4, "****"},
+		{"12-34", 1, 3, "**-**"},
+		{"12345", 1, 3, "1*345"},
+		{"--", 0, 4, "**"},
+		{"", 0, 4, ""},
+	}
+
+	for _, tt := range tests {
+		if got := MaskString(tt.value, tt.keepFirst, tt.keepLast, '*'); got != tt.want {
+			t.Errorf("MaskString(%q, %d, %d): expected %s, got %s", tt.value, tt.keepFirst, tt.keepLast, tt.want, got)
+		}
+	}
+}
+
+func TestGenerateDeterministicToken(t *testing.T) {
+	token1 := GenerateDeterministicToken("user123", "ssn", "123-45-6789")
+	token2 := GenerateDeterministicToken("user123", "ssn", "123-45-6789")
+	token3 := GenerateDeterministicToken("user123", "tax_id", "123-45-6789")
+
+	if token1 != token2 {
+		t.Errorf("Same inputs should produce same token: %s != %s", token1, token2)
+	}
+	if token1 == token3 {
+		t.Errorf("Different field types should produce different tokens: %s == %s", token1, token3)
+	}
+	if len(token1) != 16 {
+		t.Errorf("Token should be 16 hex characters, got: %s", token1)
+	}
+	if GenerateDeterministicToken("user123", "ssn", "") != "" {
+		t.Errorf("Empty input should produce empty token")
+	}
+}
+
+func TestNewPersona(t *testing.T) {
+	p := NewPersona("user123", "name=John Doe", "email=john@example.com")
+	again := NewPersona("user123", "name=John Doe", "email=john@example.com")
+	if *p != *again {
+		t.Errorf("Expected the same persona, got %+v and %+v", p, again)
+	}
+	if other := NewPersona("user123", "name=Jane Roe"); *other == *p {
+		t.Errorf("Expected a different persona for a different person, got %+v", other)
+	}
+
+	for i := range 200 {
+		p := NewPersona(fmt.Sprintf("user%d", i), "name=John Doe")
+		names := FemaleFirstNames
+		if p.Gender == "Male" {
+			names = MaleFirstNames
+		}
+		if !slices.Contains(names, p.FirstName) || !slices.Contains(names, p.MiddleName) {
+			t.Errorf("Expected %s names to match gender %s", p.FirstName, p.Gender)
+		}
+		if p.MiddleName == p.FirstName {
+			t.Errorf("Expected middle name to differ from first name %s", p.FirstName)
+		}
+		if p.Name() != p.FirstName+" "+p.LastName {
+			t.Errorf("Expected name %s %s, got %s", p.FirstName, p.LastName, p.Name())
+		}
+
+		email := p.Email([]string{"corp.test"})
+		want := emailLocalPart(p.FirstName) + "." + emailLocalPart(p.LastName) + "@corp.test"
+		if email != want {
+			t.Errorf("Expected email %s, got %s", want, email)
+		}
+		for _, r := range email {
+			if r > 0x7f {
+				t.Errorf("Expected an ASCII email, got %s", email)
+				break
+			}
+		}
+	}
+}
+
+func TestEmailLocalPart(t *testing.T) {
+	tests := map[string]string{
+		"Smith":        "smith",
+		"Müller-König": "mueller-koenig",
+		"O'Brien":      "obrien",
+		"Sørensen":     "sorensen",
+		"Łukasiewicz":  "lukasiewicz",
+		"Dvořák":       "dvorak",
+	}
+	for name, want := range tests {
+		if got := emailLocalPart(name); got != want {
+			t.Errorf("emailLocalPart(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// shapeOf replaces digits with 9 and letters with A or a
+func shapeOf(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return '9'
+		case r >= 'A' && r <= 'Z':
+			return 'A'
+		case r >= 'a' && r <= 'z':
+			return 'a'
+		}
+		return r
+	}, s)
+}
+
+func TestGenerateFormatPreservingPhone(t *testing.T) {
+	tests := []struct {
+		phone  string
+		prefix string
+	}{
+		{"(212) 555-0199", "("},
+		{"+44 20 7946 0958", "+44 "},
+		{"2125550199", ""},
+		{"+1-555-987-6543", "+1-"},
+		{"+4930123456", "+49"},
+		{"+353851234567", "+353"},
+		{"020 7946 0958", "0"},
+	}
+	for _, tt := range tests {
+		result := GenerateFormatPreservingPhone("user123", tt.phone)
+		if result == tt.phone || shapeOf(result) != shapeOf(tt.phone) {
+			t.Errorf("Expected %s to keep its shape, got %s", tt.phone, result)
+		}
+		if !strings.HasPrefix(result, tt.prefix) {
+			t.Errorf("Expected %s to keep prefix %q, got %s", tt.phone, tt.prefix, result)
+		}
+		if again := GenerateFormatPreservingPhone("user123", tt.phone); again != result {
+			t.Errorf("Expected deterministic results: %s vs %s", result, again)
+		}
+	}
+	if result := GenerateFormatPreservingPhone("user123", ""); result != "" {
+		t.Errorf("Expected empty string for empty input, got %s", result)
+	}
+}
+
+func TestGenerateFormatPreservingSSN(t *testing.T) {
+	for i := range 200 {
+		for _, ssn := range []string{"123-45-6789", "123456789", "123 45 6789"} {
+			result := GenerateFormatPreservingSSN(fmt.Sprintf("user%d", i), ssn)
+			if shapeOf(result) != shapeOf(ssn) {
+				t.Fatalf("Expected %s to keep its shape, got %s", ssn, result)
+			}
+			if d := digitsOf([]byte(result)); !validSSNDigits(d) {
+				t.Fatalf("Expected a valid SSN, got %s", result)
+			}
+		}
+	}
+	if result := GenerateFormatPreservingSSN("user123", "000-12-3456"); !validSSNDigits(digitsOf([]byte(result))) || shapeOf(result) != "999-99-9999" {
+		t.Errorf("Expected a valid SSN for an invalid area, got %s", result)
+	}
+}
+
+func TestGenerateFormatPreservingCardNumber(t *testing.T) {
+	for _, cc := range []string{"4111 1111 1111 1111", "378282246310005", "5555-5555-5555-4444"} {
+		result := GenerateFormatPreservingCardNumber("user123", cc, 0)
+		if result == cc || shapeOf(result) != shapeOf(cc) {
+			t.Errorf("Expected %s to keep its shape, got %s", cc, result)
+		}
+		if result[:2] != cc[:2] {
+			t.Errorf("Expected %s to keep its network prefix, got %s", cc, result)
+		}
+		if !luhnValid(digitsOf([]byte(result))) {
+			t.Errorf("Expected a Luhn-valid number for %s, got %s", cc, result)
+		}
+	}
+	if result := GenerateFormatPreservingCardNumber("user123", "4111111111111111", 1); result == GenerateFormatPreservingCardNumber("user123", "4111111111111111", 0) {
+		t.Errorf("Expected different cards for different indexes, got %s", result)
+	}
+}
+
+func TestGenerateFormatPreserving(t *testing.T) {
+	for _, value := range []string{"X12-345 b", "C01X00T47", "D123-4567-8901", "99-1234567"} {
+		result := GenerateFormatPreserving("user123", "passport", value)
+		if result == value || shapeOf(result) != shapeOf(value) {
+			t.Errorf("Expected %s to keep its shape, got %s", value, result)
+		}
+	}
+	if result := GenerateFormatPreserving("user123", "passport", "007123"); !strings.HasPrefix(result, "00") || result[2] == '0' {
+		t.Errorf("Expected leading zeros to be kept, got %s", result)
+	}
+}
+
+func TestLocales(t *testing.T) {
+	for _, l := range Locales {
+		if got, ok := LocaleByCode(l.Code); !ok || got != l {
+			t.Errorf("Expected LocaleByCode(%s) to find the pack", l.Code)
+		}
+		for _, country := range l.Countries {
+			if got, ok := LocaleForCountry(strings.ToLower(country)); !ok || got != l {
+				t.Errorf("Expected country %s to select %s", country, l.Code)
+			}
+		}
+		if len(l.MaleFirstNames) == 0 || len(l.FemaleFirstNames) == 0 || len(l.LastNames) == 0 ||
+			len(l.Streets) == 0 || len(l.Cities) == 0 || len(l.HouseNumbers) == 0 {
+			t.Errorf("Expected %s to have names, streets, cities and house numbers", l.Code)
+		}
+		if !strings.Contains(l.StreetFormat, "{street}") || !strings.Contains(l.AddressFormat, "{postal}") {
+			t.Errorf("Expected %s to lay out streets and postal codes", l.Code)
+		}
+		// Personas of every locale need ASCII email addresses; apostrophes
+		// are dropped
+		for _, name := range slices.Concat(l.MaleFirstNames, l.FemaleFirstNames, l.LastNames) {
+			for _, r := range strings.ToLower(name) {
+				if (r < 'a' || r > 'z') && r != '-' && r != '\'' && asciiFolds[r] == "" {
+					t.Errorf("Expected %s name %s to fold to ASCII, got %s", l.Code, name, emailLocalPart(name))
+				}
+			}
+		}
+	}
+
+	if l, ok := LocaleByCode("de-DE"); !ok || l != LocaleDeDE {
+		t.Error("Expected de-DE to find de_DE")
+	}
+	if _, ok := LocaleByCode("xx_XX"); ok {
+		t.Error("Expected no pack for xx_XX")
+	}
+	if l, ok := LocaleForCountry(" Deutschland "); !ok || l != LocaleDeDE {
+		t.Error("Expected Deutschland to select de_DE")
+	}
+	if _, ok := LocaleForCountry("Atlantis"); ok {
+		t.Error("Expected no pack for an unknown country")
+	}
+}
+
+func TestLocaleCountry(t *testing.T) {
+	tests := map[string]string{
+		"Deutschland": "Deutschland",
+		"de":          "de",
+		"US":          "DE",
+		"USA":         "DEU",
+		"Atlantis":    "Germany",
+		"usa":         "Germany",
+		"":            "",
+	}
+	for real, want := range tests {
+		if got := LocaleDeDE.Country(real); got != want {
+			t.Errorf("Country(%q): expected %q, got %q", real, want, got)
+		}
+	}
+}
+
+func TestLocaleAddress(t *testing.T) {
+	cities := make(map[string]LocaleCity)
+	for _, city := range LocaleDeDE.Cities {
+		cities[city.Name] = city
+	}
+
+	for i := range 100 {
+		id := fmt.Sprintf("user%d", i)
+		address := LocaleDeDE.Address(id, "123 Main St, Springfield, IL 62701", nil)
+		if address != LocaleDeDE.Address(id, "123 Main St, Springfield, IL 62701", nil) {
+			t.Fatalf("Expected a deterministic address, got %s", address)
+		}
+
+		// "Hauptstraße 12, 10115 Berlin"
+		street, rest, ok := strings.Cut(address, ", ")
+		postal, city, _ := strings.Cut(rest, " ")
+		if !ok || !slices.Contains(LocaleDeDE.Streets, street[:strings.LastIndex(street, " ")]) {
+			t.Errorf("Expected a German street line, got %s", address)
+		}
+		want, ok := cities[city]
+		if !ok || len(postal) != 5 || !matchesPattern(postal, want.PostalCode) {
+			t.Errorf("Expected postal code %s to match city %s, got %s", postal, city, address)
+		}
+
+		place := LocaleDeDE.NewPlace(id, "city=Springfield", "zip_code=62701")
+		if c := cities[place.City]; c.State != place.State || !matchesPattern(place.PostalCode, c.PostalCode) {
+			t.Errorf("Expected a coherent place, got %+v", place)
+		}
+		if withPlace := LocaleDeDE.Address(id, "123 Main St", place); !strings.HasSuffix(withPlace, ", "+place.PostalCode+" "+place.City) {
+			t.Errorf("Expected the address to use %+v, got %s", place, withPlace)
+		}
+	}
+
+	if got := LocaleJaJP.Street("user1", "1 Main St"); !strings.ContainsAny(got[:1], "123456789") {
+		t.Errorf("Expected a chome number first, got %s", got)
+	}
+	if got := LocaleEsMX.Street("user1", "1 Main St"); got[len(got)-1] < '0' || got[len(got)-1] > '9' {
+		t.Errorf("Expected the house number last, got %s", got)
+	}
+	if LocaleDeDE.Street("user1", "") != "" || LocaleDeDE.Address("user1", "", nil) != "" {
+		t.Error("Expected empty values to stay empty")
+	}
+}
+
+// matchesPattern reports whether s matches a pattern in which # is a digit
+func matchesPattern(s, pattern string) bool {
+	if len(s) != len(pattern) {
+		return false
+	}
+	for i := range len(s) {
+		if pattern[i] == '#' && (s[i] < '0' || s[i] > '9') || pattern[i] != '#' && pattern[i] != s[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLoadDictionaryFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := dir + "/" + name
+		if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	list, err := LoadDictionaryFile(write("companies.txt", "\ufeff# Company names\nAcme Corp\n\n  Globex  \nInitech\r\n"))
+	if err != nil {
+		t.Fatalf("Failed to load text dictionary: %v", err)
+	}
+	if !slices.Equal(list, []string{"Acme Corp", "Globex", "Initech"}) {
+		t.Errorf("Expected three companies, got %q", list)
+	}
+
+	list, err = LoadDictionaryFile(write("codes.yaml", "- 00123\n- SKU-9\n- true\n"))
+	if err != nil {
+		t.Fatalf("Failed to load YAML dictionary: %v", err)
+	}
+	if !slices.Equal(list, []string{"00123", "SKU-9", "true"}) {
+		t.Errorf("Expected entries as written, got %q", list)
+	}
+
+	invalid := map[string]string{
+		"duplicate.txt":   "Smith\nJones\nSmith\n",
+		"empty.txt":       "# nothing\n\n",
+		"duplicate.yaml":  "- Smith\n- \" Smith \"\n",
+		"empty-item.yaml": "- Smith\n- \"\"\n",
+		"null-item.yaml":  "- Smith\n-\n",
+		"nested.yaml":     "- [Smith]\n",
+		"mapping.yaml":    "names: [Smith]\n",
+		"no-items.yml":    "",
+	}
+	for name, content := range invalid {
+		if _, err := LoadDictionaryFile(write(name, content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := LoadDictionaryFile(write("dup.txt", "a\nb\na\n")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("Expected the duplicate's line in the error, got %v", err)
+	}
+	if _, err := LoadDictionaryFile(dir + "/missing.txt"); err == nil {
+		t.Error("Expected error for a missing file")
+	}
+}
+
+func TestLoadDictionaries(t *testing.T) {
+	savedLastNames, savedCities := LastNames, CityNames
+	t.Cleanup(func() {
+		LastNames, CityNames = savedLastNames, savedCities
+		LocaleEnUS.LastNames = savedLastNames
+		delete(replacedDictionaries, "last_names")
+		delete(replacedDictionaries, "cities")
+		delete(customDictionaries, "companies")
+	})
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"last_names": dir + "/last_names.txt",
+		"cities":     dir + "/cities.yaml",
+		"companies":  dir + "/companies.txt",
+	}
+	os.WriteFile(files["last_names"], []byte("Abbott\nBanerjee\n"), 0o600)
+	os.WriteFile(files["cities"], []byte("- Springfield\n- Shelbyville\n"), 0o600)
+	os.WriteFile(files["companies"], []byte("Acme Corp\nGlobex\nInitech\n"), 0o600)
+
+	// A bad file leaves every list untouched
+	os.WriteFile(dir+"/bad.txt", []byte("x\nx\n"), 0o600)
+	if err := LoadDictionaries(map[string]string{"last_names": files["last_names"], "zzz": dir + "/bad.txt"}); err == nil {
+		t.Fatal("Expected error for a duplicate entry")
+	}
+	if !slices.Equal(LastNames, savedLastNames) {
+		t.Fatal("Expected last names to be unchanged after a failed load")
+	}
+
+	if err := LoadDictionaries(files); err != nil {
+		t.Fatalf("Failed to load dictionaries: %v", err)
+	}
+	for i := range 50 {
+		id := fmt.Sprintf("user%d", i)
+		if last := GenerateDeterministicLastName(id, "Doe"); last != "Abbott" && last != "Banerjee" {
+			t.Errorf("Expected a last name from the file, got %s", last)
+		}
+		if city := GenerateDeterministicCity(id, "Boston"); city != "Springfield" && city != "Shelbyville" {
+			t.Errorf("Expected a city from the file, got %s", city)
+		}
+		if company := GenerateDeterministicWord(id, "companies", "Contoso"); !slices.Contains([]string{"Acme Corp", "Globex", "Initech"}, company) {
+			t.Errorf("Expected a company from the file, got %s", company)
+		}
+	}
+	if p := LocaleEnUS.NewPersona("user1", "name=John Doe"); p.LastName != "Abbott" && p.LastName != "Banerjee" {
+		t.Errorf("Expected en_US personas to use the loaded last names, got %s", p.LastName)
+	}
+	if token := GenerateDeterministicWord("user1", "unknown", "Contoso"); token == "Contoso" || token == "" {
+		t.Errorf("Expected a token for an unknown dictionary, got %q", token)
+	}
+}
+
+func TestBuiltinDictionariesValid(t *testing.T) {
+	for name, list := range builtinDictionaries {
+		var b dictionaryBuilder
+		for i, entry := range *list {
+			if err := b.add(entry, i+1); err != nil {
+				t.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+}
+
+func TestSelectFromListLarge(t *testing.T) {
+	list := make([]string, 1000)
+	for i := range list {
+		list[i] = fmt.Sprint(i)
+	}
+	seen := make(map[string]bool)
+	for i := range 5000 {
+		seen[selectFromList(hashField("user", "word", fmt.Sprint(i)), 0, list)] = true
+	}
+	if len(seen) < 900 {
+		t.Errorf("Expected entries beyond the first 256 to be selected, got %d distinct", len(seen))
+	}
+}
+
+func TestFPEPermute(t *testing.T) {
+	for _, radices := range [][]int{{10}, {10, 10}, {10, 10, 10}, {26, 10}, {10, 26, 10}} {
+		total := 1
+		for _, r := range radices {
+			total *= r
+		}
+		seen := make(map[string]bool)
+		for v := range total {
+			x := make([]byte, len(radices))
+			for i := len(radices) - 1; i >= 0; i-- {
+				x[i] = byte(v % radices[i])
+				v /= radices[i]
+			}
+			out := fpePermute("test", radices, x)
+			for i, d := range out {
+				if int(d) >= radices[i] {
+					t.Fatalf("Expected numeral %d of %v below %d, got %d", i, out, radices[i], d)
+				}
+			}
+			seen[string(out)] = true
+		}
+		if len(seen) != total {
+			t.Errorf("Expected a permutation of %v numerals, got %d distinct of %d", radices, len(seen), total)
+		}
+	}
+}
+
+func TestGenerateBijectiveNeighbours(t *testing.T) {
+	// Inputs differing in one character must not give fakes that share the
+	// other characters or differ by a constant shift
+	letters := make(map[byte]bool)
+	shifts := make(map[byte]bool)
+	var prev string
+	for d := range 10 {
+		result := GenerateBijective("", "passport", fmt.Sprintf("X%d", d))
+		if shapeOf(result) != "A9" {
+			t.Fatalf("Expected a passport shaped like X1, got %s", result)
+		}
+		letters[result[0]] = true
+		if prev != "" {
+			shifts[(result[1]-prev[1]+10)%10] = true
+		}
+		prev = result
+	}
+	if len(letters) == 1 || len(shifts) == 1 {
+		t.Errorf("Expected neighbouring passports to map to unrelated fakes, got letters %v and shifts %v", letters, shifts)
+	}
+
+	shared := 0
+	for d := range 100 {
+		a := GenerateBijective("", "passport", fmt.Sprintf("AB%04d", d))
+		b := GenerateBijective("", "passport", fmt.Sprintf("AB%04d", d+1))
+		if a[:2] == b[:2] || a[2:] == b[2:] {
+			shared++
+		}
+	}
+	if shared > 10 {
+		t.Errorf("Expected fakes of neighbouring passports to share their letters or digits rarely, got %d of 100", shared)
+	}
+}
+
+func TestGenerateBijective(t *testing.T) {
+	ssns := make(map[string]bool)
+	cards := make(map[string]bool)
+	ints := make(map[int64]bool)
+	for i := range 5000 {
+		ssn := fmt.Sprintf("%03d-45-%04d", 100+i%500, i+1)
+		result := GenerateBijectiveSSN("", ssn)
+		if shapeOf(result) != shapeOf(ssn) || !validSSNDigits(digitsOf([]byte(result))) {
+			t.Fatalf("Expected a valid SSN shaped like %s, got %s", ssn, result)
+		}
+		ssns[result] = true
+
+		cardBytes := []byte(fmt.Sprintf("4111 1111 %04d %04d", i, i%1000*10))
+		setLuhnCheckDigit(cardBytes)
+		card := string(cardBytes)
+		result = GenerateBijectiveCardNumber("", card)
+		if shapeOf(result) != shapeOf(card) || result[:2] != "41" || !luhnValid(digitsOf([]byte(result))) {
+			t.Fatalf("Expected a Luhn-valid card shaped like %s, got %s", card, result)
+		}
+		cards[result] = true
+
+		n := GenerateBijectiveInteger("", int64(i))
+		if len(fmt.Sprint(n)) != len(fmt.Sprint(i)) {
+			t.Fatalf("Expected %d to keep its number of digits, got %d", i, n)
+		}
+		ints[n] = true
+	}
+	if len(ssns) != 5000 || len(cards) != 5000 || len(ints) != 5000 {
+		t.Errorf("Expected 5000 distinct values, got %d SSNs, %d cards, %d integers", len(ssns), len(cards), len(ints))
+	}
+
+	if result := GenerateBijectivePhone("", "+44 20 7946 0958"); !strings.HasPrefix(result, "+44 ") || shapeOf(result) != shapeOf("+44 20 7946 0958") {
+		t.Errorf("Expected the country code and shape to be kept, got %s", result)
+	}
+	if result := GenerateBijective("", "passport", "X12-345 b"); result == "X12-345 b" || shapeOf(result) != shapeOf("X12-345 b") {
+		t.Errorf("Expected the shape to be kept, got %s", result)
+	}
+	if GenerateBijective("", "passport", "X12345") != GenerateBijective("", "passport", "X12345") {
+		t.Error("Expected the same value to give the same result")
+	}
+	if result := GenerateBijectiveSSN("", "000-12-3456"); validSSNDigits(digitsOf([]byte(result))) {
+		t.Errorf("Expected an invalid SSN to stay invalid, got %s", result)
+	}
+	emails := make(map[string]bool)
+	for i := range 2000 {
+		result := GenerateBijectiveEmail("", fmt.Sprintf("Person%d@Corp.example.com", i))
+		if !strings.HasSuffix(result, ".com") || strings.Count(result, "@") != 1 || result != strings.ToLower(result) {
+			t.Fatalf("Expected a lower-case address keeping its TLD, got %s", result)
+		}
+		emails[result] = true
+	}
+	if len(emails) != 2000 {
+		t.Errorf("Expected 2000 distinct emails, got %d", len(emails))
+	}
+	if GenerateBijectiveEmail("", "Jane@Corp.com") != GenerateBijectiveEmail("", "jane@corp.com") {
+		t.Error("Expected addresses differing in case to give the same result")
+	}
+
+	if n := GenerateBijectiveInteger("", -7); n >= 0 || n < -9 {
+		t.Errorf("Expected a negative one-digit integer, got %d", n)
+	}
+}
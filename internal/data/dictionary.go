@@ -0,0 +1,193 @@
+package data
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// builtinDictionaries are the generator word lists a dictionary file can
+// replace, by dictionary name
+var builtinDictionaries = map[string]*[]string{
+	"first_names":        &FirstNames,
+	"male_first_names":   &MaleFirstNames,
+	"female_first_names": &FemaleFirstNames,
+	"last_names":         &LastNames,
+	"streets":            &StreetNames,
+	"cities":             &CityNames,
+	"states":             &StateProvinces,
+	"countries":          &CountryNames,
+	"email_domains":      &EmailDomains,
+}
+
+// customDictionaries are dictionaries loaded under other names, used by the
+// word generator, e.g. company names or product codes
+var customDictionaries = map[string][]string{}
+
+// replacedDictionaries records the built-in dictionaries replaced from files.
+// Cities, states and countries are otherwise picked by region, see
+// AddressRegions.
+var replacedDictionaries = map[string]bool{}
+
+// Dictionary returns the entries of a built-in or loaded dictionary
+func Dictionary(name string) ([]string, bool) {
+	if list, ok := builtinDictionaries[name]; ok {
+		return *list, true
+	}
+	list, ok := customDictionaries[name]
+	return list, ok
+}
+
+// LoadDictionaries reads dictionary files, mapping each dictionary name to a
+// file, see LoadDictionaryFile. A built-in name such as last_names replaces
+// that word list; any other name adds a dictionary for the word generator.
+// Either every file is loaded or, on error, none is. Dictionaries must be
+// loaded at startup, before any values are generated.
+func LoadDictionaries(files map[string]string) error {
+	loaded := make(map[string][]string, len(files))
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if name == "" {
+			return fmt.Errorf("empty dictionary name")
+		}
+		list, err := LoadDictionaryFile(files[name])
+		if err != nil {
+			return fmt.Errorf("dictionary %s: %w", name, err)
+		}
+		loaded[name] = list
+	}
+
+	for name, list := range loaded {
+		if builtin, ok := builtinDictionaries[name]; ok {
+			*builtin = list
+			replacedDictionaries[name] = true
+		} else {
+			customDictionaries[name] = list
+		}
+	}
+	// The en_US locale shares the default name and street lists
+	LocaleEnUS.MaleFirstNames = MaleFirstNames
+	LocaleEnUS.FemaleFirstNames = FemaleFirstNames
+	LocaleEnUS.LastNames = LastNames
+	LocaleEnUS.Streets = StreetNames
+	return nil
+}
+
+// LoadDictionaryFile reads a dictionary file. Files ending in .yaml or .yml
+// hold a YAML list; any other file holds one entry per line, skipping blank
+// lines and lines starting with #. Empty and duplicate entries are rejected.
+func LoadDictionaryFile(file string) ([]string, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read dictionary: %w", err)
+	}
+	var list []string
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		list, err = parseYAMLDictionary(raw)
+	default:
+		list, err = parseTextDictionary(raw)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid dictionary %s: %w", file, err)
+	}
+	return list, nil
+}
+
+// dictionaryBuilder collects entries, rejecting empty and duplicate ones
+type dictionaryBuilder struct {
+	list []string
+	seen map[string]int
+}
+
+func (b *dictionaryBuilder) add(entry string, line int) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return fmt.Errorf("line %d: empty entry", line)
+	}
+	if first, ok := b.seen[entry]; ok {
+		return fmt.Errorf("line %d: duplicate entry %q, first on line %d", line, entry, first)
+	}
+	if b.seen == nil {
+		b.seen = make(map[string]int)
+	}
+	b.seen[entry] = line
+	b.list = append(b.list, entry)
+	return nil
+}
+
+func (b *dictionaryBuilder) result() ([]string, error) {
+	if len(b.list) == 0 {
+		return nil, fmt.Errorf("no entries")
+	}
+	return b.list, nil
+}
+
+func parseTextDictionary(raw []byte) ([]string, error) {
+	var b dictionaryBuilder
+	scanner := bufio.NewScanner(bytes.NewReader(bytes.TrimPrefix(raw, []byte("\ufeff"))))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if err := b.add(text, line); err != nil {
+			return nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return b.result()
+}
+
+func parseYAMLDictionary(raw []byte) ([]string, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("no entries")
+	}
+	seq := doc.Content[0]
+	if seq.Kind != yaml.SequenceNode {
+		return nil, fmt.Errorf("line %d: expected a list of entries", seq.Line)
+	}
+
+	var b dictionaryBuilder
+	for _, item := range seq.Content {
+		if item.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf("line %d: expected a single value", item.Line)
+		}
+		// Scalars are taken as written, so product codes such as 00123 keep
+		// their leading zeros
+		value := item.Value
+		if item.Tag == "!!null" {
+			value = ""
+		}
+		if err := b.add(value, item.Line); err != nil {
+			return nil, err
+		}
+	}
+	return b.result()
+}
+
+// GenerateDeterministicWord picks a deterministic entry of a dictionary, or
+// returns an opaque token if no dictionary has that name
+func GenerateDeterministicWord(id, dictionary, realValue string) string {
+	if realValue == "" {
+		return ""
+	}
+	list, ok := Dictionary(dictionary)
+	if !ok || len(list) == 0 {
+		return GenerateDeterministicToken(id, "word", realValue)
+	}
+	hash := hashField(id, "word:"+dictionary, realValue)
+	return selectFromList(hash, 0, list)
+}
@@ -0,0 +1,238 @@
+package data
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"strings"
+)
+
+// digitStream yields deterministic digits and letters for format-preserving
+// generation. It is an HMAC-SHA256 keystream, so values of any length can be
+// generated.
+type digitStream struct {
+	seed    string
+	counter byte
+	buf     []byte
+}
+
+func newDigitStream(id, fieldType, value string) *digitStream {
+	return &digitStream{seed: id + ":" + fieldType + ":" + value}
+}
+
+func (s *digitStream) next() byte {
+	if len(s.buf) == 0 {
+		mac := hmac.New(sha256.New, currentHashKey())
+		mac.Write([]byte(s.seed))
+		mac.Write([]byte{s.counter})
+		s.counter++
+		s.buf = mac.Sum(nil)
+	}
+	b := s.buf[0]
+	s.buf = s.buf[1:]
+	return b
+}
+
+// digit returns a digit in [min, 9]
+func (s *digitStream) digit(min byte) byte {
+	return '0' + min + s.next()%(10-min)
+}
+
+func (s *digitStream) letter(upper bool) byte {
+	if upper {
+		return 'A' + s.next()%26
+	}
+	return 'a' + s.next()%26
+}
+
+// preserveShape replaces every ASCII digit and letter of real, starting at
+// byte offset from, with a generated one of the same class and case. All
+// other characters, such as separators and spaces, are kept in place, as are
+// leading zeros; the first other digit is never turned into a 0.
+func preserveShape(s *digitStream, real string, from int) []byte {
+	out := []byte(real)
+	leading := true
+	for i := from; i < len(out); i++ {
+		c := out[i]
+		switch {
+		case c == '0' && leading:
+		case c >= '1' && c <= '9' && leading:
+			out[i] = s.digit(1)
+			leading = false
+		case c >= '0' && c <= '9':
+			out[i] = s.digit(0)
+		case c >= 'A' && c <= 'Z':
+			out[i] = s.letter(true)
+			leading = false
+		case c >= 'a' && c <= 'z':
+			out[i] = s.letter(false)
+			leading = false
+		}
+	}
+	return out
+}
+
+// GenerateFormatPreserving replaces the digits and letters of a value such
+// as a passport or account number with deterministic ones, keeping its
+// length, separators and the positions and case of digits and letters, e.g.
+// "X12-345 b" becomes "Q80-921 k"
+func GenerateFormatPreserving(id, fieldType, real string) string {
+	if real == "" {
+		return ""
+	}
+	return string(preserveShape(newDigitStream(id, fieldType, real), real, 0))
+}
+
+// twoDigitCountryCodes are the ITU calling codes of two digits. 1 and 7 are
+// the only one-digit codes; all others have three.
+var twoDigitCountryCodes = map[string]bool{
+	"20": true, "27": true, "30": true, "31": true, "32": true, "33": true, "34": true,
+	"36": true, "39": true, "40": true, "41": true, "43": true, "44": true, "45": true,
+	"46": true, "47": true, "48": true, "49": true, "51": true, "52": true, "53": true,
+	"54": true, "55": true, "56": true, "57": true, "58": true, "60": true, "61": true,
+	"62": true, "63": true, "64": true, "65": true, "66": true, "81": true, "82": true,
+	"84": true, "86": true, "90": true, "91": true, "92": true, "93": true, "94": true,
+	"95": true, "98": true,
+}
+
+// countryCodeLength returns the length in bytes of the "+" and calling code
+// that start an international phone number, or 0 if there is none
+func countryCodeLength(phone string) int {
+	if !strings.HasPrefix(phone, "+") {
+		return 0
+	}
+	digits := 0
+	for digits < len(phone)-1 && phone[1+digits] >= '0' && phone[1+digits] <= '9' {
+		digits++
+	}
+	switch {
+	case digits == 0:
+		return 0
+	case digits <= 3 && len(phone) > 1+digits:
+		// The code is written apart from the number, e.g. +44 20 7946 0958
+		return 1 + digits
+	case phone[1] == '1' || phone[1] == '7':
+		return 2
+	case digits >= 2 && twoDigitCountryCodes[phone[1:3]]:
+		return 3
+	default:
+		return 1 + min(digits, 3)
+	}
+}
+
+// GenerateFormatPreservingPhone generates a phone number shaped like the
+// real one: the country code, separators, parentheses and number of digits
+// are kept, as is a leading trunk 0, e.g. "(212) 555-0199" or
+// "+44 20 7946 0958"
+func GenerateFormatPreservingPhone(id, realPhone string) string {
+	if realPhone == "" {
+		return ""
+	}
+	s := newDigitStream(id, "phone", realPhone)
+	return string(preserveShape(s, realPhone, countryCodeLength(realPhone)))
+}
+
+// GenerateFormatPreservingSSN generates an SSN with the real one's
+// separators. Nine-digit values get a valid area (001-899 except 666),
+// group and serial; anything else is treated like GenerateFormatPreserving.
+func GenerateFormatPreservingSSN(id, realSSN string) string {
+	if realSSN == "" {
+		return ""
+	}
+	if len(digitsOf([]byte(realSSN))) != 9 {
+		return GenerateFormatPreserving(id, "ssn", realSSN)
+	}
+	s := newDigitStream(id, "ssn", realSSN)
+	for range 16 {
+		if out := preserveShape(s, realSSN, 0); validSSNDigits(digitsOf(out)) {
+			return string(out)
+		}
+	}
+
+	// Leading zeros are kept, so an area of 000 can't be re-rolled; fall
+	// back to the digits of a generated SSN
+	digits := digitsOf([]byte(GenerateDeterministicSSN(id, realSSN)))
+	out := []byte(realSSN)
+	for i, c := range out {
+		if c >= '0' && c <= '9' {
+			out[i], digits = digits[0], digits[1:]
+		}
+	}
+	return string(out)
+}
+
+// validSSNDigits reports whether nine digits form an issuable SSN
+func validSSNDigits(d []byte) bool {
+	area, group, serial := string(d[:3]), string(d[3:5]), string(d[5:])
+	return area != "000" && area != "666" && area < "900" && group != "00" && serial != "0000"
+}
+
+// digitsOf returns the ASCII digits of b
+func digitsOf(b []byte) []byte {
+	var digits []byte
+	for _, c := range b {
+		if c >= '0' && c <= '9' {
+			digits = append(digits, c)
+		}
+	}
+	return digits
+}
+
+// GenerateFormatPreservingCardNumber generates a card number with the real
+// one's length, separators and first two digits, which tell card networks
+// apart. If the real number passes the Luhn check, so does the result.
+func GenerateFormatPreservingCardNumber(id, realCCNumber string, index int) string {
+	if realCCNumber == "" {
+		return ""
+	}
+	s := newDigitStream(id, fmt.Sprintf("credit_card_number_%d", index), realCCNumber)
+
+	// Keep the network prefix
+	from, kept := 0, 0
+	for from < len(realCCNumber) && kept < 2 {
+		if c := realCCNumber[from]; c >= '0' && c <= '9' {
+			kept++
+		}
+		from++
+	}
+	out := preserveShape(s, realCCNumber, from)
+	if digits := digitsOf([]byte(realCCNumber)); len(digits) > kept && luhnValid(digits) {
+		setLuhnCheckDigit(out)
+	}
+	return string(out)
+}
+
+// luhnValid reports whether digits pass the Luhn check
+func luhnValid(digits []byte) bool {
+	return luhnSum(digits)%10 == 0
+}
+
+func luhnSum(digits []byte) int {
+	sum := 0
+	for i := range digits {
+		d := int(digits[len(digits)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum
+}
+
+// setLuhnCheckDigit rewrites the last digit of b so its digits pass the Luhn check
+func setLuhnCheckDigit(b []byte) {
+	last := -1
+	for i, c := range b {
+		if c >= '0' && c <= '9' {
+			last = i
+		}
+	}
+	if last < 0 {
+		return
+	}
+	b[last] = '0'
+	b[last] = '0' + byte((10-luhnSum(digitsOf(b))%10)%10)
+}
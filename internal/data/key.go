@@ -0,0 +1,121 @@
+package data
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MinHashKeyLength is the minimum accepted size of the hashing secret in bytes
+const MinHashKeyLength = 32
+
+// hashKey holds the server-side secret used to key every deterministic hash.
+// Without the key, obscured values cannot be linked back to real values by
+// running a dictionary through the generators.
+var hashKey atomic.Pointer[[]byte]
+
+// SetHashKey sets the secret used by all GenerateDeterministic* functions.
+// It must be called before any of them, or before ObscureData and anything
+// built on them; generating without a key panics.
+func SetHashKey(key []byte) error {
+	if len(key) < MinHashKeyLength {
+		return fmt.Errorf("hash key must be at least %d bytes, got %d", MinHashKeyLength, len(key))
+	}
+	k := bytes.Clone(key)
+	hashKey.Store(&k)
+	return nil
+}
+
+// currentHashKey returns the configured secret. Generating without one would
+// give fakes anyone can reproduce from a dictionary of real values, so a
+// missing key is a programming error and panics.
+func currentHashKey() []byte {
+	if k := hashKey.Load(); k != nil {
+		return *k
+	}
+	panic("data: no hash key set, call SetHashKey before generating fakes")
+}
+
+// ParseHashKey decodes a hashing secret of at least MinHashKeyLength bytes.
+// Hex-encoded keys are decoded, anything else is used as raw bytes.
+// Surrounding whitespace is ignored.
+func ParseHashKey(raw []byte) ([]byte, error) {
+	raw = bytes.TrimSpace(raw)
+	if decoded, err := hex.DecodeString(string(raw)); err == nil {
+		// A hex passphrase is decoded too, halving its length, so say why it
+		// is too short
+		if len(decoded) < MinHashKeyLength {
+			return nil, fmt.Errorf("key was decoded as hex to %d bytes, hex keys need at least %d hex characters", len(decoded), 2*MinHashKeyLength)
+		}
+		return decoded, nil
+	}
+	if len(raw) < MinHashKeyLength {
+		return nil, fmt.Errorf("key must be at least %d bytes, got %d", MinHashKeyLength, len(raw))
+	}
+	return raw, nil
+}
+
+// LoadHashKeyFromFile reads a hashing secret from a file
+func LoadHashKeyFromFile(filepath string) ([]byte, error) {
+	raw, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read hash key file: %w", err)
+	}
+
+	key, err := ParseHashKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("hash key in %s: %w", filepath, err)
+	}
+	return key, nil
+}
+
+// LoadTenantKeysFromFile reads per-tenant secrets from a YAML file mapping
+// tenant names to secrets, each hex or raw and at least MinHashKeyLength
+// bytes. Tenants sharing a secret would share fakes, so that is rejected.
+func LoadTenantKeysFromFile(filepath string) (map[string][]byte, error) {
+	raw, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tenant keys file: %w", err)
+	}
+
+	var secrets map[string]string
+	if err := yaml.Unmarshal(raw, &secrets); err != nil {
+		return nil, fmt.Errorf("failed to parse tenant keys file %s: %w", filepath, err)
+	}
+	if len(secrets) == 0 {
+		return nil, fmt.Errorf("no tenant keys found in %s", filepath)
+	}
+
+	keys := make(map[string][]byte, len(secrets))
+	owners := make(map[string]string, len(secrets))
+	for tenant, secret := range secrets {
+		if tenant == "" {
+			return nil, fmt.Errorf("empty tenant name in %s", filepath)
+		}
+		key, err := ParseHashKey([]byte(secret))
+		if err != nil {
+			return nil, fmt.Errorf("tenant %q in %s: %w", tenant, filepath, err)
+		}
+		if other, ok := owners[string(key)]; ok {
+			return nil, fmt.Errorf("tenants %q and %q share a key in %s", other, tenant, filepath)
+		}
+		owners[string(key)] = tenant
+		keys[tenant] = key
+	}
+	return keys, nil
+}
+
+// ScopeID derives a record identifier private to the holder of key. Passing
+// scoped identifiers to the GenerateDeterministic* functions gives each
+// tenant its own consistent fakes that can't be linked to another tenant's.
+func ScopeID(key []byte, id string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
@@ -0,0 +1,205 @@
+package data
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Locale is a pack of names and address conventions for one country, so a
+// German record gets "Hauptstraße 12, 10115 Berlin" rather than
+// "1234 Main St, Berlin, BW 04521"
+type Locale struct {
+	// Code identifies the locale, e.g. de_DE
+	Code string
+	// Countries are the spellings of the country that select the locale,
+	// e.g. DE, DEU, Germany; matching ignores case
+	Countries []string
+
+	MaleFirstNames   []string
+	FemaleFirstNames []string
+	LastNames        []string
+
+	Streets []string
+	Cities  []LocaleCity
+	// HouseNumbers are patterns for house numbers, # being a digit
+	HouseNumbers []string
+	// StreetFormat lays out a street line from {number} and {street}
+	StreetFormat string
+	// AddressFormat lays out a full address from {street}, {city}, {state}
+	// and {postal}
+	AddressFormat string
+}
+
+// LocaleCity is a city with its state or region and the pattern of its postal
+// codes, # being a digit, e.g. 1#### for Berlin
+type LocaleCity struct {
+	Name       string
+	State      string
+	PostalCode string
+}
+
+// Place is a city with a matching state and postal code
+type Place struct {
+	City       string
+	State      string
+	PostalCode string
+}
+
+// LocaleByCode returns the locale pack with the given code, e.g. de_DE or de-DE
+func LocaleByCode(code string) (*Locale, bool) {
+	code = strings.ReplaceAll(code, "-", "_")
+	for _, l := range Locales {
+		if strings.EqualFold(l.Code, code) {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// LocaleForCountry returns the locale pack of a country given by code or
+// name, e.g. DE or Germany
+func LocaleForCountry(country string) (*Locale, bool) {
+	country = strings.TrimSpace(country)
+	for _, l := range Locales {
+		if slices.ContainsFunc(l.Countries, func(c string) bool { return strings.EqualFold(c, country) }) {
+			return l, true
+		}
+	}
+	return nil, false
+}
+
+// firstNames picks the male or female first names from a hash bit
+func (l *Locale) firstNames(hash [8]byte, byteIndex int) []string {
+	if hash[byteIndex]%2 == 0 {
+		return l.MaleFirstNames
+	}
+	return l.FemaleFirstNames
+}
+
+// Name generates a deterministic full name from the locale's names
+func (l *Locale) Name(id, realName string) string {
+	if realName == "" {
+		return ""
+	}
+	hash := hashField(id, "name", realName)
+	first := selectFromList(hash, 0, l.firstNames(hash, 2))
+	last := selectFromList(hash, 1, l.LastNames)
+	return fmt.Sprintf("%s %s", first, last)
+}
+
+// FirstName generates a deterministic first name from the locale's names
+func (l *Locale) FirstName(id, realFirstName string) string {
+	if realFirstName == "" {
+		return ""
+	}
+	hash := hashField(id, "firstname", realFirstName)
+	return selectFromList(hash, 0, l.firstNames(hash, 1))
+}
+
+// MiddleName generates a deterministic middle name from the locale's names
+func (l *Locale) MiddleName(id, realMiddleName string) string {
+	if realMiddleName == "" {
+		return ""
+	}
+	hash := hashField(id, "middlename", realMiddleName)
+	return selectFromList(hash, 0, l.firstNames(hash, 1))
+}
+
+// LastName generates a deterministic last name from the locale's names
+func (l *Locale) LastName(id, realLastName string) string {
+	if realLastName == "" {
+		return ""
+	}
+	hash := hashField(id, "lastname", realLastName)
+	return selectFromList(hash, 0, l.LastNames)
+}
+
+// Street generates a deterministic street line in the locale's layout
+func (l *Locale) Street(id, realStreet string) string {
+	if realStreet == "" {
+		return ""
+	}
+	return l.street(newDigitStream(id, "street", realStreet))
+}
+
+func (l *Locale) street(s *digitStream) string {
+	number := fillPattern(s, l.HouseNumbers[int(s.next())%len(l.HouseNumbers)], true)
+	street := l.Streets[int(s.next())%len(l.Streets)]
+	return strings.NewReplacer("{number}", number, "{street}", street).Replace(l.StreetFormat)
+}
+
+// NewPlace derives a city, state and postal code that belong together from a
+// record id and the real values of its city, state and postal code fields
+func (l *Locale) NewPlace(id string, values ...string) *Place {
+	return l.place(newDigitStream(id, "place", strings.Join(values, "\x00")))
+}
+
+func (l *Locale) place(s *digitStream) *Place {
+	city := l.Cities[(int(s.next())<<8|int(s.next()))%len(l.Cities)]
+	return &Place{City: city.Name, State: city.State, PostalCode: fillPattern(s, city.PostalCode, false)}
+}
+
+// Address generates a deterministic full address in the locale's layout.
+// place, if not nil, supplies the city, state and postal code.
+func (l *Locale) Address(id, realAddress string, place *Place) string {
+	if realAddress == "" {
+		return ""
+	}
+	s := newDigitStream(id, "address", realAddress)
+	street := l.street(s)
+	if place == nil {
+		place = l.place(s)
+	}
+	return strings.NewReplacer(
+		"{street}", street,
+		"{city}", place.City,
+		"{state}", place.State,
+		"{postal}", place.PostalCode,
+	).Replace(l.AddressFormat)
+}
+
+// Country returns the locale's country in the style of realCountry: its
+// two- or three-letter code for a code, otherwise its name, e.g. "FRA" or
+// "France" for fr_FR. A country that already selects the locale is kept.
+func (l *Locale) Country(realCountry string) string {
+	if realCountry == "" {
+		return ""
+	}
+	if c, ok := LocaleForCountry(realCountry); ok && c == l {
+		return realCountry
+	}
+	// Countries start with the two- and three-letter codes, then the name
+	i := 2
+	if code := strings.TrimSpace(realCountry); isUpperASCII(code) && (len(code) == 2 || len(code) == 3) {
+		i = len(code) - 2
+	}
+	return l.Countries[min(i, len(l.Countries)-1)]
+}
+
+// isUpperASCII reports whether s is made of upper case ASCII letters only
+func isUpperASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// fillPattern replaces each # of pattern with a digit. With noLeadingZero,
+// the first digit of every run of #s is 1-9.
+func fillPattern(s *digitStream, pattern string, noLeadingZero bool) string {
+	out := []byte(pattern)
+	for i, c := range out {
+		if c != '#' {
+			continue
+		}
+		if noLeadingZero && (i == 0 || pattern[i-1] != '#') {
+			out[i] = s.digit(1)
+		} else {
+			out[i] = s.digit(0)
+		}
+	}
+	return string(out)
+}
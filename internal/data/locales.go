@@ -0,0 +1,170 @@
+package data
+
+// Locales are the built-in locale packs
+var Locales = []*Locale{LocaleEnUS, LocaleDeDE, LocaleFrFR, LocaleJaJP, LocaleEsMX}
+
+// LocaleEnUS is the en_US locale pack, matching the default generators
+var LocaleEnUS = &Locale{
+	Code:             "en_US",
+	Countries:        []string{"US", "USA", "United States", "United States of America"},
+	MaleFirstNames:   MaleFirstNames,
+	FemaleFirstNames: FemaleFirstNames,
+	LastNames:        LastNames,
+	Streets:          StreetNames,
+	Cities: []LocaleCity{
+		{"New York", "NY", "100##"}, {"Los Angeles", "CA", "900##"}, {"Chicago", "IL", "606##"},
+		{"Houston", "TX", "770##"}, {"Phoenix", "AZ", "850##"}, {"Philadelphia", "PA", "191##"},
+		{"San Antonio", "TX", "782##"}, {"San Diego", "CA", "921##"}, {"Dallas", "TX", "752##"},
+		{"San Jose", "CA", "951##"}, {"Austin", "TX", "787##"}, {"Seattle", "WA", "981##"},
+		{"Denver", "CO", "802##"}, {"Boston", "MA", "021##"}, {"Portland", "OR", "972##"},
+		{"Atlanta", "GA", "303##"}, {"Miami", "FL", "331##"}, {"Minneapolis", "MN", "554##"},
+	},
+	HouseNumbers:  []string{"#", "##", "###", "####"},
+	StreetFormat:  "{number} {street}",
+	AddressFormat: "{street}, {city}, {state} {postal}",
+}
+
+// LocaleDeDE is the de_DE locale pack
+var LocaleDeDE = &Locale{
+	Code:      "de_DE",
+	Countries: []string{"DE", "DEU", "Germany", "Deutschland"},
+	MaleFirstNames: []string{
+		"Lukas", "Leon", "Finn", "Jonas", "Paul", "Felix", "Maximilian", "Elias",
+		"Noah", "Ben", "Moritz", "Tim", "Jan", "Niklas", "Tobias", "Florian",
+		"Stefan", "Thomas", "Andreas", "Michael", "Jürgen", "Klaus", "Wolfgang", "Matthias",
+	},
+	FemaleFirstNames: []string{
+		"Mia", "Emma", "Hannah", "Sophia", "Lea", "Lena", "Marie", "Anna",
+		"Laura", "Julia", "Lina", "Clara", "Johanna", "Katharina", "Sabine", "Petra",
+		"Ursula", "Monika", "Claudia", "Susanne", "Birgit", "Jana", "Franziska", "Ingrid",
+	},
+	LastNames: []string{
+		"Müller", "Schmidt", "Schneider", "Fischer", "Weber", "Meyer", "Wagner", "Becker",
+		"Schulz", "Hoffmann", "Schäfer", "Koch", "Bauer", "Richter", "Klein", "Wolf",
+		"Schröder", "Neumann", "Schwarz", "Zimmermann", "Braun", "Krüger", "Hofmann", "Hartmann",
+		"Lange", "Schmitt", "Werner", "Krause", "Meier", "Lehmann",
+	},
+	Streets: []string{
+		"Hauptstraße", "Schulstraße", "Gartenstraße", "Bahnhofstraße", "Dorfstraße", "Bergstraße",
+		"Birkenweg", "Lindenstraße", "Kirchstraße", "Waldstraße", "Ringstraße", "Am Markt",
+		"Schillerstraße", "Goethestraße", "Mühlenweg", "Wiesenweg", "Rosenstraße", "Feldstraße",
+	},
+	Cities: []LocaleCity{
+		{"Berlin", "Berlin", "1####"}, {"Hamburg", "Hamburg", "2####"}, {"München", "Bayern", "80###"},
+		{"Köln", "Nordrhein-Westfalen", "50###"}, {"Frankfurt am Main", "Hessen", "60###"},
+		{"Stuttgart", "Baden-Württemberg", "70###"}, {"Düsseldorf", "Nordrhein-Westfalen", "40###"},
+		{"Leipzig", "Sachsen", "04###"}, {"Dortmund", "Nordrhein-Westfalen", "44###"},
+		{"Essen", "Nordrhein-Westfalen", "45###"}, {"Bremen", "Bremen", "28###"},
+		{"Dresden", "Sachsen", "01###"}, {"Hannover", "Niedersachsen", "30###"},
+		{"Nürnberg", "Bayern", "90###"}, {"Freiburg im Breisgau", "Baden-Württemberg", "79###"},
+	},
+	HouseNumbers:  []string{"#", "##", "##", "##a", "###"},
+	StreetFormat:  "{street} {number}",
+	AddressFormat: "{street}, {postal} {city}",
+}
+
+// LocaleFrFR is the fr_FR locale pack
+var LocaleFrFR = &Locale{
+	Code:      "fr_FR",
+	Countries: []string{"FR", "FRA", "France"},
+	MaleFirstNames: []string{
+		"Gabriel", "Louis", "Raphaël", "Jules", "Adam", "Lucas", "Léo", "Hugo",
+		"Arthur", "Nathan", "Théo", "Paul", "Antoine", "Nicolas", "Julien", "Pierre",
+		"François", "Olivier", "Philippe", "Sébastien", "Mathieu", "Guillaume",
+	},
+	FemaleFirstNames: []string{
+		"Jade", "Louise", "Emma", "Alice", "Chloé", "Léa", "Manon", "Camille",
+		"Inès", "Juliette", "Zoé", "Charlotte", "Margaux", "Élise", "Isabelle", "Nathalie",
+		"Sophie", "Céline", "Aurélie", "Claire", "Hélène", "Amélie",
+	},
+	LastNames: []string{
+		"Martin", "Bernard", "Thomas", "Petit", "Robert", "Richard", "Durand", "Dubois",
+		"Moreau", "Laurent", "Simon", "Michel", "Lefèvre", "Leroy", "Roux", "David",
+		"Bertrand", "Morel", "Fournier", "Girard", "Bonnet", "Dupont", "Lambert", "Fontaine",
+		"Rousseau", "Vincent", "Mercier", "Faure",
+	},
+	Streets: []string{
+		"rue de la Paix", "rue Victor Hugo", "avenue de la République", "rue du Moulin",
+		"boulevard Voltaire", "rue des Lilas", "place de l'Église", "rue Jean Jaurès",
+		"avenue des Champs", "rue de la Gare", "chemin des Vignes", "rue Pasteur",
+		"allée des Tilleuls", "rue du Château", "impasse des Roses", "quai de la Loire",
+	},
+	Cities: []LocaleCity{
+		{"Paris", "Île-de-France", "750##"}, {"Marseille", "Provence-Alpes-Côte d'Azur", "130##"},
+		{"Lyon", "Auvergne-Rhône-Alpes", "6900#"}, {"Toulouse", "Occitanie", "310##"},
+		{"Nice", "Provence-Alpes-Côte d'Azur", "060##"}, {"Nantes", "Pays de la Loire", "440##"},
+		{"Strasbourg", "Grand Est", "670##"}, {"Montpellier", "Occitanie", "340##"},
+		{"Bordeaux", "Nouvelle-Aquitaine", "330##"}, {"Lille", "Hauts-de-France", "590##"},
+		{"Rennes", "Bretagne", "350##"}, {"Reims", "Grand Est", "511##"},
+	},
+	HouseNumbers:  []string{"#", "##", "##", "## bis", "###"},
+	StreetFormat:  "{number} {street}",
+	AddressFormat: "{street}, {postal} {city}",
+}
+
+// LocaleJaJP is the ja_JP locale pack, in romanized Japanese
+var LocaleJaJP = &Locale{
+	Code:      "ja_JP",
+	Countries: []string{"JP", "JPN", "Japan", "Nippon", "Nihon"},
+	MaleFirstNames: []string{
+		"Haruto", "Yuto", "Sota", "Riku", "Hinata", "Minato", "Ren", "Takumi",
+		"Hiroshi", "Takeshi", "Kenji", "Daiki", "Kazuki", "Shota", "Yusuke", "Naoki",
+	},
+	FemaleFirstNames: []string{
+		"Yui", "Hina", "Aoi", "Sakura", "Mei", "Rin", "Yuna", "Himari",
+		"Yoko", "Keiko", "Akiko", "Naomi", "Ayaka", "Misaki", "Haruka", "Emi",
+	},
+	LastNames: []string{
+		"Sato", "Suzuki", "Takahashi", "Tanaka", "Watanabe", "Ito", "Yamamoto", "Nakamura",
+		"Kobayashi", "Kato", "Yoshida", "Yamada", "Sasaki", "Yamaguchi", "Matsumoto", "Inoue",
+		"Kimura", "Hayashi", "Shimizu", "Yamazaki",
+	},
+	Streets: []string{
+		"Ginza", "Nishi-Shinjuku", "Jingumae", "Shibuya", "Marunouchi", "Roppongi",
+		"Umeda", "Namba", "Sakae", "Tenjin", "Kita-Aoyama", "Minami-Azabu",
+	},
+	Cities: []LocaleCity{
+		{"Chiyoda", "Tokyo", "100-####"}, {"Shinjuku", "Tokyo", "160-####"}, {"Shibuya", "Tokyo", "150-####"},
+		{"Minato", "Tokyo", "105-####"}, {"Osaka", "Osaka", "530-####"}, {"Nagoya", "Aichi", "460-####"},
+		{"Yokohama", "Kanagawa", "220-####"}, {"Sapporo", "Hokkaido", "060-####"},
+		{"Fukuoka", "Fukuoka", "810-####"}, {"Kyoto", "Kyoto", "600-####"},
+		{"Kobe", "Hyogo", "650-####"}, {"Sendai", "Miyagi", "980-####"},
+	},
+	HouseNumbers:  []string{"#-#-#", "#-##-#", "#-#-##", "#-##-##"},
+	StreetFormat:  "{number} {street}",
+	AddressFormat: "{street}, {city}, {state} {postal}",
+}
+
+// LocaleEsMX is the es_MX locale pack
+var LocaleEsMX = &Locale{
+	Code:      "es_MX",
+	Countries: []string{"MX", "MEX", "Mexico", "México"},
+	MaleFirstNames: []string{
+		"José", "Juan", "Luis", "Carlos", "Jorge", "Miguel", "Alejandro", "Francisco",
+		"Javier", "Fernando", "Ricardo", "Eduardo", "Santiago", "Mateo", "Diego", "Emiliano",
+	},
+	FemaleFirstNames: []string{
+		"María", "Guadalupe", "Sofía", "Valentina", "Ximena", "Camila", "Fernanda", "Daniela",
+		"Gabriela", "Ana", "Lucía", "Mariana", "Alejandra", "Verónica", "Regina", "Paola",
+	},
+	LastNames: []string{
+		"Hernández", "García", "Martínez", "López", "González", "Pérez", "Rodríguez", "Sánchez",
+		"Ramírez", "Cruz", "Flores", "Gómez", "Morales", "Vázquez", "Reyes", "Jiménez",
+		"Torres", "Díaz", "Gutiérrez", "Ruiz", "Mendoza", "Aguilar",
+	},
+	Streets: []string{
+		"Avenida Reforma", "Calle Hidalgo", "Calle Morelos", "Avenida Juárez", "Calle Madero",
+		"Calle 5 de Mayo", "Avenida Insurgentes", "Calle Zaragoza", "Calle Allende",
+		"Calle Guerrero", "Avenida Revolución", "Calle Independencia",
+	},
+	Cities: []LocaleCity{
+		{"Ciudad de México", "CDMX", "0####"}, {"Guadalajara", "Jalisco", "44###"},
+		{"Monterrey", "Nuevo León", "64###"}, {"Puebla", "Puebla", "72###"},
+		{"Tijuana", "Baja California", "22###"}, {"León", "Guanajuato", "37###"},
+		{"Mérida", "Yucatán", "97###"}, {"Querétaro", "Querétaro", "76###"},
+		{"Cancún", "Quintana Roo", "77###"}, {"Oaxaca de Juárez", "Oaxaca", "68###"},
+	},
+	HouseNumbers:  []string{"#", "##", "###", "####"},
+	StreetFormat:  "{street} {number}",
+	AddressFormat: "{street}, {postal} {city}, {state}",
+}
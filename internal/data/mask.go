@@ -0,0 +1,59 @@
+package data
+
+import (
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaskString hides every letter and digit except the first keepFirst and the
+// last keepLast of them. Separators are kept so the shape stays recognisable,
+// e.g. "123-45-6789" becomes "***-**-6789" with keepLast 4. A value too
+// short to keep both ends, such as a 4-digit PIN with keepLast 4, is masked
+// entirely, and so is one without letters or digits, so the real value is
+// never returned.
+func MaskString(value string, keepFirst, keepLast int, maskChar rune) string {
+	if value == "" {
+		return ""
+	}
+
+	total := 0
+	for _, r := range value {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			total++
+		}
+	}
+	if total == 0 {
+		return strings.Repeat(string(maskChar), utf8.RuneCountInString(value))
+	}
+	if keepFirst+keepLast >= total {
+		keepFirst, keepLast = 0, 0
+	}
+
+	var b strings.Builder
+	seen := 0
+	for _, r := range value {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			b.WriteRune(r)
+			continue
+		}
+		if seen < keepFirst || seen >= total-keepLast {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune(maskChar)
+		}
+		seen++
+	}
+	return b.String()
+}
+
+// GenerateDeterministicToken generates an opaque keyed-hash token for a value
+// Example: "3f9a1c0e7b2d5a64"
+func GenerateDeterministicToken(id, fieldType, value string) string {
+	if value == "" {
+		return ""
+	}
+	hash := hashField(id, "token_"+fieldType, value)
+	return hex.EncodeToString(hash[:])
+}
@@ -17,17 +17,17 @@ var FirstNames = []string{
 	"Douglas", "Virginia", "Zachary", "Julie", "Peter", "Joyce", "Kyle", "Victoria",
 	"Walter", "Kelly", "Harold", "Christina", "Ralph", "Lauren", "Roy", "Joan",
 	"Russell", "Evelyn", "Vincent", "Judith", "Eugene", "Megan", "Carl", "Andrea",
-	"Arthur", "Cheryl", "Ryan", "Hannah", "Roger", "Jacqueline", "Joe", "Martha",
-	"Juan", "Madison", "Jack", "Teresa", "Elmer", "Sara", "Willie", "Sophia",
+	"Arthur", "Cheryl", "Hannah", "Roger", "Jacqueline", "Joe", "Martha",
+	"Juan", "Madison", "Teresa", "Elmer", "Sara", "Willie", "Sophia",
 	"Albert", "Theresa", "Wayne", "Sheryl", "Billy", "Brittany", "Bruce", "Beverly",
-	"Louis", "Denise", "Joe", "Marilyn", "Danny", "Amber", "David", "Danielle",
-	"Harry", "Abigail", "Clint", "Alice", "Mark", "Judy", "Fred", "Kayla",
-	"Daniel", "Alicia", "Tom", "Sophie", "Chris", "Isabella", "Hank", "Olivia",
-	"George", "Ava", "Jeff", "Ariana", "Frank", "Gloria", "Ed", "Aurora",
-	"Jake", "Beverly", "Stan", "Iris", "Oscar", "Ivy", "Vincent", "Jasmine",
+	"Louis", "Denise", "Marilyn", "Danny", "Amber", "Danielle",
+	"Harry", "Abigail", "Clint", "Alice", "Judy", "Fred", "Kayla",
+	"Alicia", "Tom", "Sophie", "Chris", "Isabella", "Hank", "Olivia",
+	"Ava", "Jeff", "Ariana", "Frank", "Gloria", "Ed", "Aurora",
+	"Jake", "Stan", "Iris", "Oscar", "Ivy", "Jasmine",
 	"Miles", "Rose", "Philip", "Violet", "Nolan", "Lily", "Ethan", "Daisy",
 	"Liam", "Hazel", "Noah", "Poppy", "Oliver", "Ruby", "Elijah", "Willow",
-	"Logan", "Lavender", "Mason", "Iris", "Lucas", "Lotus", "Caleb", "Orchid",
+	"Logan", "Lavender", "Mason", "Lucas", "Lotus", "Caleb", "Orchid",
 	// Non-English names with special characters
 	"François", "Amélie", "Jean-Paul", "Solange", "Luc", "Michèle", "André", "Renée",
 	"José", "María", "Francisca", "Juana", "Carlos", "Carlota", "Diego", "Dolores",
@@ -39,7 +39,7 @@ var FirstNames = []string{
 	"Søren", "Åsa", "Jørgen", "Grete", "Niels", "Inger", "Lars", "Ingrid",
 	"Klaus", "Greta", "Hans", "Heidi", "Jürgen", "Gertrude", "Wolfgang", "Margot",
 	"Pierre", "Véronique", "Henri", "Léonie", "Jules", "Estelle", "Maurice", "Thérèse",
-	"Søren", "Ørsted", "Jørgen", "Ørsted", "Svend", "Åse", "Erik", "Asa",
+	"Ørsted", "Svend", "Åse", "Erik", "Asa",
 }
 
 var LastNames = []string{
@@ -51,19 +51,19 @@ var LastNames = []string{
 	"Green", "Adams", "Nelson", "Baker", "Hall", "Rivera", "Campbell", "Mitchell",
 	"Carter", "Roberts",
 	"Phillips", "Evans", "Edwards", "Collins", "Reeves", "Morris", "Murphy", "Cook",
-	"Morgan", "Peterson", "Cooper", "Reed", "Bell", "Kidd", "Rogers", "Morgan",
-	"Peterson", "Marquez", "Kennedy", "Bennett", "Wood", "Barnes", "Ross", "Henderson",
-	"Coleman", "Jenkins", "Perry", "Powell", "Long", "Patterson", "Hughes", "Flores",
+	"Morgan", "Peterson", "Cooper", "Reed", "Bell", "Kidd", "Rogers",
+	"Marquez", "Kennedy", "Bennett", "Wood", "Barnes", "Ross", "Henderson",
+	"Coleman", "Jenkins", "Perry", "Powell", "Long", "Patterson", "Hughes",
 	"Washington", "Butler", "Simmons", "Bryant", "Alexander", "Russell", "Griffin", "Hayes",
 	"Howell", "Deleon", "Rios", "Wade", "Nichols", "Chase", "Salazar", "Vargas",
 	"Walton", "Wilkes", "Benson", "Gould", "Whitmore", "Graves", "Summers", "Hancock",
 	"Holley", "Hatfield", "Holland", "Harmon", "Hunsaker", "Hanson", "Harley", "Hardin",
 	"Hadley", "Hackett", "Hackney", "Hagerman", "Hager", "Haggard", "Haggett", "Hahn",
 	"Haight", "Hair", "Hairston", "Hajj", "Hakimi", "Halbert", "Halch", "Halcomb",
-	"Hale", "Halečin", "Hales", "Haley", "Hali", "Halifax", "Hall", "Hallahan",
+	"Hale", "Halečin", "Hales", "Haley", "Hali", "Halifax", "Hallahan",
 	"Hallam", "Hallaway", "Halle", "Hallead", "Halley", "Hallmark", "Halloway", "Hallum",
 	"Halmes", "Halowell", "Halpenry", "Halpy", "Halsal", "Halsall", "Halse", "Halt",
-	"Halter", "Haltz", "Haluska", "Halvorsen", "Halverson", "Halsey", "Halstead", "Halstead",
+	"Halter", "Haltz", "Haluska", "Halvorsen", "Halverson", "Halsey", "Halstead",
 	"Hambel", "Hamblen", "Hambley", "Hamblin", "Hamby", "Hambysher", "Hamcke", "Hamden",
 	"Hamdy", "Hame", "Hameed", "Hamels", "Hamer", "Hamera", "Hamers", "Hames",
 	"Hamil", "Hamill", "Hamilton", "Hamlin", "Hamm", "Hammack", "Hammans", "Hammar",
@@ -71,14 +71,14 @@ var LastNames = []string{
 	"Hammons", "Hamner", "Hampden", "Hamper", "Hampford", "Hampshire", "Hampstead",
 	"Hampton", "Hamptons", "Hamric", "Hamrick", "Hamshire", "Hamson", "Hamstead",
 	"Hamton", "Hamzawi", "Hanaberry", "Hanafee", "Hanagan", "Hanahan", "Hanako",
-	"Hanberry", "Hancock", "Hand", "Handal", "Handall", "Hande", "Handel", "Handers",
+	"Hanberry", "Hand", "Handal", "Handall", "Hande", "Handel", "Handers",
 	"Handford", "Handforth", "Handke", "Handl", "Handle", "Handley", "Handlin",
 	"Handoko", "Hands", "Handsel", "Handshoe", "Handyman", "Handy", "Hane", "Hanebuth",
 	"Hanekamp", "Hanelman", "Hanesworth", "Hanfield", "Hanford", "Hanforth", "Hang", "Hangar",
 	"Hangas", "Hangers", "Hanglind", "Hangman", "Hangover", "Hangrove", "Hanguk", "Hanham",
-	"Hanhoff", "Hanible", "Hanigan", "Hanihara", "Hanisek", "Hanisek", "Hanison", "Haniver",
+	"Hanhoff", "Hanible", "Hanigan", "Hanihara", "Hanisek", "Hanison", "Haniver",
 	"Hank", "Hanke", "Hanked", "Hanken", "Hanker", "Hankes", "Hankerson", "Hankey",
-	"Hankins", "Hankinson", "Hankinson", "Hankla", "Hankleman", "Hankook", "Hankook",
+	"Hankins", "Hankinson", "Hankla", "Hankleman", "Hankook",
 	"Hankraft", "Hanks", "Hankshaw", "Hankson", "Hankun", "Hanky", "Hanlan", "Hanle",
 	"Hanleigh", "Hanlen", "Hanley", "Hanlin", "Hanlon", "Hanlos", "Hanly", "Hanm",
 	// Non-English names with special characters
@@ -92,8 +92,39 @@ var LastNames = []string{
 	"Żywiecki", "Czarnecki", "Łukasiewicz", "Zieliński", "Wójtowicz", "Kędzierski", "Ętowski", "Żychliński",
 	"Novotný", "Dvořák", "Dušek", "Čapek", "Řehák", "Ředitelný", "Šulc", "Šváb",
 	"Kovács", "Szőcs", "Kővári", "Kovácsová", "Völgyi", "Völker", "Völkl", "Völkert",
-	"Hadjaris", "Hadjós", "Hajós", "Hajdu", "Hajdú", "Hajnal", "Hajós", "Hajdu",
-	"Sørmoen", "Sørlie", "Sørheim", "Sørland", "Sørmoer", "Sørbel", "Sørhus", "Sørland",
+	"Hadjaris", "Hadjós", "Hajós", "Hajdu", "Hajdú", "Hajnal",
+	"Sørmoen", "Sørlie", "Sørheim", "Sørland", "Sørmoer", "Sørbel", "Sørhus",
 	"François", "Flavien", "Florian", "Forêt", "Fosse", "Foulon", "Fouquet", "Fourgault",
 	"Franceschetti", "Francescone", "Francese", "Franceschi", "Francesco", "Francesini", "Francesia", "Francesca",
 }
+
+// MaleFirstNames and FemaleFirstNames are used for personas, whose first name
+// has to agree with their gender
+var MaleFirstNames = []string{
+	"James", "John", "Robert", "Michael", "William", "David", "Richard", "Joseph",
+	"Thomas", "Charles", "Christopher", "Daniel", "Matthew", "Anthony", "Mark", "Donald",
+	"Steven", "Paul", "Andrew", "Joshua", "Kenneth", "Kevin", "Brian", "George",
+	"Edward", "Alexander", "Ryan", "Jacob", "Gary", "Nicholas", "Eric", "Jonathan",
+	"Stephen", "Larry", "Justin", "Scott", "Brandon", "Benjamin", "Samuel", "Raymond",
+	"Patrick", "Jack", "Dennis", "Jerry", "Tyler", "Aaron", "Jose", "Adam",
+	"Henry", "Douglas", "Zachary", "Peter", "Kyle", "Walter", "Harold", "Ralph",
+	"Roy", "Russell", "Vincent", "Eugene", "Carl", "Arthur", "Roger", "Juan",
+	"Albert", "Wayne", "Bruce", "Louis", "Harry", "Frank", "Oscar", "Miles",
+	"Philip", "Nolan", "Ethan", "Liam", "Noah", "Oliver", "Elijah", "Logan",
+	"Mason", "Lucas", "Caleb",
+}
+
+var FemaleFirstNames = []string{
+	"Mary", "Patricia", "Jennifer", "Linda", "Elizabeth", "Barbara", "Susan", "Jessica",
+	"Sarah", "Karen", "Nancy", "Lisa", "Margaret", "Betty", "Sandra", "Ashley",
+	"Dorothy", "Kimberly", "Emily", "Donna", "Michelle", "Carol", "Amanda", "Melissa",
+	"Deborah", "Stephanie", "Cynthia", "Kathleen", "Amy", "Angela", "Shirley", "Anna",
+	"Brenda", "Pamela", "Emma", "Nicole", "Helen", "Samantha", "Katherine", "Christine",
+	"Debra", "Rachel", "Catherine", "Carolyn", "Janet", "Ruth", "Maria", "Heather",
+	"Diane", "Virginia", "Julie", "Joyce", "Victoria", "Kelly", "Christina", "Lauren",
+	"Joan", "Evelyn", "Judith", "Megan", "Andrea", "Cheryl", "Hannah", "Jacqueline",
+	"Martha", "Madison", "Teresa", "Sara", "Sophia", "Theresa", "Brittany", "Beverly",
+	"Denise", "Marilyn", "Amber", "Danielle", "Abigail", "Alice", "Judy", "Kayla",
+	"Alicia", "Sophie", "Isabella", "Olivia", "Ava", "Gloria", "Iris", "Ivy",
+	"Jasmine", "Rose", "Violet", "Lily", "Daisy", "Hazel", "Ruby", "Willow",
+}
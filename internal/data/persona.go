@@ -0,0 +1,87 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Persona is one coherent fake person. Generating the name, email and gender
+// fields of a record from the same Persona makes them agree: the email is
+// built from the name and the gender matches the first name.
+type Persona struct {
+	FirstName  string
+	MiddleName string
+	LastName   string
+	Gender     string
+
+	hash [8]byte
+}
+
+// NewPersona derives a persona from a record id and the real values that
+// identify the person, e.g. their name and email. The same id and values
+// always give the same persona.
+func NewPersona(id string, values ...string) *Persona {
+	return newPersona(MaleFirstNames, FemaleFirstNames, LastNames, id, values)
+}
+
+// NewPersona derives a persona with the locale's names
+func (l *Locale) NewPersona(id string, values ...string) *Persona {
+	return newPersona(l.MaleFirstNames, l.FemaleFirstNames, l.LastNames, id, values)
+}
+
+func newPersona(maleFirstNames, femaleFirstNames, lastNames []string, id string, values []string) *Persona {
+	hash := hashField(id, "persona", strings.Join(values, "\x00"))
+
+	p := &Persona{hash: hash, Gender: "Female"}
+	firstNames := femaleFirstNames
+	if hash[0]%2 == 0 {
+		p.Gender = "Male"
+		firstNames = maleFirstNames
+	}
+	p.FirstName = firstNames[bytesToInt(hash, 1, 1<<16)%len(firstNames)]
+	p.LastName = lastNames[bytesToInt(hash, 3, 1<<16)%len(lastNames)]
+	p.MiddleName = firstNames[int(hash[5])%len(firstNames)]
+	if p.MiddleName == p.FirstName {
+		p.MiddleName = firstNames[(int(hash[5])+1)%len(firstNames)]
+	}
+	return p
+}
+
+// Name returns the first and last name
+func (p *Persona) Name() string {
+	return p.FirstName + " " + p.LastName
+}
+
+// Email returns first.last at one of domains, with the name reduced to ASCII
+func (p *Persona) Email(domains []string) string {
+	if len(domains) == 0 {
+		domains = EmailDomains
+	}
+	domain := selectFromList(p.hash, 6, domains)
+	return fmt.Sprintf("%s.%s@%s", emailLocalPart(p.FirstName), emailLocalPart(p.LastName), domain)
+}
+
+// asciiFolds spells letters with diacritics the way they are usually written
+// in email addresses
+var asciiFolds = map[rune]string{
+	'ä': "ae", 'ö': "oe", 'ü': "ue", 'ß': "ss", 'æ': "ae", 'ø': "o", 'å': "a",
+	'á': "a", 'à': "a", 'â': "a", 'é': "e", 'è': "e", 'ê': "e", 'ë': "e", 'ę': "e",
+	'í': "i", 'î': "i", 'ó': "o", 'ô': "o", 'ő': "o", 'ú': "u", 'ñ': "n",
+	'ç': "c", 'č': "c", 'ř': "r", 'š': "s", 'ž': "z", 'ż': "z", 'ł': "l",
+	'ń': "n", 'ý': "y", 'ą': "a", 'ć': "c", 'ś': "s", 'ź': "z",
+	'ï': "i", 'ì': "i", 'ò': "o", 'ù': "u", 'û': "u", 'ÿ': "y", 'œ': "oe",
+}
+
+// emailLocalPart lower-cases a name and reduces it to ASCII letters and hyphens
+func emailLocalPart(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case r >= 'a' && r <= 'z', r == '-':
+			b.WriteRune(r)
+		default:
+			b.WriteString(asciiFolds[r])
+		}
+	}
+	return b.String()
+}
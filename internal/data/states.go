@@ -11,7 +11,7 @@ var StateProvinces = []string{
 	// Canadian Provinces
 	"AB", "BC", "MB", "NB", "NL", "NS", "ON", "PE", "QC", "SK",
 	// Mexican States (abbreviated)
-	"AGS", "BC", "BCS", "CAM", "COAH", "COL", "CDMX", "DGO", "GTO", "GRO",
+	"AGS", "BCS", "CAM", "COAH", "COL", "CDMX", "DGO", "GTO", "GRO",
 	"HGO", "JAL", "MEX", "MICH", "MOR", "NAY", "OAX", "PUE", "QRO", "QROO",
 	"SLP", "SIN", "SON", "TAB", "TAMPS", "TLAX", "VER", "YUC", "ZAC",
 }
@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"unicode/utf8"
+
+	"simulacrum/internal/rules"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// CSVContentType is the media type for comma-separated values
+	CSVContentType = "text/csv"
+	// TSVContentType is the media type for tab-separated values
+	TSVContentType = "text/tab-separated-values"
+	// MaxCSVRecordSize bounds the memory used for a single CSV record
+	MaxCSVRecordSize = 16 << 20
+)
+
+var (
+	// ErrRecordTooLarge is returned when a CSV record exceeds MaxCSVRecordSize
+	ErrRecordTooLarge = errors.New("csv record too large")
+	// ErrUnterminatedQuote is returned when a quoted CSV field is never closed
+	ErrUnterminatedQuote = errors.New("unterminated quoted field")
+)
+
+// utf8BOM may precede the header of files written by spreadsheet software
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// HandleObscureCSV obscures CSV, or TSV for text/tab-separated-values,
+// streaming rows back as they are processed. The delimiter can be overridden
+// with the "delimiter" query parameter, e.g. ?delimiter=;
+func (o *Obscurer) HandleObscureCSV(c *gin.Context) {
+	comma := ','
+	contentType := CSVContentType
+	if c.ContentType() == TSVContentType {
+		comma = '\t'
+		contentType = TSVContentType
+	}
+	if d := c.Query("delimiter"); d != "" {
+		r, size := utf8.DecodeRuneInString(d)
+		if size != len(d) || !validDelimiter(r) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delimiter"})
+			return
+		}
+		comma = r
+	}
+
+	// Keep reading the request while the response is streamed
+	_ = http.NewResponseController(c.Writer).EnableFullDuplex()
+
+	c.Header("Content-Type", contentType+"; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	err := o.ObscureCSV(c.Request.Body, c.Writer, byte(comma), c.Writer.Flush)
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		_ = c.Error(err)
+		return
+	}
+	// Nothing was sent yet, so the error goes out as JSON instead
+	c.Header("Content-Type", "")
+	if errors.Is(err, ErrRecordTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "CSV record too large"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV"})
+}
+
+// validDelimiter reports whether r can separate CSV fields
+func validDelimiter(r rune) bool {
+	return r < utf8.RuneSelf && r != '"' && r != '\r' && r != '\n'
+}
+
+// csvColumn is how the values of one column are treated
+type csvColumn struct {
+	rule     *rules.Rule
+	excluded bool
+}
+
+// ObscureCSV reads delimited text with a header row from r and writes the
+// obscured rows to w. Columns are matched by header name as if each row were
+// an object in a top-level array, so field rules and paths such as
+// $[*].email both apply. The column named by the first matching IDKeys entry
+// seeds its row. Quoting, line endings and column order are kept; columns
+// whose rule removes them are dropped. flush, if not nil, is called after
+// each chunk of output.
+func (o *Obscurer) ObscureCSV(r io.Reader, w io.Writer, comma byte, flush func()) error {
+	cr := newCSVReader(r, comma)
+
+	if err := cr.next(); err != nil {
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	}
+
+	// Match every column once from the header
+	columns := make([]csvColumn, cr.len())
+	names := make([]string, cr.len())
+	for i := range columns {
+		names[i] = string(cr.field(i))
+		if i == 0 {
+			names[i] = string(bytes.TrimPrefix(cr.field(i), utf8BOM))
+		}
+		path := rules.Path{}.AppendIndex(rules.AnyIndex).AppendKey(names[i])
+		columns[i].rule, columns[i].excluded = o.matchRule(path)
+	}
+
+	// The first configured id key present in the header seeds each row
+	idColumn := -1
+	for _, key := range o.IDKeys {
+		if idColumn = slices.Index(names, key); idColumn >= 0 {
+			break
+		}
+	}
+
+	out := cr.appendRecord(nil, columns, func(i int) []byte { return cr.field(i) })
+
+	for {
+		err := cr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		id := ""
+		if idColumn >= 0 && idColumn < cr.len() {
+			id = string(cr.field(idColumn))
+		}
+		id = o.scopeID(id)
+		o.stats.addRecord()
+		for i := range min(cr.len(), len(columns)) {
+			if columns[i].removed() {
+				o.stats.addField(columns[i].rule)
+			}
+		}
+		sc := o.rowScope(cr, columns, id)
+		out = cr.appendRecord(out, columns, func(i int) []byte {
+			value := cr.field(i)
+			if i >= len(columns) || columns[i].rule == nil || columns[i].excluded {
+				return value
+			}
+			rule := columns[i].rule
+			o.stats.addField(rule)
+			if s, ok := sc.generate(rule, string(value), id); ok {
+				return []byte(s)
+			}
+			return []byte(obscureString(rule, string(value), id, o.uniqueID(rule, id)))
+		})
+
+		if len(out) >= streamFlushSize {
+			if _, err := w.Write(out); err != nil {
+				return err
+			}
+			if flush != nil {
+				flush()
+			}
+			out = out[:0]
+		}
+	}
+
+	if _, err := w.Write(out); err != nil {
+		return err
+	}
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
+
+// csvReader reads CSV records one at a time, remembering which fields were
+// quoted and how each record was terminated so they can be written back the
+// same way
+type csvReader struct {
+	r     *bufio.Reader
+	comma byte
+
+	buf    []byte // field values of the current record, back to back
+	ends   []int  // end offset of each field in buf
+	quoted []bool // whether each field was quoted
+	eol    string // line terminator of the current record
+
+	special string // bytes that force a field to be quoted
+}
+
+func newCSVReader(r io.Reader, comma byte) *csvReader {
+	return &csvReader{
+		r:       bufio.NewReaderSize(r, 64<<10),
+		comma:   comma,
+		special: string([]byte{comma, '"', '\r', '\n'}),
+	}
+}
+
+// len returns the number of fields in the current record
+func (cr *csvReader) len() int {
+	return len(cr.ends)
+}
+
+// field returns the unquoted value of the i-th field of the current record
+func (cr *csvReader) field(i int) []byte {
+	start := 0
+	if i > 0 {
+		start = cr.ends[i-1]
+	}
+	return cr.buf[start:cr.ends[i]]
+}
+
+// next reads the next record, returning io.EOF when there are none left
+func (cr *csvReader) next() error {
+	cr.buf, cr.ends, cr.quoted, cr.eol = cr.buf[:0], cr.ends[:0], cr.quoted[:0], ""
+
+	if _, err := cr.r.Peek(1); err != nil {
+		return err
+	}
+
+	for {
+		quoted, err := cr.readQuoted()
+		if err != nil {
+			return err
+		}
+
+		// Read the unquoted part of the field, or whatever follows a closing quote
+		tail := len(cr.buf)
+		for {
+			b, err := cr.r.ReadByte()
+			if err == io.EOF {
+				cr.endField(quoted)
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			if b == cr.comma {
+				cr.endField(quoted)
+				break
+			}
+			if b == '\n' {
+				cr.eol = "\n"
+				if len(cr.buf) > tail && cr.buf[len(cr.buf)-1] == '\r' {
+					cr.buf = cr.buf[:len(cr.buf)-1]
+					cr.eol = "\r\n"
+				}
+				cr.endField(quoted)
+				return nil
+			}
+			if err := cr.appendByte(b); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// readQuoted reads a quoted field body if the next field starts with a quote
+func (cr *csvReader) readQuoted() (bool, error) {
+	if b, err := cr.r.ReadByte(); err != nil || b != '"' {
+		if err == nil {
+			err = cr.r.UnreadByte()
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		return false, err
+	}
+
+	for {
+		b, err := cr.r.ReadByte()
+		if err == io.EOF {
+			return true, ErrUnterminatedQuote
+		}
+		if err != nil {
+			return true, err
+		}
+
+		if b == '"' {
+			next, err := cr.r.ReadByte()
+			if err == io.EOF {
+				return true, nil
+			}
+			if err != nil {
+				return true, err
+			}
+			if next != '"' {
+				return true, cr.r.UnreadByte()
+			}
+			// A doubled quote is a literal quote
+		}
+		if err := cr.appendByte(b); err != nil {
+			return true, err
+		}
+	}
+}
+
+func (cr *csvReader) appendByte(b byte) error {
+	if len(cr.buf) >= MaxCSVRecordSize {
+		return ErrRecordTooLarge
+	}
+	cr.buf = append(cr.buf, b)
+	return nil
+}
+
+func (cr *csvReader) endField(quoted bool) {
+	cr.ends = append(cr.ends, len(cr.buf))
+	cr.quoted = append(cr.quoted, quoted)
+}
+
+// appendRecord appends the current record to dst with values from value,
+// quoting fields that were quoted or now need to be and skipping removed columns
+func (cr *csvReader) appendRecord(dst []byte, columns []csvColumn, value func(i int) []byte) []byte {
+	first := true
+	for i := range cr.len() {
+		if i < len(columns) && columns[i].removed() {
+			continue
+		}
+		if !first {
+			dst = append(dst, cr.comma)
+		}
+		first = false
+
+		v := value(i)
+		if !cr.quoted[i] && !bytes.ContainsAny(v, cr.special) {
+			dst = append(dst, v...)
+			continue
+		}
+		dst = append(dst, '"')
+		for _, b := range v {
+			if b == '"' {
+				dst = append(dst, '"')
+			}
+			dst = append(dst, b)
+		}
+		dst = append(dst, '"')
+	}
+	return append(dst, cr.eol...)
+}
+
+// rowScope creates the scope shared by the cells of the current row, or
+// returns nil if neither persona mode nor a locale is configured
+func (o *Obscurer) rowScope(cr *csvReader, columns []csvColumn, id string) *recordScope {
+	if !o.scoped() {
+		return nil
+	}
+
+	values := make(map[rules.Kind]string)
+	for i := range min(cr.len(), len(columns)) {
+		col := columns[i]
+		if !col.excluded && scopeKind(col.rule) && len(cr.field(i)) > 0 && values[col.rule.Kind] == "" {
+			values[col.rule.Kind] = string(cr.field(i))
+		}
+	}
+	return o.newScope(id, o.Locale, values)
+}
+
+// removed reports whether the column is dropped from the output
+func (col csvColumn) removed() bool {
+	return !col.excluded && col.rule != nil && col.rule.Strategy == rules.StrategyRemove
+}
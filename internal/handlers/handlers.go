@@ -1,332 +1,145 @@
 package handlers
 
 import (
+	"bufio"
 	"fmt"
 	"io"
 	"net/http"
 
 	"simulacrum/internal/data"
+	"simulacrum/internal/rules"
 
 	"github.com/gin-gonic/gin"
-	"github.com/valyala/fastjson"
 )
 
-// List of fields that should be obscured
-var obscurableFields = map[string]bool{
-	"id":             true,
-	"name":           true,
-	"email":          true,
-	"phone_number":   true,
-	"address":        true,
-	"street":         true,
-	"city":           true,
-	"state":          true,
-	"zip_code":       true,
-	"county":         true,
-	"country":        true,
-	"tax_id":         true,
-	"first_name":     true,
-	"last_name":      true,
-	"middle_name":    true,
-	"date_of_birth":  true,
-	"gender":         true,
-	"ssn":            true,
-	"passport":       true,
-	"driver_license": true,
-	"bank_accounts":  true,
-	"integer_value":  true,
-	"float_value":    true,
+// DefaultIDKeys are the object keys used to identify a record when none are configured
+var DefaultIDKeys = []string{"id"}
+
+// Obscurer obscures generic JSON documents
+type Obscurer struct {
+	// Rules decide which fields are obscured and by which generator
+	Rules *rules.RuleSet
+	// IDKeys are checked in order on every object; the first one present seeds
+	// obscuration of its sibling and nested fields
+	IDKeys []string
+	// Persona generates the name, email and gender fields of each object
+	// from one fake person, so they agree with each other
+	Persona bool
+	// Locale, if set, generates names and addresses in the conventions of a
+	// country, e.g. "Hauptstraße 12, 10115 Berlin" for de_DE
+	Locale *data.Locale
+	// LocaleFromCountry picks the locale of each object from its country
+	// field, falling back to Locale
+	LocaleFromCountry bool
+
+	// tenantKey, if set, scopes record identifiers to a tenant, see ForTenant
+	tenantKey []byte
+	// stats, if set, counts records and transformed values, see WithStats
+	stats *Stats
+	// noEscapes is set when the document has no escape sequences, so object
+	// keys can be written from their decoded form, see rawKeys
+	noEscapes bool
 }
 
-// HandleObscure accepts arbitrary JSON and obscures any recognized fields
-func HandleObscure(c *gin.Context) {
-	// Use fastjson for faster unmarshaling
-	body, err := io.ReadAll(c.Request.Body)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
-		return
-	}
+// ObscurerHandler serves a request with an Obscurer, e.g. (*Obscurer).HandleObscure
+type ObscurerHandler func(*Obscurer, *gin.Context)
 
-	var parser fastjson.Parser
-	v, err := parser.ParseBytes(body)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
-		return
+// NewObscurer creates an Obscurer from a rule set and record identifier keys.
+// A nil rule set uses the built-in rules. data.SetHashKey must have been
+// called before the Obscurer is used.
+func NewObscurer(rs *rules.RuleSet, idKeys []string) *Obscurer {
+	if rs == nil {
+		rs = rules.Default()
 	}
-
-	// Convert fastjson Value to map[string]any
-	req := fastjsonToInterface(v)
-	result := obscureGeneric(req)
-	c.JSON(http.StatusOK, result)
+	if len(idKeys) == 0 {
+		idKeys = DefaultIDKeys
+	}
+	return &Obscurer{Rules: rs, IDKeys: idKeys}
 }
 
-// fastjsonToInterface converts a fastjson.Value to any
-func fastjsonToInterface(v *fastjson.Value) any {
-	switch v.Type() {
-	case fastjson.TypeObject:
-		m := make(map[string]any)
-		obj, _ := v.Object()
-		obj.Visit(func(key []byte, v *fastjson.Value) {
-			m[string(key)] = fastjsonToInterface(v)
-		})
-		return m
-	case fastjson.TypeArray:
-		arr := v.GetArray()
-		result := make([]any, len(arr))
-		for i, item := range arr {
-			result[i] = fastjsonToInterface(item)
-		}
-		return result
-	case fastjson.TypeString:
-		return string(v.GetStringBytes())
-	case fastjson.TypeNumber:
-		// Try to parse as int64 first, then fall back to float64
-		f := v.GetFloat64()
-		if f == float64(int64(f)) {
-			return int64(f)
+// CheckDictionaries verifies that the dictionaries named by word rules exist.
+// Dictionaries are loaded before rules, so a misspelled name is reported at
+// startup rather than generating tokens.
+func CheckDictionaries(rs *rules.RuleSet) error {
+	for i, rule := range rs.Rules {
+		if rule.Kind != rules.KindWord || rule.Strategy != rules.StrategyGenerate {
+			continue
+		}
+		if name := rule.Option("dictionary", ""); name != "" {
+			if _, ok := data.Dictionary(name); !ok {
+				return fmt.Errorf("rule %d (%s): unknown dictionary %q", i, rule.Kind, name)
+			}
 		}
-		return f
-	case fastjson.TypeTrue:
-		return true
-	case fastjson.TypeFalse:
-		return false
-	case fastjson.TypeNull:
-		return nil
-	default:
-		return nil
 	}
+	return nil
 }
 
-// obscureGeneric recursively processes a generic structure and obscures known fields
-func obscureGeneric(input any) any {
-	switch v := input.(type) {
-	case map[string]any:
-		return obscureMap(v)
-	case []any:
-		return obscureArray(v)
-	default:
-		return input
-	}
+// ForTenant returns a copy of the Obscurer whose fakes are private to the
+// holder of key: the same input yields different values for different keys
+func (o *Obscurer) ForTenant(key []byte) *Obscurer {
+	scoped := *o
+	scoped.tenantKey = key
+	return &scoped
 }
 
-// obscureMap processes a map and obscures known fields
-func obscureMap(m map[string]any) map[string]any {
-	result := make(map[string]any)
-
-	for key, value := range m {
-		if obscurableFields[key] {
-			result[key] = obscureField(key, value)
-		} else {
-			// For unknown fields, recursively process if they're nested structures
-			result[key] = obscureGeneric(value)
-		}
+// scopeID scopes a record identifier to the tenant, if any
+func (o *Obscurer) scopeID(id string) string {
+	if o.tenantKey == nil {
+		return id
 	}
-
-	return result
+	return data.ScopeID(o.tenantKey, id)
 }
 
-// obscureArray processes an array and obscures each element
-func obscureArray(arr []any) []any {
-	result := make([]any, len(arr))
-	for i, item := range arr {
-		result[i] = obscureGeneric(item)
+// uniqueID returns the seed of the values a rule keeps distinct. Unique rules
+// use one that is the same for every record, so a value's fake doesn't depend
+// on the record it is in, but is still private to the tenant. Only the
+// identifying numbers of passports, licenses and bank accounts use it; their
+// dates, names and amounts stay seeded by the record.
+func (o *Obscurer) uniqueID(rule *rules.Rule, id string) string {
+	if !rule.Unique() {
+		return id
 	}
-	return result
+	return o.scopeID("")
 }
 
-// obscureField applies field-specific obscuration logic
-func obscureField(fieldName string, value any) any {
-	// Get the ID for deterministic hashing - we'll look for it in the parent context
-	// For now, use empty string as fallback (this will be improved when we have context)
-	id := ""
-
-	switch fieldName {
-	case "id":
-		// ID is typically kept but could be hashed if needed
-		if str, ok := value.(string); ok {
-			return str
-		}
-	case "name":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicName(id, str)
-		}
-	case "first_name":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicFirstName(id, str)
-		}
-	case "last_name":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicLastName(id, str)
-		}
-	case "middle_name":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicMiddleName(id, str)
-		}
-	case "street":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicStreet(id, str)
-		}
-	case "email":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicEmail(id, str)
-		}
-	case "phone_number":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicPhone(id, str)
-		}
-	case "address":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicAddress(id, str)
-		}
-	case "city":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicCity(id, str)
-		}
-	case "state":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicState(id, str)
-		}
-	case "zip_code":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicZipCode(id, str)
-		}
-	case "county":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicCounty(id, str)
-		}
-	case "country":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicCountry(id, str)
-		}
-	case "tax_id":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicTaxID(id, str)
-		}
-	case "ssn":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicSSN(id, str)
-		}
-	case "date_of_birth":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicDateOfBirth(id, str)
-		}
-	case "gender":
-		if str, ok := value.(string); ok {
-			return data.GenerateDeterministicGender(id, str)
-		}
-	case "integer_value":
-		if num, ok := toInt64(value); ok {
-			return data.GenerateDeterministicInteger(id, num)
-		}
-	case "float_value":
-		if fval, ok := toFloat64(value); ok {
-			return data.GenerateDeterministicFloat(id, fval)
-		}
-	case "passport":
-		return obscurePassport(value, id)
-	case "driver_license":
-		return obscureDriverLicense(value, id)
-	case "bank_accounts":
-		return obscureBankAccounts(value, id)
-	}
+var defaultObscurer = NewObscurer(nil, nil)
 
-	return value
+// HandleObscure accepts arbitrary JSON and obscures any recognized fields
+// using the default settings
+func HandleObscure(c *gin.Context) {
+	defaultObscurer.HandleObscure(c)
 }
 
-// Helper functions for type conversion
-func toInt64(v any) (int64, bool) {
-	switch val := v.(type) {
-	case float64:
-		return int64(val), true
-	case int64:
-		return val, true
-	case int:
-		return int64(val), true
-	default:
-		return 0, false
+// HandleObscure accepts arbitrary JSON and obscures any recognized fields
+func (o *Obscurer) HandleObscure(c *gin.Context) {
+	switch c.ContentType() {
+	case NDJSONContentType:
+		o.HandleObscureNDJSON(c)
+		return
+	case CSVContentType, TSVContentType:
+		o.HandleObscureCSV(c)
+		return
 	}
-}
 
-func toFloat64(v any) (float64, bool) {
-	switch val := v.(type) {
-	case float64:
-		return val, true
-	case int64:
-		return float64(val), true
-	case int:
-		return float64(val), true
-	default:
-		return 0, false
+	// Top-level arrays are streamed element by element instead of being
+	// parsed as a whole
+	body := bufio.NewReaderSize(c.Request.Body, 64<<10)
+	if b, err := peekNonSpace(body); err == nil && b == '[' {
+		o.handleObscureArray(c, body)
+		return
 	}
-}
 
-// Helper function to obscure passport data
-func obscurePassport(value any, id string) any {
-	if m, ok := value.(map[string]any); ok {
-		result := make(map[string]any)
-		for k, v := range m {
-			if str, ok := v.(string); ok {
-				result[k] = data.GenerateDeterministicPassportNumber(id+fmt.Sprintf("passport_%s_", k), str)
-			} else {
-				result[k] = v
-			}
-		}
-		return result
+	input, err := io.ReadAll(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
+		return
 	}
-	return value
-}
 
-// Helper function to obscure driver license data
-func obscureDriverLicense(value any, id string) any {
-	if m, ok := value.(map[string]any); ok {
-		result := make(map[string]any)
-		for k, v := range m {
-			if str, ok := v.(string); ok {
-				result[k] = data.GenerateDeterministicDriverLicenseNumber(id+fmt.Sprintf("license_%s_", k), str)
-			} else {
-				result[k] = v
-			}
-		}
-		return result
+	result, err := o.ObscureJSON(nil, input)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
 	}
-	return value
-}
 
-// Helper function to obscure bank accounts
-func obscureBankAccounts(value any, id string) any {
-	if arr, ok := value.([]any); ok {
-		result := make([]any, len(arr))
-		for i, item := range arr {
-			if m, ok := item.(map[string]any); ok {
-				obscured := make(map[string]any)
-				for k, v := range m {
-					if str, ok := v.(string); ok {
-						switch k {
-						case "name":
-							obscured[k] = data.GenerateDeterministicAccountName(id, str, i)
-						case "amount":
-							obscured[k] = data.GenerateDeterministicAmount(id, str, i)
-						case "account_number":
-							obscured[k] = data.GenerateDeterministicAccountNumber(id, str, i)
-						case "balance":
-							obscured[k] = data.GenerateDeterministicBalance(id, str, i)
-						case "credit_card_number":
-							obscured[k] = data.GenerateDeterministicCreditCardNumber(id, str, i)
-						case "routing_number":
-							obscured[k] = data.GenerateDeterministicRoutingNumber(id, str, i)
-						default:
-							obscured[k] = v
-						}
-					} else {
-						obscured[k] = v
-					}
-				}
-				result[i] = obscured
-			} else {
-				result[i] = item
-			}
-		}
-		return result
-	}
-	return value
+	c.Data(http.StatusOK, "application/json; charset=utf-8", result)
 }
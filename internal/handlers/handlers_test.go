@@ -1,17 +1,33 @@
 package handlers
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"slices"
 	"strings"
 	"testing"
 
+	"simulacrum/internal/auth"
+	"simulacrum/internal/data"
+	"simulacrum/internal/rules"
+
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 )
 
+func TestMain(m *testing.M) {
+	if err := data.SetHashKey(bytes.Repeat([]byte("t"), data.MinHashKeyLength)); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
 func TestHandleObscureGenericSingleField(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -489,3 +505,1422 @@ func TestHandleObscurePhoneWithoutCountryCode(t *testing.T) {
 		t.Errorf("Expected phone without country code, got %s", phone)
 	}
 }
+
+func TestHandleObscureRecordIDSeedsFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+
+	reqBody := map[string]any{
+		"people": []any{
+			map[string]any{"id": "user_1", "name": "John Doe"},
+			map[string]any{"id": "user_2", "name": "John Doe"},
+		},
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest("POST", "/obscure", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+
+	people := result["people"].([]any)
+	name1 := people[0].(map[string]any)["name"]
+	name2 := people[1].(map[string]any)["name"]
+
+	// Each record should match the typed API seeded with the same id
+	if name1 != data.GenerateDeterministicName("user_1", "John Doe") {
+		t.Errorf("Expected name seeded with user_1, got %v", name1)
+	}
+	if name2 != data.GenerateDeterministicName("user_2", "John Doe") {
+		t.Errorf("Expected name seeded with user_2, got %v", name2)
+	}
+}
+
+func TestHandleObscureNestedObjectInheritsRecordID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+
+	reqBody := map[string]any{
+		"id": "user123",
+		"contact": map[string]any{
+			"email": "john@example.com",
+		},
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest("POST", "/obscure", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+
+	email := result["contact"].(map[string]any)["email"]
+	if email != data.GenerateDeterministicEmail("user123", "john@example.com") {
+		t.Errorf("Expected nested email seeded with parent id, got %v", email)
+	}
+}
+
+func TestHandleObscureCustomIDKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", NewObscurer(nil, []string{"customer_uuid", "user_id"}).HandleObscure)
+
+	reqBody := map[string]any{
+		"user_id":       "u-1",
+		"customer_uuid": "c-1",
+		"ssn":           "123-45-6789",
+		"orders": []any{
+			map[string]any{"user_id": 42, "city": "Springfield"},
+		},
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest("POST", "/obscure", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+
+	// customer_uuid is listed first so it wins over user_id
+	if result["ssn"] != data.GenerateDeterministicSSN("c-1", "123-45-6789") {
+		t.Errorf("Expected ssn seeded with customer_uuid, got %v", result["ssn"])
+	}
+
+	// Numeric identifiers are used as their decimal representation
+	order := result["orders"].([]any)[0].(map[string]any)
+	if order["city"] != data.GenerateDeterministicCity("42", "Springfield") {
+		t.Errorf("Expected city seeded with numeric user_id, got %v", order["city"])
+	}
+}
+
+func TestHandleObscurePassportMatchesTypedAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+
+	reqBody := map[string]any{
+		"id": "user123",
+		"passport": map[string]any{
+			"number":          "ABC123456",
+			"issue_date":      "2020-01-01",
+			"expiration_date": "2030-01-01",
+		},
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest("POST", "/obscure", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+
+	expected := data.ObscurePassport("user123", &data.Passport{
+		Number:         "ABC123456",
+		IssueDate:      "2020-01-01",
+		ExpirationDate: "2030-01-01",
+	})
+	passport := result["passport"].(map[string]any)
+	if passport["number"] != expected.Number {
+		t.Errorf("Expected passport number %s, got %v", expected.Number, passport["number"])
+	}
+	if passport["issue_date"] != expected.IssueDate {
+		t.Errorf("Expected issue date %s, got %v", expected.IssueDate, passport["issue_date"])
+	}
+	if passport["expiration_date"] != expected.ExpirationDate {
+		t.Errorf("Expected expiration date %s, got %v", expected.ExpirationDate, passport["expiration_date"])
+	}
+}
+
+func TestHandleObscureCustomRules(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: first_name
+    fields: [firstName]
+  - kind: email
+    patterns: ["*_email", "e_mail"]
+    options:
+      domains: corp.test
+  - kind: phone
+    fields: [mobile]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", NewObscurer(rs, nil).HandleObscure)
+
+	reqBody := map[string]any{
+		"id":         "user123",
+		"firstName":  "John",
+		"work_email": "john@example.com",
+		"mobile":     "555-987-6543",
+		"name":       "Widget",
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest("POST", "/obscure", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+
+	if result["firstName"] != data.GenerateDeterministicFirstName("user123", "John") {
+		t.Errorf("Expected firstName to be obscured as a first name, got %v", result["firstName"])
+	}
+	if email, ok := result["work_email"].(string); !ok || !strings.HasSuffix(email, "@corp.test") {
+		t.Errorf("Expected work_email to use the configured domain, got %v", result["work_email"])
+	}
+	if result["mobile"] == "555-987-6543" {
+		t.Errorf("Expected mobile to be obscured")
+	}
+
+	// Fields not covered by the rules file pass through
+	if result["name"] != "Widget" {
+		t.Errorf("Expected name to pass through, got %v", result["name"])
+	}
+}
+
+func TestHandleObscurePathRulesAndExclusions(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+exclude:
+  - $.products[*].name
+rules:
+  - kind: name
+    fields: [name]
+  - kind: street
+    paths: [orders.*.shipping.street]
+  - kind: email
+    paths: ["$.contacts[*]"]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", NewObscurer(rs, nil).HandleObscure)
+
+	reqBody := map[string]any{
+		"id":       "user123",
+		"name":     "John Doe",
+		"products": []any{map[string]any{"name": "Widget"}},
+		"orders": []any{
+			map[string]any{
+				"shipping": map[string]any{"street": "123 Main St"},
+				"billing":  map[string]any{"street": "456 Oak Ave"},
+			},
+		},
+		"contacts": []any{"john@example.com"},
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest("POST", "/obscure", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+
+	if result["name"] == "John Doe" {
+		t.Errorf("Expected top-level name to be obscured")
+	}
+
+	product := result["products"].([]any)[0].(map[string]any)
+	if product["name"] != "Widget" {
+		t.Errorf("Expected excluded product name to pass through, got %v", product["name"])
+	}
+
+	order := result["orders"].([]any)[0].(map[string]any)
+	if street := order["shipping"].(map[string]any)["street"]; street == "123 Main St" {
+		t.Errorf("Expected shipping street to be obscured")
+	}
+	if street := order["billing"].(map[string]any)["street"]; street != "456 Oak Ave" {
+		t.Errorf("Expected billing street to pass through, got %v", street)
+	}
+
+	if contact := result["contacts"].([]any)[0]; contact != data.GenerateDeterministicEmail("user123", "john@example.com") {
+		t.Errorf("Expected array element to be obscured by path rule, got %v", contact)
+	}
+}
+
+func TestHandleObscureStrategies(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: ssn
+    fields: [ssn]
+    strategy: mask
+  - fields: [credit_card_number]
+    strategy: mask
+    options:
+      keep_first: "1"
+      keep_last: "4"
+  - fields: [notes]
+    strategy: redact
+  - fields: [tax_id]
+    strategy: nullify
+  - fields: [passport]
+    strategy: remove
+  - kind: email
+    fields: [email]
+    strategy: hash
+  - fields: [name]
+    strategy: keep
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", NewObscurer(rs, nil).HandleObscure)
+
+	reqBody := map[string]any{
+		"id":                 "user123",
+		"ssn":                "123-45-6789",
+		"credit_card_number": "4111111111111111",
+		"notes":              "VIP customer",
+		"tax_id":             "98-7654321",
+		"passport":           map[string]any{"number": "ABC123456"},
+		"email":              "john@example.com",
+		"name":               "John Doe",
+	}
+	body, _ := json.Marshal(reqBody)
+
+	req := httptest.NewRequest("POST", "/obscure", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var result map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+
+	if result["ssn"] != "***-**-6789" {
+		t.Errorf("Expected masked ssn, got %v", result["ssn"])
+	}
+	if result["credit_card_number"] != "4***********1111" {
+		t.Errorf("Expected masked card, got %v", result["credit_card_number"])
+	}
+	if result["notes"] != DefaultRedactText {
+		t.Errorf("Expected redacted notes, got %v", result["notes"])
+	}
+	if v, ok := result["tax_id"]; !ok || v != nil {
+		t.Errorf("Expected tax_id to be null, got %v", v)
+	}
+	if _, ok := result["passport"]; ok {
+		t.Errorf("Expected passport to be removed")
+	}
+	if result["email"] != data.GenerateDeterministicToken("user123", "email", "john@example.com") {
+		t.Errorf("Expected hashed email token, got %v", result["email"])
+	}
+	if result["name"] != "John Doe" {
+		t.Errorf("Expected name to be kept, got %v", result["name"])
+	}
+}
+
+func TestHandleObscurePreservesOrderAndPrecision(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+
+	body := `{"zeta":1,"id":"user123","big":12345678901234567890,"price":19.990,"name":"John Doe","esc":"café \"q\"","alpha":[1.50,true,null]}`
+
+	req := httptest.NewRequest("POST", "/obscure", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+
+	name, _ := json.Marshal(data.GenerateDeterministicName("user123", "John Doe"))
+	expected := `{"zeta":1,"id":"user123","big":12345678901234567890,"price":19.990,"name":` + string(name) + `,"esc":"café \"q\"","alpha":[1.50,true,null]}`
+	if w.Body.String() != expected {
+		t.Errorf("Expected untouched values verbatim and in order:\n%s\ngot:\n%s", expected, w.Body.String())
+	}
+}
+
+func TestObscureJSONPreservesEscapes(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: name
+    fields: [name]
+  - kind: country
+    fields: [country]
+    strategy: keep
+  - kind: ssn
+    fields: [secret]
+    strategy: mask
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	o := NewObscurer(rs, nil)
+
+	input := `{"id":"caf\u00e9","k\u00e9y":"a\/b","name":"Jo\u00EBl","country":"Espa\u00f1a",` +
+		`"nested":{"\"q\"":[1,"\t"],"x":{"y\/z":true}},"secret":{"p\u00edn":"12\/34"}}`
+	name, _ := json.Marshal(data.GenerateDeterministicName("café", "Joël"))
+	want := `{"id":"caf\u00e9","k\u00e9y":"a\/b","name":` + string(name) + `,"country":"Espa\u00f1a",` +
+		`"nested":{"\"q\"":[1,"\t"],"x":{"y\/z":true}},"secret":{"p\u00edn":"` + data.MaskString("12/34", 0, 4, '*') + `"}}`
+
+	got, err := o.ObscureJSON(nil, []byte(input))
+	if err != nil {
+		t.Fatalf("ObscureJSON failed: %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("Expected untouched escapes verbatim:\n%s\ngot:\n%s", want, got)
+	}
+}
+
+func TestHandleObscureLargeIntegerID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+
+	body := `[{"id":9007199254740993,"ssn":"123-45-6789"},{"id":9007199254740992,"ssn":"123-45-6789"}]`
+
+	req := httptest.NewRequest("POST", "/obscure", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	var result []map[string]any
+	json.Unmarshal(w.Body.Bytes(), &result)
+
+	// Identifiers beyond 2^53 must not collapse onto the same seed
+	if result[0]["ssn"] != data.GenerateDeterministicSSN("9007199254740993", "123-45-6789") {
+		t.Errorf("Expected ssn seeded with exact id, got %v", result[0]["ssn"])
+	}
+	if result[0]["ssn"] == result[1]["ssn"] {
+		t.Errorf("Expected distinct ids to produce distinct values")
+	}
+}
+
+func TestHandleObscureInvalidJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+
+	req := httptest.NewRequest("POST", "/obscure", strings.NewReader(`{"name":`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+}
+
+func TestAppendJSONString(t *testing.T) {
+	tests := map[string]string{
+		"plain":       `"plain"`,
+		`quote"back\`: `"quote\"back\\"`,
+		"line\nbreak": `"line\nbreak"`,
+		"ctrl\x01":    `"ctrl\u0001"`,
+		"Müller":      `"Müller"`,
+		"bad\xffbyte": "\"bad�byte\"",
+	}
+	for input, want := range tests {
+		if got := string(appendJSONString(nil, input)); got != want {
+			t.Errorf("appendJSONString(%q): expected %s, got %s", input, want, got)
+		}
+	}
+}
+
+func TestHandleObscureNDJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure/ndjson", defaultObscurer.HandleObscureNDJSON)
+
+	body := "{\"id\":\"u1\",\"name\":\"John Doe\"}\r\n" +
+		"\n" +
+		"{\"id\":\"u2\",\"name\":\n" +
+		"{\"id\":\"u3\",\"ssn\":\"123-45-6789\"}"
+	req := httptest.NewRequest("POST", "/obscure/ndjson", strings.NewReader(body))
+	req.Header.Set("Content-Type", NDJSONContentType)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != NDJSONContentType {
+		t.Errorf("Expected content type %s, got %s", NDJSONContentType, ct)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(w.Body.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 output lines, got %d: %q", len(lines), w.Body.String())
+	}
+
+	var first map[string]any
+	json.Unmarshal([]byte(lines[0]), &first)
+	if first["name"] != data.GenerateDeterministicName("u1", "John Doe") {
+		t.Errorf("Expected obscured name on line 1, got %v", first["name"])
+	}
+
+	// The bad record reports its line number and the stream carries on
+	if lines[1] != `{"error":"invalid JSON","line":3}` {
+		t.Errorf("Expected per-line error, got %s", lines[1])
+	}
+
+	var last map[string]any
+	json.Unmarshal([]byte(lines[2]), &last)
+	if last["ssn"] != data.GenerateDeterministicSSN("u3", "123-45-6789") {
+		t.Errorf("Expected obscured ssn on line 4, got %v", last["ssn"])
+	}
+}
+
+func TestHandleObscureNDJSONContentType(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+
+	req := httptest.NewRequest("POST", "/obscure", strings.NewReader("{\"name\":\"A\"}\n{\"name\":\"B\"}\n"))
+	req.Header.Set("Content-Type", NDJSONContentType)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", w.Code)
+	}
+	if n := strings.Count(w.Body.String(), "\n"); n != 2 {
+		t.Errorf("Expected 2 NDJSON records, got %d: %q", n, w.Body.String())
+	}
+}
+
+func TestReadLineTooLong(t *testing.T) {
+	r := bufio.NewReaderSize(strings.NewReader(strings.Repeat("x", 100)+"\nshort\n"), 16)
+
+	line, tooLong, err := readLine(r, nil, 50)
+	if err != nil || !tooLong || len(line) != 0 {
+		t.Fatalf("Expected oversized line to be dropped, got %q tooLong=%v err=%v", line, tooLong, err)
+	}
+
+	line, tooLong, err = readLine(r, line, 50)
+	if err != nil || tooLong || string(line) != "short" {
+		t.Errorf("Expected next line to be read intact, got %q tooLong=%v err=%v", line, tooLong, err)
+	}
+}
+
+func TestObscureJSONArrayMatchesWholeDocument(t *testing.T) {
+	input := ` [ {"id":"a1","name":"John Doe","note":"[not, an] \"array\" {"},
+		"Jane Roe", 42, -1.5e3, true, null, [ {"email":"x@y.com"} ],
+		{"id":7,"address":{"street":"1 Main St","city":"Paris"}} ] `
+
+	want, err := defaultObscurer.ObscureJSON(nil, []byte(input))
+	if err != nil {
+		t.Fatalf("ObscureJSON failed: %v", err)
+	}
+
+	var got bytes.Buffer
+	flushes := 0
+	if err := defaultObscurer.ObscureJSONArray(strings.NewReader(input), &got, func() { flushes++ }); err != nil {
+		t.Fatalf("ObscureJSONArray failed: %v", err)
+	}
+
+	if got.String() != string(want) {
+		t.Errorf("Expected streamed output to match whole document\nwant %s\ngot  %s", want, got.String())
+	}
+	if flushes == 0 {
+		t.Errorf("Expected output to be flushed")
+	}
+}
+
+func TestObscureJSONArrayPathRules(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - strategy: remove
+    paths: ["$[1]"]
+  - kind: name
+    paths: ["$[*].who"]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	o := NewObscurer(rs, nil)
+
+	var got bytes.Buffer
+	if err := o.ObscureJSONArray(strings.NewReader(`[{"who":"John"},{"who":"Jane"},[]]`), &got, nil); err != nil {
+		t.Fatalf("ObscureJSONArray failed: %v", err)
+	}
+
+	want := fmt.Sprintf(`[{"who":%q},null,[]]`, data.GenerateDeterministicName("", "John"))
+	if got.String() != want {
+		t.Errorf("Expected %s, got %s", want, got.String())
+	}
+}
+
+func TestObscureJSONArrayInvalid(t *testing.T) {
+	inputs := []string{
+		`[`,
+		`[1,,2]`,
+		`[1 2]`,
+		`[{"a":1}`,
+		`[{"a":}]`,
+		`[1] x`,
+		`{"a":1}`,
+	}
+	for _, input := range inputs {
+		if err := defaultObscurer.ObscureJSONArray(strings.NewReader(input), io.Discard, nil); err == nil {
+			t.Errorf("Expected error for %s", input)
+		}
+	}
+
+	var got bytes.Buffer
+	if err := defaultObscurer.ObscureJSONArray(strings.NewReader(" [ ] \n"), &got, nil); err != nil || got.String() != "[]" {
+		t.Errorf("Expected empty array, got %q (err %v)", got.String(), err)
+	}
+}
+
+func TestReadJSONValueTooLarge(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader(`{"name":"` + strings.Repeat("x", 100) + `"}]`))
+	if _, err := readJSONValue(r, nil, 50); err != ErrElementTooLarge {
+		t.Errorf("Expected ErrElementTooLarge, got %v", err)
+	}
+}
+
+func TestHandleObscureStreamedArrayInvalidJSON(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+
+	req := httptest.NewRequest("POST", "/obscure", strings.NewReader(`[{"name":"John"},{"name":`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "Invalid JSON") {
+		t.Errorf("Expected Invalid JSON error, got %s", w.Body.String())
+	}
+}
+
+func TestObscureCSV(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: name
+    fields: [name]
+  - kind: ssn
+    fields: [ssn]
+  - strategy: remove
+    fields: [notes]
+  - strategy: redact
+    paths: ["$[*].secret"]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	o := NewObscurer(rs, []string{"customer_id", "id"})
+
+	input := "\xef\xbb\xbfid,\"name\",notes,ssn,customer_id,secret,city\r\n" +
+		"1,\"Doe, John\",\"multi\nline\",123-45-6789,c1,x,\"Paris\"\r\n" +
+		"2,Jane,,,c2,y,Rome\r\n" +
+		"3,Short\r\n"
+
+	var got bytes.Buffer
+	if err := o.ObscureCSV(strings.NewReader(input), &got, ',', nil); err != nil {
+		t.Fatalf("ObscureCSV failed: %v", err)
+	}
+
+	want := "\xef\xbb\xbfid,\"name\",ssn,customer_id,secret,city\r\n" +
+		fmt.Sprintf("1,%s,%s,c1,[REDACTED],\"Paris\"\r\n",
+			csvQuote(data.GenerateDeterministicName("c1", "Doe, John")),
+			data.GenerateDeterministicSSN("c1", "123-45-6789")) +
+		fmt.Sprintf("2,%s,%s,c2,[REDACTED],Rome\r\n",
+			data.GenerateDeterministicName("c2", "Jane"),
+			data.GenerateDeterministicSSN("c2", "")) +
+		fmt.Sprintf("3,%s\r\n", data.GenerateDeterministicName("", "Short"))
+	if got.String() != want {
+		t.Errorf("Unexpected CSV output\nwant %q\ngot  %q", want, got.String())
+	}
+}
+
+// csvQuote quotes s the way ObscureCSV writes a field that was quoted
+func csvQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+}
+
+func TestObscureCSVQuoting(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - strategy: redact
+    fields: [a]
+    options:
+      text: 'say "hi"; bye'
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	o := NewObscurer(rs, nil)
+
+	var got bytes.Buffer
+	if err := o.ObscureCSV(strings.NewReader("a;b\nx;\"he said \"\"no\"\"\"\n"), &got, ';', nil); err != nil {
+		t.Fatalf("ObscureCSV failed: %v", err)
+	}
+
+	// The new value needs quoting; the untouched one keeps its escaped quotes
+	want := "a;b\n\"say \"\"hi\"\"; bye\";\"he said \"\"no\"\"\"\n"
+	if got.String() != want {
+		t.Errorf("Expected %q, got %q", want, got.String())
+	}
+
+	if err := o.ObscureCSV(strings.NewReader("a,b\n\"open,1\n"), io.Discard, ',', nil); err != ErrUnterminatedQuote {
+		t.Errorf("Expected ErrUnterminatedQuote, got %v", err)
+	}
+}
+
+func TestHandleObscureCSV(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", HandleObscure)
+	router.POST("/obscure/csv", defaultObscurer.HandleObscureCSV)
+
+	tests := []struct {
+		url, contentType, body, want, wantType string
+	}{
+		{"/obscure", TSVContentType, "id\tcity\n1\tParis\n", "id\tcity\n1\t" + data.GenerateDeterministicCity("1", "Paris") + "\n", TSVContentType},
+		{"/obscure/csv", "", "id,city\n1,Paris\n", "id,city\n1," + data.GenerateDeterministicCity("1", "Paris") + "\n", CSVContentType},
+		{"/obscure/csv?delimiter=|", CSVContentType, "id|city\n1|Paris\n", "id|city\n1|" + data.GenerateDeterministicCity("1", "Paris") + "\n", CSVContentType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", tt.url, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tt.url, w.Code)
+		}
+		if w.Body.String() != tt.want {
+			t.Errorf("%s: expected %q, got %q", tt.url, tt.want, w.Body.String())
+		}
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, tt.wantType) {
+			t.Errorf("%s: expected content type %s, got %s", tt.url, tt.wantType, ct)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/obscure/csv?delimiter=%22", strings.NewReader("a\n"))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for a quote delimiter, got %d", w.Code)
+	}
+
+	req = httptest.NewRequest("POST", "/obscure/csv", strings.NewReader("a,b\n\"open,1\n"))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for invalid CSV, got %d", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Errorf("Expected the invalid CSV error as JSON, got content type %s", ct)
+	}
+}
+
+func TestProfilesSelectByScope(t *testing.T) {
+	export, err := rules.Parse([]byte(`
+rules:
+  - strategy: redact
+    fields: [name]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	profiles, err := NewProfiles(map[string]*Obscurer{
+		"qa":     NewObscurer(nil, nil),
+		"export": NewObscurer(export, nil),
+	}, "qa")
+	if err != nil {
+		t.Fatalf("Failed to create profiles: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	newRouter := func(claims jwt.MapClaims) *gin.Engine {
+		router := gin.New()
+		router.POST("/obscure", func(c *gin.Context) {
+			c.Set("jwt_claims", claims)
+		}, profiles.Handle((*Obscurer).HandleObscure))
+		return router
+	}
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		query  string
+		status int
+		want   string
+	}{
+		{"default profile", jwt.MapClaims{"scope": "obscure:qa obscure:export"}, "", http.StatusOK, ""},
+		{"named profile", jwt.MapClaims{"scope": "obscure:qa obscure:export"}, "?profile=export", http.StatusOK, "[REDACTED]"},
+		{"only granted profile", jwt.MapClaims{"roles": []any{"obscure:export"}}, "", http.StatusOK, "[REDACTED]"},
+		{"profile outside scopes", jwt.MapClaims{"scp": []any{"obscure:qa"}}, "?profile=export", http.StatusForbidden, ""},
+		{"unknown profile", jwt.MapClaims{"scope": "obscure:qa obscure:prod"}, "?profile=prod", http.StatusForbidden, ""},
+		{"no profile scopes", jwt.MapClaims{"scope": "read write"}, "", http.StatusForbidden, ""},
+		{"no claims", nil, "", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/obscure"+tt.query, strings.NewReader(`{"id":"user123","name":"John Doe"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		newRouter(tt.claims).ServeHTTP(w, req)
+
+		if w.Code != tt.status {
+			t.Errorf("%s: expected status %d, got %d: %s", tt.name, tt.status, w.Code, w.Body.String())
+			continue
+		}
+		if w.Code != http.StatusOK {
+			continue
+		}
+		var result map[string]any
+		json.Unmarshal(w.Body.Bytes(), &result)
+		if tt.want != "" && result["name"] != tt.want {
+			t.Errorf("%s: expected name %q, got %v", tt.name, tt.want, result["name"])
+		}
+		if tt.want == "" && (result["name"] == "John Doe" || result["name"] == "[REDACTED]") {
+			t.Errorf("%s: expected a generated name, got %v", tt.name, result["name"])
+		}
+	}
+}
+
+func TestProfilesAmbiguous(t *testing.T) {
+	profiles, _ := NewProfiles(map[string]*Obscurer{
+		"qa":     NewObscurer(nil, nil),
+		"export": NewObscurer(nil, nil),
+	}, "")
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/obscure", func(c *gin.Context) {
+		c.Set("jwt_claims", jwt.MapClaims{"scope": "obscure:qa obscure:export"})
+	}, profiles.Handle((*Obscurer).HandleObscure))
+
+	req := httptest.NewRequest("POST", "/obscure", strings.NewReader(`{}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 when several profiles are granted, got %d", w.Code)
+	}
+}
+
+func TestNewProfilesInvalid(t *testing.T) {
+	if _, err := NewProfiles(nil, ""); err == nil {
+		t.Error("Expected error for no profiles")
+	}
+	if _, err := NewProfiles(map[string]*Obscurer{"qa": NewObscurer(nil, nil)}, "export"); err == nil {
+		t.Error("Expected error for an undefined default profile")
+	}
+}
+
+func TestTenantsNamespaces(t *testing.T) {
+	tenants := NewTenants(map[string][]byte{
+		"team-a":           []byte(strings.Repeat("a", data.MinHashKeyLength)),
+		"team-b":           []byte(strings.Repeat("b", data.MinHashKeyLength)),
+		"billing.internal": []byte(strings.Repeat("c", data.MinHashKeyLength)),
+	}, "")
+	o := NewObscurer(nil, nil)
+
+	gin.SetMode(gin.TestMode)
+	obscure := func(claims jwt.MapClaims, id *auth.ClientIdentity, body string) (int, map[string]any) {
+		router := gin.New()
+		router.POST("/obscure", func(c *gin.Context) {
+			if claims != nil {
+				c.Set("jwt_claims", claims)
+			}
+			if id != nil {
+				c.Set("client_identity", id)
+			}
+			tenants.Handle((*Obscurer).HandleObscure)(o, c)
+		})
+		req := httptest.NewRequest("POST", "/obscure", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		var result map[string]any
+		json.Unmarshal(w.Body.Bytes(), &result)
+		return w.Code, result
+	}
+
+	row := `{"id":"user123","name":"John Doe","email":"john@example.com"}`
+	_, a1 := obscure(jwt.MapClaims{"tenant": "team-a"}, nil, row)
+	_, a2 := obscure(jwt.MapClaims{"tenant": "team-a"}, nil, row)
+	_, b := obscure(jwt.MapClaims{"tenant": "team-b"}, nil, row)
+	_, cert := obscure(nil, &auth.ClientIdentity{CommonName: "billing-service", DNSNames: []string{"billing.internal"}}, row)
+
+	if a1["name"] != a2["name"] || a1["email"] != a2["email"] {
+		t.Errorf("Expected consistent fakes within a tenant, got %v and %v", a1, a2)
+	}
+	if a1["name"] == b["name"] && a1["email"] == b["email"] {
+		t.Errorf("Expected different fakes across tenants, got %v for both", a1)
+	}
+	if cert["name"] == nil || cert["name"] == a1["name"] && cert["email"] == a1["email"] {
+		t.Errorf("Expected the certificate's tenant to get its own fakes, got %v", cert)
+	}
+
+	// Without a tenant the global namespace differs from every tenant's
+	global, _ := o.ObscureJSON(nil, []byte(row))
+	var g map[string]any
+	json.Unmarshal(global, &g)
+	if g["name"] == a1["name"] && g["email"] == a1["email"] {
+		t.Error("Expected tenant fakes to differ from the global namespace")
+	}
+
+	if code, _ := obscure(jwt.MapClaims{"tenant": "team-z"}, nil, row); code != http.StatusForbidden {
+		t.Errorf("Expected 403 for an unknown tenant, got %d", code)
+	}
+	if code, _ := obscure(jwt.MapClaims{"sub": "user"}, nil, row); code != http.StatusForbidden {
+		t.Errorf("Expected 403 without a tenant, got %d", code)
+	}
+}
+
+func TestObscureCSVForTenant(t *testing.T) {
+	input := "id,name\nuser123,John Doe\n"
+	a := NewObscurer(nil, nil).ForTenant([]byte(strings.Repeat("a", data.MinHashKeyLength)))
+	b := NewObscurer(nil, nil).ForTenant([]byte(strings.Repeat("b", data.MinHashKeyLength)))
+
+	var outA, outB bytes.Buffer
+	if err := a.ObscureCSV(strings.NewReader(input), &outA, ',', nil); err != nil {
+		t.Fatalf("ObscureCSV failed: %v", err)
+	}
+	if err := b.ObscureCSV(strings.NewReader(input), &outB, ',', nil); err != nil {
+		t.Fatalf("ObscureCSV failed: %v", err)
+	}
+	if outA.String() == outB.String() {
+		t.Errorf("Expected tenants to get different rows, got %q for both", outA.String())
+	}
+}
+
+func TestStatsCountRecordsAndFields(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: email
+    fields: [email]
+  - kind: ssn
+    fields: [ssn]
+    strategy: mask
+  - fields: [notes]
+    strategy: remove
+  - fields: [name]
+    strategy: keep
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	o := NewObscurer(rs, nil)
+	want := map[string]int{"email": 2, "ssn": 2, "remove": 2}
+
+	var stats Stats
+	input := `[{"id":1,"email":"a@example.com","ssn":"123-45-6789","name":"A"},` +
+		`{"id":2,"email":"b@example.com","ssn":"987-65-4321","notes":"x","name":"B"},{"id":3,"notes":"y"}]`
+	if err := o.WithStats(&stats).ObscureJSONArray(strings.NewReader(input), io.Discard, nil); err != nil {
+		t.Fatalf("ObscureJSONArray failed: %v", err)
+	}
+	if stats.Records != 3 || fmt.Sprint(stats.Fields) != fmt.Sprint(want) {
+		t.Errorf("Expected 3 records and %v, got %d and %v", want, stats.Records, stats.Fields)
+	}
+
+	stats = Stats{}
+	csv := "id,email,ssn,notes,name\n1,a@example.com,123-45-6789,x,A\n2,b@example.com,987-65-4321,y,B\n"
+	if err := o.WithStats(&stats).ObscureCSV(strings.NewReader(csv), io.Discard, ',', nil); err != nil {
+		t.Fatalf("ObscureCSV failed: %v", err)
+	}
+	if stats.Records != 2 || fmt.Sprint(stats.Fields) != fmt.Sprint(want) {
+		t.Errorf("Expected 2 records and %v, got %d and %v", want, stats.Records, stats.Fields)
+	}
+
+	// The original Obscurer doesn't count
+	if _, err := o.ObscureJSON(nil, []byte(`{"email":"a@example.com"}`)); err != nil {
+		t.Fatalf("ObscureJSON failed: %v", err)
+	}
+	if stats.Records != 2 {
+		t.Errorf("Expected stats to be unchanged, got %d records", stats.Records)
+	}
+}
+
+func TestCountStatsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	var stats *Stats
+	router.POST("/obscure/ndjson", func(c *gin.Context) {
+		CountStats((*Obscurer).HandleObscureNDJSON)(NewObscurer(nil, nil), c)
+		stats, _ = GetStats(c)
+	})
+
+	body := "{\"id\":1,\"email\":\"a@example.com\"}\nnot json\n{\"id\":2,\"email\":\"b@example.com\"}\n"
+	req := httptest.NewRequest(http.MethodPost, "/obscure/ndjson", strings.NewReader(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if stats == nil || stats.Records != 2 || stats.Fields["email"] != 2 {
+		t.Errorf("Expected 2 records with 2 emails, got %+v", stats)
+	}
+}
+
+func TestHandleObscurePersona(t *testing.T) {
+	o := NewObscurer(nil, nil)
+	o.Persona = true
+
+	input := `{"id":"user123","name":"John Doe","first_name":"John","last_name":"Doe","email":"john@example.com","gender":"M",` +
+		`"manager":{"name":"Ann Lee","email":"ann@example.com"}}`
+	result, err := o.ObscureJSON(nil, []byte(input))
+	if err != nil {
+		t.Fatalf("ObscureJSON failed: %v", err)
+	}
+	var out struct {
+		Name      string `json:"name"`
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		Email     string `json:"email"`
+		Gender    string `json:"gender"`
+		Manager   struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"manager"`
+	}
+	if err := json.Unmarshal(result, &out); err != nil {
+		t.Fatalf("Invalid output %s: %v", result, err)
+	}
+
+	if out.Name != out.FirstName+" "+out.LastName {
+		t.Errorf("Expected name to agree with first and last name, got %+v", out)
+	}
+	names := data.FemaleFirstNames
+	if out.Gender == "Male" {
+		names = data.MaleFirstNames
+	}
+	if !slices.Contains(names, out.FirstName) {
+		t.Errorf("Expected first name %s to match gender %s", out.FirstName, out.Gender)
+	}
+	local, _, _ := strings.Cut(out.Email, "@")
+	first, last, _ := strings.Cut(local, ".")
+	if first != strings.ToLower(out.FirstName) || last == "" {
+		t.Errorf("Expected email %s to be built from %s", out.Email, out.Name)
+	}
+	if out.Manager.Name == out.Name {
+		t.Errorf("Expected the nested object to get its own persona, got %s twice", out.Name)
+	}
+	managerFirst, _, _ := strings.Cut(out.Manager.Name, " ")
+	if !strings.HasPrefix(out.Manager.Email, strings.ToLower(managerFirst)+".") {
+		t.Errorf("Expected manager email %s to be built from %s", out.Manager.Email, out.Manager.Name)
+	}
+
+	// The same record gives the same persona, also from CSV
+	again, _ := o.ObscureJSON(nil, []byte(input))
+	if !bytes.Equal(result, again) {
+		t.Errorf("Expected deterministic output, got %s and %s", result, again)
+	}
+
+	var csvOut bytes.Buffer
+	csvIn := "id,name,email,gender\nuser123,John Doe,john@example.com,M\n"
+	if err := o.ObscureCSV(strings.NewReader(csvIn), &csvOut, ',', nil); err != nil {
+		t.Fatalf("ObscureCSV failed: %v", err)
+	}
+	cells := strings.Split(strings.Split(strings.TrimSpace(csvOut.String()), "\n")[1], ",")
+	csvFirst, _, _ := strings.Cut(cells[1], " ")
+	wantGender := "Female"
+	if slices.Contains(data.MaleFirstNames, csvFirst) {
+		wantGender = "Male"
+	}
+	if !strings.HasPrefix(cells[2], strings.ToLower(csvFirst)+".") || cells[3] != wantGender {
+		t.Errorf("Expected a coherent CSV row, got %v", cells)
+	}
+
+	// Without persona mode every field is generated on its own
+	o.Persona = false
+	plain, _ := o.ObscureJSON(nil, []byte(input))
+	if bytes.Equal(result, plain) {
+		t.Error("Expected persona mode to change the output")
+	}
+}
+
+func TestHandleObscureLocale(t *testing.T) {
+	o := NewObscurer(nil, nil)
+	if err := o.SetLocale("auto"); err != nil {
+		t.Fatalf("SetLocale failed: %v", err)
+	}
+
+	input := `{"id":"user123","name":"John Doe","address":"123 Main St, Springfield, IL 62701","city":"Springfield",` +
+		`"state":"IL","zip_code":"62701","country":"DE","contact":{"street":"1 Elm St"}}`
+	result, err := o.ObscureJSON(nil, []byte(input))
+	if err != nil {
+		t.Fatalf("ObscureJSON failed: %v", err)
+	}
+	var out struct {
+		Name    string `json:"name"`
+		Address string `json:"address"`
+		City    string `json:"city"`
+		State   string `json:"state"`
+		ZipCode string `json:"zip_code"`
+		Country string `json:"country"`
+		Contact struct {
+			Street string `json:"street"`
+		} `json:"contact"`
+	}
+	if err := json.Unmarshal(result, &out); err != nil {
+		t.Fatalf("Invalid output %s: %v", result, err)
+	}
+
+	last := out.Name[strings.LastIndex(out.Name, " ")+1:]
+	if !slices.Contains(data.LocaleDeDE.LastNames, last) {
+		t.Errorf("Expected a German name, got %s", out.Name)
+	}
+	if !strings.HasSuffix(out.Address, ", "+out.ZipCode+" "+out.City) {
+		t.Errorf("Expected the address to end with %s %s, got %s", out.ZipCode, out.City, out.Address)
+	}
+	if i := slices.IndexFunc(data.LocaleDeDE.Cities, func(c data.LocaleCity) bool { return c.Name == out.City }); i < 0 ||
+		data.LocaleDeDE.Cities[i].State != out.State {
+		t.Errorf("Expected a German city and its state, got %s, %s", out.City, out.State)
+	}
+	if out.Country != "DE" {
+		t.Errorf("Expected the country to be kept, got %s", out.Country)
+	}
+	// Nested objects inherit the locale of their record
+	if !slices.Contains(data.LocaleDeDE.Streets, out.Contact.Street[:strings.LastIndex(out.Contact.Street, " ")]) {
+		t.Errorf("Expected a German street, got %s", out.Contact.Street)
+	}
+
+	// Records of an unknown country are generated as before
+	unknown := strings.Replace(input, `"DE"`, `"Atlantis"`, 1)
+	result, _ = o.ObscureJSON(nil, []byte(unknown))
+	plain, _ := NewObscurer(nil, nil).ObscureJSON(nil, []byte(unknown))
+	if !bytes.Equal(result, plain) {
+		t.Errorf("Expected the default generators, got %s and %s", result, plain)
+	}
+
+	// A configured locale applies to records without a country
+	if err := o.SetLocale("fr_FR"); err != nil {
+		t.Fatalf("SetLocale failed: %v", err)
+	}
+	// and to records whose country selects no locale, whose country then
+	// agrees with the French address
+	result, _ = o.ObscureJSON(nil, []byte(unknown))
+	if err := json.Unmarshal(result, &out); err != nil {
+		t.Fatalf("Invalid output %s: %v", result, err)
+	}
+	if out.Country != "France" || !slices.ContainsFunc(data.LocaleFrFR.Cities, func(c data.LocaleCity) bool { return c.Name == out.City }) {
+		t.Errorf("Expected a French city and France as the country, got %s, %s", out.City, out.Country)
+	}
+	var csvOut bytes.Buffer
+	csvIn := "id,city,zip_code\nuser123,Springfield,62701\nuser124,Berlin,10115\n"
+	if err := o.ObscureCSV(strings.NewReader(csvIn), &csvOut, ',', nil); err != nil {
+		t.Fatalf("ObscureCSV failed: %v", err)
+	}
+	for _, row := range strings.Split(strings.TrimSpace(csvOut.String()), "\n")[1:] {
+		cells := strings.Split(row, ",")
+		i := slices.IndexFunc(data.LocaleFrFR.Cities, func(c data.LocaleCity) bool { return c.Name == cells[1] })
+		if i < 0 || cells[2][:2] != data.LocaleFrFR.Cities[i].PostalCode[:2] {
+			t.Errorf("Expected a French city with its postal code, got %v", cells)
+		}
+	}
+
+	if err := o.SetLocale("xx_XX"); err == nil {
+		t.Error("Expected an unknown locale to be rejected")
+	}
+}
+
+func TestHandleObscureWord(t *testing.T) {
+	file := t.TempDir() + "/products.txt"
+	os.WriteFile(file, []byte("Widget\nGadget\nGizmo\n"), 0o600)
+	if err := data.LoadDictionaries(map[string]string{"test_products": file}); err != nil {
+		t.Fatalf("Failed to load dictionary: %v", err)
+	}
+
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: word
+    fields: [product]
+    options:
+      dictionary: test_products
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if err := CheckDictionaries(rs); err != nil {
+		t.Fatalf("Expected the dictionary to be found: %v", err)
+	}
+
+	o := NewObscurer(rs, nil)
+	result, err := o.ObscureJSON(nil, []byte(`{"id":"order1","product":"Turbo Encabulator"}`))
+	if err != nil {
+		t.Fatalf("ObscureJSON failed: %v", err)
+	}
+	var out struct {
+		Product string `json:"product"`
+	}
+	if err := json.Unmarshal(result, &out); err != nil {
+		t.Fatalf("Invalid output %s: %v", result, err)
+	}
+	if !slices.Contains([]string{"Widget", "Gadget", "Gizmo"}, out.Product) {
+		t.Errorf("Expected a product from the dictionary, got %s", out.Product)
+	}
+
+	rs, _ = rules.Parse([]byte("rules:\n  - kind: word\n    fields: [product]\n    options:\n      dictionary: nope\n"))
+	if err := CheckDictionaries(rs); err == nil {
+		t.Error("Expected an unknown dictionary to be rejected")
+	}
+}
+
+func TestHandleObscureUnique(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: name
+    fields: [name]
+  - kind: email
+    fields: [email]
+    options:
+      unique: true
+  - kind: ssn
+    fields: [ssn]
+    options:
+      unique: true
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	var input []map[string]string
+	for i := range 400 {
+		input = append(input, map[string]string{
+			"id":    fmt.Sprintf("user%d", i),
+			"name":  fmt.Sprintf("Person %d", i),
+			"email": fmt.Sprintf("person%d@corp.example", i),
+			"ssn":   fmt.Sprintf("%03d-45-%04d", 100+i, i+1),
+		})
+	}
+	obscure := func(o *Obscurer, records []map[string]string) []map[string]string {
+		raw, _ := json.Marshal(records)
+		result, err := o.ObscureJSON(nil, raw)
+		if err != nil {
+			t.Fatalf("ObscureJSON failed: %v", err)
+		}
+		var out []map[string]string
+		if err := json.Unmarshal(result, &out); err != nil {
+			t.Fatalf("Invalid output %s: %v", result, err)
+		}
+		return out
+	}
+
+	o := NewObscurer(rs, nil)
+	out := obscure(o, input)
+	fakes := make(map[string]string)
+	emails := make(map[string]bool)
+	ssns := make(map[string]bool)
+	for i, record := range out {
+		fakes[input[i]["email"]] = record["email"]
+		fakes[input[i]["ssn"]] = record["ssn"]
+		emails[record["email"]] = true
+		ssns[record["ssn"]] = true
+		if valueShape(record["ssn"]) != "999-99-9999" {
+			t.Fatalf("Expected the SSN shape to be kept, got %s", record["ssn"])
+		}
+	}
+	if len(emails) != 400 || len(ssns) != 400 {
+		t.Errorf("Expected 400 distinct emails and SSNs, got %d and %d", len(emails), len(ssns))
+	}
+
+	// Fakes depend only on the value: not on the record, the order or the
+	// other values, nor on persona mode
+	reversed := slices.Clone(input)
+	slices.Reverse(reversed)
+	reversed[0] = map[string]string{"id": "other", "email": input[0]["email"], "ssn": input[0]["ssn"]}
+	o.Persona = true
+	for i, record := range obscure(o, reversed) {
+		for _, field := range []string{"email", "ssn"} {
+			if want := fakes[reversed[i][field]]; record[field] != want {
+				t.Fatalf("Expected %s to give %s, got %s", reversed[i][field], want, record[field])
+			}
+		}
+	}
+
+	// The persona still generates the fields that aren't unique
+	persona := obscure(o, input[:1])[0]
+	if want := data.NewPersona("user0", "name=Person 0", "email=person0@corp.example").Name(); persona["name"] != want {
+		t.Errorf("Expected the name %s of the persona, got %s", want, persona["name"])
+	}
+}
+
+func TestObscureUniqueCompositeKinds(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: bank_accounts
+    fields: [accounts]
+    options:
+      unique: true
+  - kind: passport
+    fields: [passport]
+    options:
+      unique: true
+  - kind: driver_license
+    fields: [license]
+    options:
+      unique: true
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	o := NewObscurer(rs, nil)
+
+	type document struct {
+		Accounts []map[string]string `json:"accounts"`
+		Passport map[string]string   `json:"passport"`
+		License  map[string]string   `json:"license"`
+	}
+	obscure := func(id string) document {
+		input := `{"id":"` + id + `","accounts":[{"name":"Checking","amount":"120.00","balance":"5400.00",` +
+			`"account_number":"12345678","credit_card_number":"4111111111111111","routing_number":"021000021"}],` +
+			`"passport":{"number":"X1234567","issue_date":"2015-01-01","expiration_date":"2025-01-01"},` +
+			`"license":{"number":"D1234567","issue_date":"2016-02-02","expiration_date":"2026-02-02"}}`
+		result, err := o.ObscureJSON(nil, []byte(input))
+		if err != nil {
+			t.Fatalf("ObscureJSON failed: %v", err)
+		}
+		var doc document
+		if err := json.Unmarshal(result, &doc); err != nil {
+			t.Fatalf("Invalid output %s: %v", result, err)
+		}
+		return doc
+	}
+	a, b := obscure("a"), obscure("b")
+
+	// Identifying numbers stay the same across records
+	for _, key := range []string{"account_number", "credit_card_number", "routing_number"} {
+		if a.Accounts[0][key] != b.Accounts[0][key] {
+			t.Errorf("Expected %s to be record independent, got %s and %s", key, a.Accounts[0][key], b.Accounts[0][key])
+		}
+	}
+	if a.Passport["number"] != b.Passport["number"] || a.License["number"] != b.License["number"] {
+		t.Errorf("Expected passport and license numbers to be record independent")
+	}
+
+	// Everything else is seeded by the record, so records can't be linked
+	for _, key := range []string{"name", "amount", "balance"} {
+		if a.Accounts[0][key] == b.Accounts[0][key] {
+			t.Errorf("Expected account %s to differ between records, got %s", key, a.Accounts[0][key])
+		}
+	}
+	for _, key := range []string{"issue_date", "expiration_date"} {
+		if a.Passport[key] == b.Passport[key] || a.License[key] == b.License[key] {
+			t.Errorf("Expected %s to differ between records", key)
+		}
+	}
+}
+
+// valueShape replaces digits with 9 and letters with A or a
+func valueShape(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= '0' && r <= '9':
+			return '9'
+		case r >= 'A' && r <= 'Z':
+			return 'A'
+		case r >= 'a' && r <= 'z':
+			return 'a'
+		}
+		return r
+	}, s)
+}
+
+func TestHandleObscurePreserveFormat(t *testing.T) {
+	rs, err := rules.Parse([]byte(`
+rules:
+  - kind: phone
+    fields: [phone]
+    options: {format: preserve}
+  - kind: ssn
+    fields: [ssn]
+    options: {format: preserve}
+  - kind: passport
+    fields: [passport]
+    options: {format: preserve}
+  - kind: bank_accounts
+    fields: [bank_accounts]
+    options: {format: preserve}
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	o := NewObscurer(rs, nil)
+
+	input := `{"id":"user123","phone":"+44 20 7946 0958","ssn":"123456789","passport":{"number":"C01X00T47"},` +
+		`"bank_accounts":[{"account_number":"DE-0012-3456","credit_card_number":"3782 822463 10005"}]}`
+	result, err := o.ObscureJSON(nil, []byte(input))
+	if err != nil {
+		t.Fatalf("ObscureJSON failed: %v", err)
+	}
+	var out struct {
+		Phone    string `json:"phone"`
+		SSN      string `json:"ssn"`
+		Passport struct {
+			Number string `json:"number"`
+		} `json:"passport"`
+		BankAccounts []struct {
+			AccountNumber string `json:"account_number"`
+			Card          string `json:"credit_card_number"`
+		} `json:"bank_accounts"`
+	}
+	if err := json.Unmarshal(result, &out); err != nil {
+		t.Fatalf("Invalid output %s: %v", result, err)
+	}
+
+	// same reports whether a generated value differs from the real one but
+	// has its shape
+	same := func(generated, real string) bool {
+		return generated != real && valueShape(generated) == valueShape(real)
+	}
+	if !same(out.Phone, "+44 20 7946 0958") || !strings.HasPrefix(out.Phone, "+44 ") {
+		t.Errorf("Expected the phone to keep its shape and country code, got %s", out.Phone)
+	}
+	if !same(out.SSN, "123456789") {
+		t.Errorf("Expected a 9-digit SSN, got %s", out.SSN)
+	}
+	if !same(out.Passport.Number, "C01X00T47") {
+		t.Errorf("Expected the passport number to keep its shape, got %s", out.Passport.Number)
+	}
+	if len(out.BankAccounts) != 1 || !same(out.BankAccounts[0].AccountNumber, "DE-0012-3456") ||
+		!same(out.BankAccounts[0].Card, "3782 822463 10005") || !strings.HasPrefix(out.BankAccounts[0].Card, "37") {
+		t.Errorf("Expected the bank account to keep its shape, got %+v", out.BankAccounts)
+	}
+
+	var csvOut bytes.Buffer
+	if err := o.ObscureCSV(strings.NewReader("id,phone\nuser123,(212) 555-0199\n"), &csvOut, ',', nil); err != nil {
+		t.Fatalf("ObscureCSV failed: %v", err)
+	}
+	row := strings.Split(strings.TrimSpace(csvOut.String()), "\n")[1]
+	if phone := strings.TrimPrefix(row, "user123,"); !same(phone, "(212) 555-0199") {
+		t.Errorf("Expected the CSV phone to keep its shape, got %s", phone)
+	}
+}
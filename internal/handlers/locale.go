@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"fmt"
+
+	"simulacrum/internal/data"
+	"simulacrum/internal/rules"
+
+	"github.com/valyala/fastjson"
+)
+
+// placeKinds are the kinds generated from a record's place when it has a
+// locale, so its city, state and postal code belong together
+var placeKinds = []rules.Kind{rules.KindCity, rules.KindState, rules.KindZipCode}
+
+// recordScope is what the fields of one object or CSV row share: the locale
+// they are generated in and, once seeded from their real values, the persona
+// and the place
+type recordScope struct {
+	locale  *data.Locale
+	persona *data.Persona
+	place   *data.Place
+}
+
+// scoped reports whether fields are generated per record scope rather than
+// one at a time
+func (o *Obscurer) scoped() bool {
+	return o.Persona || o.Locale != nil || o.LocaleFromCountry
+}
+
+// scopeLocale returns the locale of sc, which may be nil
+func scopeLocale(sc *recordScope) *data.Locale {
+	if sc == nil {
+		return nil
+	}
+	return sc.locale
+}
+
+// scopeKind reports whether the value of a field matched by rule seeds the
+// record scope. Only generated values do, except for the country, which
+// selects the locale whatever its strategy.
+func scopeKind(rule *rules.Rule) bool {
+	return rule != nil && (rule.Kind == rules.KindCountry || rule.Strategy == rules.StrategyGenerate)
+}
+
+// newScope creates the scope of a record from the first non-empty real value
+// of each kind, inheriting locale from the enclosing record unless the
+// record's country selects another one
+func (o *Obscurer) newScope(id string, locale *data.Locale, values map[rules.Kind]string) *recordScope {
+	if o.LocaleFromCountry {
+		if l, ok := data.LocaleForCountry(values[rules.KindCountry]); ok {
+			locale = l
+		}
+	}
+
+	sc := &recordScope{locale: locale}
+	if o.Persona {
+		sc.persona = newPersona(locale, id, values)
+	}
+	if locale != nil {
+		var seed []string
+		for _, kind := range placeKinds {
+			if s := values[kind]; s != "" {
+				seed = append(seed, string(kind)+"="+s)
+			}
+		}
+		if len(seed) > 0 {
+			sc.place = locale.NewPlace(id, seed...)
+		}
+	}
+	return sc
+}
+
+// objectScope creates the scope shared by the fields of obj, or returns nil
+// if neither persona mode nor a locale is configured
+func (o *Obscurer) objectScope(obj *fastjson.Object, id string, path rules.Path, locale *data.Locale) *recordScope {
+	if !o.scoped() {
+		return nil
+	}
+
+	values := make(map[rules.Kind]string)
+	obj.Visit(func(key []byte, v *fastjson.Value) {
+		rule, excluded := o.matchRule(path.AppendKey(string(key)))
+		if excluded || !scopeKind(rule) {
+			return
+		}
+		if s, ok := stringOf(v); ok && s != "" && values[rule.Kind] == "" {
+			values[rule.Kind] = s
+		}
+	})
+	return o.newScope(id, locale, values)
+}
+
+// generate returns the value of a generated field that comes from the scope,
+// or false if it is generated on its own. Values of unique rules are always
+// generated on their own, as a persona or locale can't keep them distinct.
+func (sc *recordScope) generate(rule *rules.Rule, real, id string) (string, bool) {
+	if sc == nil || real == "" || rule.Strategy != rules.StrategyGenerate || rule.Unique() {
+		return "", false
+	}
+	if s, ok := personaValue(sc.persona, rule, real); ok {
+		return s, true
+	}
+
+	l := sc.locale
+	if l == nil {
+		return "", false
+	}
+	switch rule.Kind {
+	case rules.KindName:
+		return l.Name(id, real), true
+	case rules.KindFirstName:
+		return l.FirstName(id, real), true
+	case rules.KindMiddleName:
+		return l.MiddleName(id, real), true
+	case rules.KindLastName:
+		return l.LastName(id, real), true
+	case rules.KindStreet:
+		return l.Street(id, real), true
+	case rules.KindAddress:
+		return l.Address(id, real, sc.place), true
+	case rules.KindCity, rules.KindState, rules.KindZipCode:
+		place := sc.place
+		if place == nil {
+			place = l.NewPlace(id, string(rule.Kind)+"="+real)
+		}
+		switch rule.Kind {
+		case rules.KindCity:
+			return place.City, true
+		case rules.KindState:
+			return place.State, true
+		default:
+			return place.PostalCode, true
+		}
+	case rules.KindCountry:
+		// The country agrees with the address generated for the locale
+		return l.Country(real), true
+	}
+	return "", false
+}
+
+// SetLocale configures locale-aware generation. code is a locale such as
+// de_DE, used for every object whose country doesn't select another one, or
+// "auto" to only follow country fields. An empty code turns it off.
+func (o *Obscurer) SetLocale(code string) error {
+	switch code {
+	case "":
+		o.Locale, o.LocaleFromCountry = nil, false
+	case "auto":
+		o.Locale, o.LocaleFromCountry = nil, true
+	default:
+		l, ok := data.LocaleByCode(code)
+		if !ok {
+			return fmt.Errorf("unknown locale %q", code)
+		}
+		o.Locale, o.LocaleFromCountry = l, true
+	}
+	return nil
+}
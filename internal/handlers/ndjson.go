@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// NDJSONContentType is the media type for newline-delimited JSON
+	NDJSONContentType = "application/x-ndjson"
+	// MaxNDJSONLineSize bounds the memory used for a single record
+	MaxNDJSONLineSize = 16 << 20
+	// streamFlushSize is how much output is buffered before it is flushed to the client
+	streamFlushSize = 32 << 10
+)
+
+// HandleObscureNDJSON obscures newline-delimited JSON, one record per line,
+// streaming each result back as soon as it is ready
+func (o *Obscurer) HandleObscureNDJSON(c *gin.Context) {
+	// Keep reading the request while the response is streamed
+	_ = http.NewResponseController(c.Writer).EnableFullDuplex()
+
+	c.Header("Content-Type", NDJSONContentType)
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so failures can only end the stream
+	if err := o.ObscureNDJSON(c.Request.Body, c.Writer, c.Writer.Flush); err != nil {
+		_ = c.Error(err)
+	}
+}
+
+// ObscureNDJSON reads newline-delimited JSON from r and writes one obscured
+// record per line to w. A line that can't be parsed is replaced by an error
+// record naming the line number instead of failing the whole stream. flush,
+// if not nil, is called whenever enough output has been written.
+func (o *Obscurer) ObscureNDJSON(r io.Reader, w io.Writer, flush func()) error {
+	reader := bufio.NewReaderSize(r, 64<<10)
+	var line, out []byte
+	pending := 0
+
+	for lineNo := 1; ; lineNo++ {
+		var tooLong bool
+		var readErr error
+		line, tooLong, readErr = readLine(reader, line, MaxNDJSONLineSize)
+		if readErr != nil && readErr != io.EOF {
+			return readErr
+		}
+
+		record := bytes.TrimSpace(line)
+		if len(record) > 0 || tooLong {
+			out = out[:0]
+			if tooLong {
+				out = appendLineError(out, lineNo, "line too long")
+			} else if result, err := o.ObscureJSON(out, record); err != nil {
+				out = appendLineError(out, lineNo, "invalid JSON")
+			} else {
+				out = result
+			}
+			out = append(out, '\n')
+
+			if _, err := w.Write(out); err != nil {
+				return err
+			}
+			pending += len(out)
+			if flush != nil && pending >= streamFlushSize {
+				flush()
+				pending = 0
+			}
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+	}
+
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
+
+// readLine reads one line into buf, without the trailing newline. Lines longer
+// than max are consumed but not kept, and reported with tooLong.
+func readLine(r *bufio.Reader, buf []byte, max int) (line []byte, tooLong bool, err error) {
+	buf = buf[:0]
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if !tooLong {
+			if len(buf)+len(chunk) > max {
+				tooLong = true
+				buf = buf[:0]
+			} else {
+				buf = append(buf, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return bytes.TrimSuffix(buf, []byte("\n")), tooLong, err
+	}
+}
+
+// appendLineError appends an error record for a line that couldn't be obscured
+func appendLineError(dst []byte, lineNo int, msg string) []byte {
+	dst = append(dst, `{"error":`...)
+	dst = appendJSONString(dst, msg)
+	dst = append(dst, `,"line":`...)
+	dst = strconv.AppendInt(dst, int64(lineNo), 10)
+	return append(dst, '}')
+}
@@ -0,0 +1,665 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"unicode/utf8"
+
+	"simulacrum/internal/data"
+	"simulacrum/internal/rules"
+
+	"github.com/valyala/fastjson"
+)
+
+// DefaultRedactText replaces values under the redact strategy unless a rule sets "text"
+const DefaultRedactText = "[REDACTED]"
+
+var parserPool fastjson.ParserPool
+
+// ObscureJSON parses a JSON document and appends its obscured form to dst.
+// Values that are not obscured are copied verbatim, in document order.
+func (o *Obscurer) ObscureJSON(dst, input []byte) ([]byte, error) {
+	parser := parserPool.Get()
+	defer parserPool.Put(parser)
+
+	v, err := parser.ParseBytes(input)
+	if err != nil {
+		return dst, err
+	}
+	if bytes.IndexByte(input, '\\') < 0 {
+		plain := *o
+		plain.noEscapes = true
+		return plain.Obscure(dst, v), nil
+	}
+	return o.Obscure(dst, v), nil
+}
+
+// Obscure appends the obscured form of a parsed document to dst
+func (o *Obscurer) Obscure(dst []byte, v *fastjson.Value) []byte {
+	o.stats.addRecord()
+	return o.obscureGeneric(dst, v, o.scopeID(""), nil, o.Locale)
+}
+
+// obscureGeneric recursively processes a generic structure and obscures known fields.
+// id is the identifier of the nearest enclosing record and path the location of v.
+// locale is the locale of the nearest enclosing record, if any.
+func (o *Obscurer) obscureGeneric(dst []byte, v *fastjson.Value, id string, path rules.Path, locale *data.Locale) []byte {
+	// GetObject and GetArray don't unescape strings, so untouched strings
+	// are written back exactly as they were read. Anything that reads a
+	// value that may be passed through must do so with stringOf.
+	if obj := v.GetObject(); obj != nil {
+		return o.obscureObject(dst, obj, id, path, locale)
+	}
+	if arr := v.GetArray(); arr != nil {
+		return o.obscureArray(dst, arr, id, path, locale)
+	}
+	return v.MarshalTo(dst)
+}
+
+// matchRule returns the rule for the value at path. excluded is true when the
+// value must be copied untouched.
+func (o *Obscurer) matchRule(path rules.Path) (rule *rules.Rule, excluded bool) {
+	if o.Rules.Excluded(path) {
+		return nil, true
+	}
+	return o.Rules.Match(path), false
+}
+
+// obscureValue obscures the value at path if a rule targets it, otherwise
+// recurses into it. sc, if not nil, is the scope of the enclosing record.
+func (o *Obscurer) obscureValue(dst []byte, v *fastjson.Value, rule *rules.Rule, excluded bool, id string, path rules.Path, sc *recordScope) []byte {
+	if excluded {
+		return v.MarshalTo(dst)
+	}
+	if rule != nil {
+		o.stats.addField(rule)
+		if str, ok := stringOf(v); ok && sc != nil {
+			if s, ok := sc.generate(rule, str, id); ok {
+				return appendJSONString(dst, s)
+			}
+		}
+		return applyRule(dst, rule, v, id, o.uniqueID(rule, id))
+	}
+	// For unknown fields, recursively process if they're nested structures
+	return o.obscureGeneric(dst, v, id, path, scopeLocale(sc))
+}
+
+// obscureObject processes an object and obscures known fields
+func (o *Obscurer) obscureObject(dst []byte, obj *fastjson.Object, id string, path rules.Path, locale *data.Locale) []byte {
+	// Looking up members unescapes the keys, so take them as written first
+	var keys []string
+	if !o.noEscapes {
+		keys = rawKeys(obj)
+	}
+
+	// An object carrying its own identifier starts a new record scope
+	id = o.recordID(obj, id)
+	sc := o.objectScope(obj, id, path, locale)
+
+	dst = append(dst, '{')
+	first := true
+	i := -1
+	obj.Visit(func(key []byte, v *fastjson.Value) {
+		i++
+		p := path.AppendKey(string(key))
+		rule, excluded := o.matchRule(p)
+		if rule != nil && rule.Strategy == rules.StrategyRemove {
+			o.stats.addField(rule)
+			return
+		}
+
+		if !first {
+			dst = append(dst, ',')
+		}
+		first = false
+		dst = appendKey(dst, keys, i, p.Key())
+		dst = append(dst, ':')
+		dst = o.obscureValue(dst, v, rule, excluded, id, p, sc)
+	})
+	return append(dst, '}')
+}
+
+// obscureArray processes an array and obscures each element
+func (o *Obscurer) obscureArray(dst []byte, arr []*fastjson.Value, id string, path rules.Path, locale *data.Locale) []byte {
+	dst = append(dst, '[')
+	for i, item := range arr {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = o.obscureElement(dst, item, i, id, path, locale)
+	}
+	return append(dst, ']')
+}
+
+// obscureElement processes the i-th element of the array at path
+func (o *Obscurer) obscureElement(dst []byte, item *fastjson.Value, i int, id string, path rules.Path, locale *data.Locale) []byte {
+	p := path.AppendIndex(i)
+	rule, excluded := o.matchRule(p)
+	// Removed array elements become null so indexes stay stable
+	if rule != nil && rule.Strategy == rules.StrategyRemove {
+		o.stats.addField(rule)
+		return append(dst, "null"...)
+	}
+	var sc *recordScope
+	if locale != nil {
+		sc = &recordScope{locale: locale}
+	}
+	return o.obscureValue(dst, item, rule, excluded, id, p, sc)
+}
+
+// recordID returns the identifier of the record obj, scoped to the tenant,
+// falling back to the parent's
+func (o *Obscurer) recordID(obj *fastjson.Object, parent string) string {
+	for _, key := range o.IDKeys {
+		v := obj.Get(key)
+		if v == nil {
+			continue
+		}
+		if s, ok := stringOf(v); ok {
+			if s != "" {
+				return o.scopeID(s)
+			}
+		} else if v.Type() == fastjson.TypeNumber {
+			// Use the number as written so large identifiers keep their precision
+			return o.scopeID(string(v.MarshalTo(nil)))
+		}
+	}
+	return parent
+}
+
+// applyRule applies the rule's strategy to a value. The remove strategy is
+// handled by the caller. id is the record id and uid the seed of the values
+// a unique rule keeps distinct, see Obscurer.uniqueID.
+func applyRule(dst []byte, rule *rules.Rule, v *fastjson.Value, id, uid string) []byte {
+	switch rule.Strategy {
+	case rules.StrategyKeep:
+		return v.MarshalTo(dst)
+	case rules.StrategyNullify:
+		return append(dst, "null"...)
+	case rules.StrategyRedact:
+		return appendJSONString(dst, rule.Option("text", DefaultRedactText))
+	case rules.StrategyMask:
+		return mapScalars(dst, v, maskFunc(rule))
+	case rules.StrategyHash:
+		return mapScalars(dst, v, func(s string) string {
+			return data.GenerateDeterministicToken(id, string(rule.Kind), s)
+		})
+	}
+
+	return obscureField(dst, rule, v, id, uid)
+}
+
+// maskFunc returns the masking function configured by a mask rule
+func maskFunc(rule *rules.Rule) func(string) string {
+	keepFirst := rule.IntOption("keep_first", 0)
+	keepLast := rule.IntOption("keep_last", 4)
+	maskChar := []rune(rule.Option("mask_char", "*"))[0]
+	return func(s string) string {
+		return data.MaskString(s, keepFirst, keepLast, maskChar)
+	}
+}
+
+// obscureString applies the rule's strategy to an untyped value, such as a
+// CSV cell, with id and uid as in applyRule. Nullify and remove yield an empty string, and kinds that are
+// objects in JSON treat the value as their number.
+func obscureString(rule *rules.Rule, s, id, uid string) string {
+	switch rule.Strategy {
+	case rules.StrategyKeep:
+		return s
+	case rules.StrategyNullify, rules.StrategyRemove:
+		return ""
+	case rules.StrategyRedact:
+		return rule.Option("text", DefaultRedactText)
+	case rules.StrategyMask:
+		return maskFunc(rule)(s)
+	case rules.StrategyHash:
+		return data.GenerateDeterministicToken(id, string(rule.Kind), s)
+	}
+
+	switch rule.Kind {
+	case rules.KindPassport:
+		return passportNumber(rule, uid, s)
+	case rules.KindDriverLicense:
+		return driverLicenseNumber(rule, uid, s)
+	case rules.KindBankAccounts:
+		return accountNumber(rule, uid, s, 0)
+	case rules.KindInteger:
+		if num, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return strconv.FormatInt(integer(rule, uid, num), 10)
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return strconv.FormatInt(integer(rule, uid, int64(f)), 10)
+		}
+		return s
+	case rules.KindFloat:
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return strconv.FormatFloat(data.GenerateDeterministicFloat(id, f), 'f', -1, 64)
+		}
+		return s
+	}
+
+	return generateString(rule, s, uid)
+}
+
+// mapScalars applies fn to every string and number in v, recursing into
+// objects and arrays. Numbers are converted to strings.
+func mapScalars(dst []byte, v *fastjson.Value, fn func(string) string) []byte {
+	if s, ok := stringOf(v); ok {
+		return appendJSONString(dst, fn(s))
+	}
+	switch v.Type() {
+	case fastjson.TypeNumber:
+		return appendJSONString(dst, fn(string(v.MarshalTo(nil))))
+	case fastjson.TypeObject:
+		obj := v.GetObject()
+		keys := rawKeys(obj)
+		dst = append(dst, '{')
+		i := -1
+		obj.Visit(func(key []byte, item *fastjson.Value) {
+			if i++; i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendKey(dst, keys, i, string(key))
+			dst = append(dst, ':')
+			dst = mapScalars(dst, item, fn)
+		})
+		return append(dst, '}')
+	case fastjson.TypeArray:
+		dst = append(dst, '[')
+		for i, item := range v.GetArray() {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = mapScalars(dst, item, fn)
+		}
+		return append(dst, ']')
+	default:
+		return v.MarshalTo(dst)
+	}
+}
+
+// obscureField applies the rule's generator to a value, seeded with the record id,
+// or uid for the values a unique rule keeps distinct. Values of an unexpected
+// type are copied unchanged.
+func obscureField(dst []byte, rule *rules.Rule, v *fastjson.Value, id, uid string) []byte {
+	switch rule.Kind {
+	case rules.KindPassport:
+		return obscurePassport(dst, rule, v, id, uid)
+	case rules.KindDriverLicense:
+		return obscureDriverLicense(dst, rule, v, id, uid)
+	case rules.KindBankAccounts:
+		return obscureBankAccounts(dst, rule, v, id, uid)
+	case rules.KindInteger:
+		if num, ok := toInt64(v); ok {
+			return strconv.AppendInt(dst, integer(rule, uid, num), 10)
+		}
+		return v.MarshalTo(dst)
+	case rules.KindFloat:
+		if fval, err := v.Float64(); err == nil {
+			return strconv.AppendFloat(dst, data.GenerateDeterministicFloat(id, fval), 'f', -1, 64)
+		}
+		return v.MarshalTo(dst)
+	}
+
+	str, ok := stringOf(v)
+	if !ok {
+		return v.MarshalTo(dst)
+	}
+	return appendJSONString(dst, generateString(rule, str, uid))
+}
+
+// generateString produces the fake value for a string field
+func generateString(rule *rules.Rule, str, id string) string {
+	switch rule.Kind {
+	case rules.KindName:
+		return data.GenerateDeterministicName(id, str)
+	case rules.KindFirstName:
+		return data.GenerateDeterministicFirstName(id, str)
+	case rules.KindLastName:
+		return data.GenerateDeterministicLastName(id, str)
+	case rules.KindMiddleName:
+		return data.GenerateDeterministicMiddleName(id, str)
+	case rules.KindStreet:
+		return data.GenerateDeterministicStreet(id, str)
+	case rules.KindEmail:
+		if rule.Unique() {
+			return data.GenerateBijectiveEmail(id, str)
+		}
+		if domains := rule.ListOption("domains"); len(domains) > 0 {
+			return data.GenerateDeterministicEmailWithDomains(id, str, domains)
+		}
+		return data.GenerateDeterministicEmail(id, str)
+	case rules.KindPhone:
+		if rule.Unique() {
+			return data.GenerateBijectivePhone(id, str)
+		}
+		if rule.PreservesFormat() {
+			return data.GenerateFormatPreservingPhone(id, str)
+		}
+		return data.GenerateDeterministicPhone(id, str)
+	case rules.KindAddress:
+		return data.GenerateDeterministicAddress(id, str)
+	case rules.KindCity:
+		return data.GenerateDeterministicCity(id, str)
+	case rules.KindState:
+		return data.GenerateDeterministicState(id, str)
+	case rules.KindZipCode:
+		return data.GenerateDeterministicZipCode(id, str)
+	case rules.KindCounty:
+		return data.GenerateDeterministicCounty(id, str)
+	case rules.KindCountry:
+		return data.GenerateDeterministicCountry(id, str)
+	case rules.KindTaxID:
+		if rule.Unique() {
+			return data.GenerateBijective(id, "taxid", str)
+		}
+		if rule.PreservesFormat() {
+			return data.GenerateFormatPreserving(id, "taxid", str)
+		}
+		return data.GenerateDeterministicTaxID(id, str)
+	case rules.KindSSN:
+		if rule.Unique() {
+			return data.GenerateBijectiveSSN(id, str)
+		}
+		if rule.PreservesFormat() {
+			return data.GenerateFormatPreservingSSN(id, str)
+		}
+		return data.GenerateDeterministicSSN(id, str)
+	case rules.KindDateOfBirth:
+		return data.GenerateDeterministicDateOfBirth(id, str)
+	case rules.KindGender:
+		return data.GenerateDeterministicGender(id, str)
+	case rules.KindWord:
+		return data.GenerateDeterministicWord(id, rule.Option("dictionary", ""), str)
+	}
+	return str
+}
+
+// integer generates an integer, encrypted if the rule is unique
+func integer(rule *rules.Rule, id string, num int64) int64 {
+	if rule.Unique() {
+		return data.GenerateBijectiveInteger(id, num)
+	}
+	return data.GenerateDeterministicInteger(id, num)
+}
+
+// toInt64 reads an integer, truncating fractional numbers
+func toInt64(v *fastjson.Value) (int64, bool) {
+	// Int64 would unescape a string that is then copied unchanged
+	if _, ok := stringOf(v); ok {
+		return 0, false
+	}
+	if num, err := v.Int64(); err == nil {
+		return num, true
+	}
+	if f, err := v.Float64(); err == nil {
+		return int64(f), true
+	}
+	return 0, false
+}
+
+// obscureStringMembers rewrites the string members of an object with gen,
+// keeping other members and the member order unchanged
+func obscureStringMembers(dst []byte, v *fastjson.Value, gen func(key, value string) string) []byte {
+	obj := v.GetObject()
+	if obj == nil {
+		return v.MarshalTo(dst)
+	}
+
+	keys := rawKeys(obj)
+	dst = append(dst, '{')
+	i := -1
+	obj.Visit(func(key []byte, item *fastjson.Value) {
+		if i++; i > 0 {
+			dst = append(dst, ',')
+		}
+		k := string(key)
+		dst = appendKey(dst, keys, i, k)
+		dst = append(dst, ':')
+		if str, ok := stringOf(item); ok {
+			dst = appendJSONString(dst, gen(k, str))
+		} else {
+			dst = item.MarshalTo(dst)
+		}
+	})
+	return append(dst, '}')
+}
+
+// passportNumber generates a passport number in the fixed or the real format
+func passportNumber(rule *rules.Rule, id, str string) string {
+	if rule.Unique() {
+		return data.GenerateBijective(id, "passport", str)
+	}
+	if rule.PreservesFormat() {
+		return data.GenerateFormatPreserving(id, "passport", str)
+	}
+	return data.GenerateDeterministicPassportNumber(id, str)
+}
+
+// driverLicenseNumber generates a license number in the fixed or the real format
+func driverLicenseNumber(rule *rules.Rule, id, str string) string {
+	if rule.Unique() {
+		return data.GenerateBijective(id, "driverlicense", str)
+	}
+	if rule.PreservesFormat() {
+		return data.GenerateFormatPreserving(id, "driverlicense", str)
+	}
+	return data.GenerateDeterministicDriverLicenseNumber(id, str)
+}
+
+// accountNumber generates the i-th account number in the fixed or the real
+// format. Unique account numbers don't depend on their position, so they
+// can't collide with those of other positions.
+func accountNumber(rule *rules.Rule, id, str string, i int) string {
+	if rule.Unique() {
+		return data.GenerateBijective(id, "account_number", str)
+	}
+	if rule.PreservesFormat() {
+		return data.GenerateFormatPreserving(id, fmt.Sprintf("account_number_%d", i), str)
+	}
+	return data.GenerateDeterministicAccountNumber(id, str, i)
+}
+
+// Helper function to obscure passport data, matching data.ObscurePassport for known keys
+func obscurePassport(dst []byte, rule *rules.Rule, v *fastjson.Value, id, uid string) []byte {
+	return obscureStringMembers(dst, v, func(k, str string) string {
+		switch k {
+		case "number":
+			return passportNumber(rule, uid, str)
+		case "issue_date":
+			return data.GenerateDeterministicDate(id, "passport_issue", str)
+		case "expiration_date":
+			return data.GenerateDeterministicDate(id, "passport_expiry", str)
+		default:
+			return data.GenerateDeterministicPassportNumber(id+fmt.Sprintf("passport_%s_", k), str)
+		}
+	})
+}
+
+// Helper function to obscure driver license data, matching data.ObscureDriverLicense for known keys
+func obscureDriverLicense(dst []byte, rule *rules.Rule, v *fastjson.Value, id, uid string) []byte {
+	return obscureStringMembers(dst, v, func(k, str string) string {
+		switch k {
+		case "number":
+			return driverLicenseNumber(rule, uid, str)
+		case "issue_date":
+			return data.GenerateDeterministicDate(id, "license_issue", str)
+		case "expiration_date":
+			return data.GenerateDeterministicDate(id, "license_expiry", str)
+		default:
+			return data.GenerateDeterministicDriverLicenseNumber(id+fmt.Sprintf("license_%s_", k), str)
+		}
+	})
+}
+
+// Helper function to obscure bank accounts
+func obscureBankAccounts(dst []byte, rule *rules.Rule, v *fastjson.Value, id, uid string) []byte {
+	arr := v.GetArray()
+	if arr == nil {
+		return v.MarshalTo(dst)
+	}
+
+	dst = append(dst, '[')
+	for i, item := range arr {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = obscureStringMembers(dst, item, func(k, str string) string {
+			switch k {
+			case "name":
+				return data.GenerateDeterministicAccountName(id, str, i)
+			case "amount":
+				return data.GenerateDeterministicAmount(id, str, i)
+			case "account_number":
+				return accountNumber(rule, uid, str, i)
+			case "balance":
+				return data.GenerateDeterministicBalance(id, str, i)
+			case "credit_card_number":
+				if rule.Unique() {
+					return data.GenerateBijectiveCardNumber(uid, str)
+				}
+				if rule.PreservesFormat() {
+					return data.GenerateFormatPreservingCardNumber(id, str, i)
+				}
+				return data.GenerateDeterministicCreditCardNumber(id, str, i)
+			case "routing_number":
+				if rule.Unique() {
+					return data.GenerateBijective(uid, "routing_number", str)
+				}
+				if rule.PreservesFormat() {
+					return data.GenerateFormatPreserving(id, fmt.Sprintf("routing_number_%d", i), str)
+				}
+				return data.GenerateDeterministicRoutingNumber(id, str, i)
+			default:
+				return str
+			}
+		})
+	}
+	return append(dst, ']')
+}
+
+// stringOf returns the string held by v, or false if v is not a string.
+// Unlike GetStringBytes and Type it doesn't unescape v in place, so a value
+// that ends up passed through is still written back exactly as it was read.
+func stringOf(v *fastjson.Value) (string, bool) {
+	if v.GetObject() != nil || v.GetArray() != nil {
+		return "", false
+	}
+	raw := v.MarshalTo(nil)
+	if len(raw) < 2 || raw[0] != '"' {
+		return "", false
+	}
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return string(raw[1 : len(raw)-1]), true
+	}
+	// Decode a copy, with the same unescaping rules as the document
+	decoded, err := fastjson.ParseBytes(raw)
+	if err != nil {
+		return string(raw[1 : len(raw)-1]), true
+	}
+	return string(decoded.GetStringBytes()), true
+}
+
+// rawKeys returns the keys of obj as written in the document, escapes
+// included, or nil if none of them contains an escape. It must be called
+// before anything looks up a member, which unescapes the keys in place.
+func rawKeys(obj *fastjson.Object) []string {
+	raw := obj.MarshalTo(nil)
+	if bytes.IndexByte(raw, '\\') < 0 {
+		return nil
+	}
+	keys := make([]string, 0, obj.Len())
+	for i := 1; i < len(raw) && raw[i] == '"'; {
+		end := skipJSONString(raw, i)
+		keys = append(keys, string(raw[i+1:end-1]))
+		// Skip the colon and the value, then the comma
+		i = skipJSONValue(raw, end+1) + 1
+	}
+	return keys
+}
+
+// skipJSONString returns the offset just past the string starting at raw[i]
+func skipJSONString(raw []byte, i int) int {
+	for i++; i < len(raw); i++ {
+		switch raw[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1
+		}
+	}
+	return i
+}
+
+// skipJSONValue returns the offset just past the compact JSON value
+// starting at raw[i]
+func skipJSONValue(raw []byte, i int) int {
+	depth := 0
+	for i < len(raw) {
+		switch raw[i] {
+		case '"':
+			i = skipJSONString(raw, i)
+			continue
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case ',':
+			if depth == 0 {
+				return i
+			}
+		}
+		i++
+	}
+	return i
+}
+
+// appendKey appends the i-th key of an object, as written if keys holds the
+// raw keys from rawKeys, otherwise escaped from its decoded form
+func appendKey(dst []byte, keys []string, i int, key string) []byte {
+	if keys == nil {
+		return appendJSONString(dst, key)
+	}
+	dst = append(dst, '"')
+	dst = append(dst, keys[i]...)
+	return append(dst, '"')
+}
+
+// appendJSONString appends s to dst as a quoted JSON string
+func appendJSONString(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			dst = append(dst, '\\', c)
+		case c == '\n':
+			dst = append(dst, '\\', 'n')
+		case c == '\r':
+			dst = append(dst, '\\', 'r')
+		case c == '\t':
+			dst = append(dst, '\\', 't')
+		case c < 0x20:
+			dst = append(dst, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		case c < utf8.RuneSelf:
+			dst = append(dst, c)
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				dst = append(dst, "\ufffd"...)
+			} else {
+				dst = append(dst, s[i:i+size]...)
+			}
+			i += size
+			continue
+		}
+		i++
+	}
+	return append(dst, '"')
+}
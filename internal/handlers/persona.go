@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"simulacrum/internal/data"
+	"simulacrum/internal/rules"
+)
+
+// personaKinds are the kinds generated from a record's persona in persona
+// mode, see Obscurer.Persona. All but gender also seed it.
+var personaKinds = []rules.Kind{
+	rules.KindName,
+	rules.KindFirstName,
+	rules.KindMiddleName,
+	rules.KindLastName,
+	rules.KindEmail,
+	rules.KindGender,
+}
+
+// isPersonaRule reports whether values matched by rule come from the persona
+func isPersonaRule(rule *rules.Rule) bool {
+	if rule == nil || rule.Strategy != rules.StrategyGenerate {
+		return false
+	}
+	for _, kind := range personaKinds {
+		if rule.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// newPersona creates the persona of a record from the first real value of
+// each of its persona kinds, with the names of locale if not nil. nil is
+// returned when nothing but gender identifies the person.
+func newPersona(locale *data.Locale, id string, values map[rules.Kind]string) *data.Persona {
+	var seed []string
+	for _, kind := range personaKinds {
+		if kind == rules.KindGender {
+			continue
+		}
+		if s := values[kind]; s != "" {
+			seed = append(seed, string(kind)+"="+s)
+		}
+	}
+	if len(seed) == 0 {
+		return nil
+	}
+	if locale != nil {
+		return locale.NewPersona(id, seed...)
+	}
+	return data.NewPersona(id, seed...)
+}
+
+// personaValue returns the persona's value for a field, or false if the rule
+// isn't a persona rule
+func personaValue(p *data.Persona, rule *rules.Rule, real string) (string, bool) {
+	if p == nil || real == "" || !isPersonaRule(rule) {
+		return "", false
+	}
+	switch rule.Kind {
+	case rules.KindName:
+		return p.Name(), true
+	case rules.KindFirstName:
+		return p.FirstName, true
+	case rules.KindMiddleName:
+		return p.MiddleName, true
+	case rules.KindLastName:
+		return p.LastName, true
+	case rules.KindEmail:
+		return p.Email(rule.ListOption("domains")), true
+	case rules.KindGender:
+		return p.Gender, true
+	}
+	return "", false
+}
@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"simulacrum/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProfileScopePrefix prefixes the token scopes and roles that grant a rule
+// profile, e.g. obscure:qa grants the qa profile
+const ProfileScopePrefix = "obscure:"
+
+// Profiles holds one Obscurer per named rule profile and lets each request
+// use only the profiles its token grants
+type Profiles struct {
+	byName      map[string]*Obscurer
+	defaultName string
+}
+
+// NewProfiles creates a set of named profiles. defaultProfile, if not empty,
+// is used when a request doesn't name a profile.
+func NewProfiles(byName map[string]*Obscurer, defaultProfile string) (*Profiles, error) {
+	if len(byName) == 0 {
+		return nil, fmt.Errorf("no profiles defined")
+	}
+	for name := range byName {
+		if name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid profile name %q", name)
+		}
+	}
+	if _, ok := byName[defaultProfile]; defaultProfile != "" && !ok {
+		return nil, fmt.Errorf("default profile %q is not defined", defaultProfile)
+	}
+	return &Profiles{byName: byName, defaultName: defaultProfile}, nil
+}
+
+// Names returns the profile names in sorted order
+func (p *Profiles) Names() []string {
+	names := make([]string, 0, len(p.byName))
+	for name := range p.byName {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Granted returns the profiles a set of scopes grants, in sorted order
+func (p *Profiles) Granted(scopes []string) []string {
+	var granted []string
+	for _, scope := range scopes {
+		name, ok := strings.CutPrefix(scope, ProfileScopePrefix)
+		if _, exists := p.byName[name]; ok && exists && !slices.Contains(granted, name) {
+			granted = append(granted, name)
+		}
+	}
+	slices.Sort(granted)
+	return granted
+}
+
+// Select picks the profile for a request: the one named by the "profile"
+// query parameter, else the default profile if granted, else the only
+// profile the token grants. The chosen profile is stored in the context, see
+// GetProfile.
+func (p *Profiles) Select(c *gin.Context) (*Obscurer, bool) {
+	granted := p.Granted(auth.GetScopes(c))
+
+	name := c.Query("profile")
+	if name == "" {
+		switch {
+		case slices.Contains(granted, p.defaultName):
+			name = p.defaultName
+		case len(granted) == 1:
+			name = granted[0]
+		case len(granted) > 1:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "profile required", "profiles": granted})
+			return nil, false
+		}
+	}
+
+	// Unknown profiles get the same answer so their names don't leak
+	if !slices.Contains(granted, name) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "profile not allowed"})
+		return nil, false
+	}
+
+	c.Set("profile", name)
+	return p.byName[name], true
+}
+
+// Handle wraps an Obscurer handler so it runs with the selected profile,
+// e.g. profiles.Handle((*Obscurer).HandleObscure)
+func (p *Profiles) Handle(h ObscurerHandler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		o, ok := p.Select(c)
+		if !ok {
+			return
+		}
+		h(o, c)
+	}
+}
+
+// GetProfile retrieves the profile selected for the request
+func GetProfile(c *gin.Context) (string, bool) {
+	name := c.GetString("profile")
+	return name, name != ""
+}
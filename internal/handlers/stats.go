@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"simulacrum/internal/rules"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Stats counts what an Obscurer did for one request: the records read and
+// the values transformed per field kind. Values themselves are never kept.
+type Stats struct {
+	Records int
+	// Fields counts transformed values by rule kind, or by strategy for
+	// rules without a kind. Values kept by the keep strategy aren't counted.
+	Fields map[string]int
+}
+
+func (s *Stats) addRecord() {
+	if s != nil {
+		s.Records++
+	}
+}
+
+// addField counts one value transformed by rule
+func (s *Stats) addField(rule *rules.Rule) {
+	if s == nil || rule.Strategy == rules.StrategyKeep {
+		return
+	}
+	label := string(rule.Kind)
+	if label == "" {
+		label = string(rule.Strategy)
+	}
+	if s.Fields == nil {
+		s.Fields = make(map[string]int)
+	}
+	s.Fields[label]++
+}
+
+// WithStats returns a copy of the Obscurer that counts its work in s. The
+// copy must not be shared between goroutines.
+func (o *Obscurer) WithStats(s *Stats) *Obscurer {
+	counted := *o
+	counted.stats = s
+	return &counted
+}
+
+// CountStats wraps an Obscurer handler so the request's Stats are stored in
+// the context, see GetStats
+func CountStats(h ObscurerHandler) ObscurerHandler {
+	return func(o *Obscurer, c *gin.Context) {
+		stats := &Stats{}
+		c.Set("obscure_stats", stats)
+		h(o.WithStats(stats), c)
+	}
+}
+
+// GetStats retrieves the Stats of the request
+func GetStats(c *gin.Context) (*Stats, bool) {
+	value, exists := c.Get("obscure_stats")
+	if !exists {
+		return nil, false
+	}
+	stats, ok := value.(*Stats)
+	return stats, ok
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaxArrayElementSize bounds the memory used for a single element of a streamed array
+const MaxArrayElementSize = 16 << 20
+
+// ErrElementTooLarge is returned when an array element exceeds MaxArrayElementSize
+var ErrElementTooLarge = errors.New("array element too large")
+
+// handleObscureArray streams a top-level JSON array back element by element.
+// Errors found before any output has been sent produce a normal error
+// response; after that the response is cut short, leaving truncated JSON.
+func (o *Obscurer) handleObscureArray(c *gin.Context, body *bufio.Reader) {
+	// Keep reading the request while the response is streamed
+	_ = http.NewResponseController(c.Writer).EnableFullDuplex()
+
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	err := o.ObscureJSONArray(body, c.Writer, c.Writer.Flush)
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		_ = c.Error(err)
+		return
+	}
+	if errors.Is(err, ErrElementTooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Array element too large"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+}
+
+// ObscureJSONStream obscures one JSON document read from r. Top-level arrays
+// are streamed with ObscureJSONArray; anything else is read whole.
+func (o *Obscurer) ObscureJSONStream(r io.Reader, w io.Writer, flush func()) error {
+	reader := bufio.NewReaderSize(r, 64<<10)
+	if b, err := peekNonSpace(reader); err == nil && b == '[' {
+		return o.ObscureJSONArray(reader, w, flush)
+	}
+
+	input, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+	result, err := o.ObscureJSON(nil, input)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(result); err != nil {
+		return err
+	}
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
+
+// ObscureJSONArray reads a top-level JSON array from r and writes its
+// obscured form to w one element at a time, so memory use is bounded by the
+// largest element rather than the whole document. Output is written in
+// chunks; flush, if not nil, is called after each one.
+func (o *Obscurer) ObscureJSONArray(r io.Reader, w io.Writer, flush func()) error {
+	reader, ok := r.(*bufio.Reader)
+	if !ok {
+		reader = bufio.NewReaderSize(r, 64<<10)
+	}
+
+	if b, err := nextNonSpace(reader); err != nil {
+		return err
+	} else if b != '[' {
+		return fmt.Errorf("expected '[', got %q", b)
+	}
+
+	parser := parserPool.Get()
+	defer parserPool.Put(parser)
+
+	out := []byte{'['}
+	var elem []byte
+	rootID := o.scopeID("")
+
+	b, err := nextNonSpace(reader)
+	if err != nil {
+		return err
+	}
+	if b != ']' {
+		_ = reader.UnreadByte()
+		for i := 0; ; i++ {
+			elem, err = readJSONValue(reader, elem, MaxArrayElementSize)
+			if err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+			v, err := parser.ParseBytes(elem)
+			if err != nil {
+				return fmt.Errorf("element %d: %w", i, err)
+			}
+
+			if i > 0 {
+				out = append(out, ',')
+			}
+			o.stats.addRecord()
+			out = o.obscureElement(out, v, i, rootID, nil, o.Locale)
+
+			if len(out) >= streamFlushSize {
+				if _, err := w.Write(out); err != nil {
+					return err
+				}
+				if flush != nil {
+					flush()
+				}
+				out = out[:0]
+			}
+
+			b, err = nextNonSpace(reader)
+			if err != nil {
+				return err
+			}
+			if b == ']' {
+				break
+			}
+			if b != ',' {
+				return fmt.Errorf("element %d: expected ',' or ']', got %q", i, b)
+			}
+		}
+	}
+	out = append(out, ']')
+
+	// Only whitespace may follow the array
+	if b, err := nextNonSpace(reader); err == nil {
+		return fmt.Errorf("unexpected %q after array", b)
+	} else if err != io.EOF {
+		return err
+	}
+
+	if _, err := w.Write(out); err != nil {
+		return err
+	}
+	if flush != nil {
+		flush()
+	}
+	return nil
+}
+
+// readJSONValue reads the bytes of one JSON value into buf, stopping before
+// the ',' or ']' that follows it. The value is only delimited here; parsing
+// it is left to the caller.
+func readJSONValue(r *bufio.Reader, buf []byte, max int) ([]byte, error) {
+	buf = buf[:0]
+	depth := 0
+	inString, escaped := false, false
+
+	for {
+		b, err := r.ReadByte()
+		if err == io.EOF {
+			return buf, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return buf, err
+		}
+
+		done := false
+		switch {
+		case inString:
+			switch {
+			case escaped:
+				escaped = false
+			case b == '\\':
+				escaped = true
+			case b == '"':
+				inString = false
+				done = depth == 0
+			}
+		case b == '"':
+			inString = true
+		case b == '{' || b == '[':
+			depth++
+		case b == '}' || b == ']':
+			if depth == 0 {
+				// End of the enclosing array after a bare scalar
+				return buf, r.UnreadByte()
+			}
+			depth--
+			done = depth == 0
+		case isSpace(b) && len(buf) == 0:
+			continue
+		case b == ',' || isSpace(b):
+			if depth == 0 {
+				return buf, r.UnreadByte()
+			}
+		}
+
+		buf = append(buf, b)
+		if len(buf) > max {
+			return buf[:0], ErrElementTooLarge
+		}
+		if done {
+			return buf, nil
+		}
+	}
+}
+
+// nextNonSpace reads and returns the next byte that isn't JSON whitespace
+func nextNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil || !isSpace(b) {
+			return b, err
+		}
+	}
+}
+
+// peekNonSpace skips JSON whitespace and returns the next byte without consuming it
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	b, err := nextNonSpace(r)
+	if err != nil {
+		return 0, err
+	}
+	return b, r.UnreadByte()
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\n' || b == '\r'
+}
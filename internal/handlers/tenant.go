@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"simulacrum/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultTenantClaim is the JWT claim naming the caller's tenant
+const DefaultTenantClaim = "tenant"
+
+// Tenants gives every tenant its own deterministic namespace: fakes are
+// consistent within a tenant but can't be linked across tenants
+type Tenants struct {
+	keys  map[string][]byte
+	claim string
+}
+
+// NewTenants creates tenant namespaces from per-tenant secrets. The tenant is
+// read from claim, DefaultTenantClaim if empty.
+func NewTenants(keys map[string][]byte, claim string) *Tenants {
+	if claim == "" {
+		claim = DefaultTenantClaim
+	}
+	return &Tenants{keys: keys, claim: claim}
+}
+
+// Resolve returns the caller's tenant and its secret. The token's tenant
+// claim wins; without one, the first name of the client certificate that has
+// a secret is used.
+func (t *Tenants) Resolve(c *gin.Context) (string, []byte, bool) {
+	if tenant, ok := auth.GetClaimString(c, t.claim); ok && tenant != "" {
+		key, ok := t.keys[tenant]
+		return tenant, key, ok
+	}
+
+	if id, ok := auth.GetClientIdentity(c); ok {
+		for _, name := range id.Names() {
+			if key, ok := t.keys[name]; ok {
+				return name, key, true
+			}
+		}
+	}
+	return "", nil, false
+}
+
+// Handle wraps an Obscurer handler so it runs in the caller's tenant
+// namespace. Callers without a known tenant get 403. The tenant is stored in
+// the context, see GetTenant.
+func (t *Tenants) Handle(h ObscurerHandler) ObscurerHandler {
+	return func(o *Obscurer, c *gin.Context) {
+		tenant, key, ok := t.Resolve(c)
+		if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"error": "unknown tenant"})
+			return
+		}
+		c.Set("tenant", tenant)
+		h(o.ForTenant(key), c)
+	}
+}
+
+// GetTenant retrieves the tenant the request was served for
+func GetTenant(c *gin.Context) (string, bool) {
+	tenant := c.GetString("tenant")
+	return tenant, tenant != ""
+}
@@ -0,0 +1,208 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// AnyIndex is an array index standing for every element, such as all rows of
+// a CSV file. It matches [*] and * but no specific index.
+const AnyIndex = -1
+
+// Segment is one step in a Path: an object key or an array index
+type Segment struct {
+	Key     string
+	Index   int
+	IsIndex bool
+}
+
+// Path is the location of a value within a document
+type Path []Segment
+
+// AppendKey returns p extended with an object key
+func (p Path) AppendKey(key string) Path {
+	return append(p, Segment{Key: key})
+}
+
+// AppendIndex returns p extended with an array index
+func (p Path) AppendIndex(i int) Path {
+	return append(p, Segment{Index: i, IsIndex: true})
+}
+
+// Key returns the last object key of the path, or "" if it ends in an index
+func (p Path) Key() string {
+	if len(p) == 0 || p[len(p)-1].IsIndex {
+		return ""
+	}
+	return p[len(p)-1].Key
+}
+
+// String renders the path in JSONPath notation, e.g. $.customers[0].name
+func (p Path) String() string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, seg := range p {
+		if seg.IsIndex && seg.Index == AnyIndex {
+			b.WriteString("[*]")
+		} else if seg.IsIndex {
+			fmt.Fprintf(&b, "[%d]", seg.Index)
+		} else {
+			b.WriteString(".")
+			b.WriteString(seg.Key)
+		}
+	}
+	return b.String()
+}
+
+type patternKind int
+
+const (
+	patKey        patternKind = iota // exact object key
+	patIndex                         // exact array index, [3]
+	patAnyIndex                      // any array index, [*]
+	patAny                           // any single key or index, *
+	patDescendant                    // zero or more segments, .. or **
+)
+
+type patternSegment struct {
+	kind  patternKind
+	key   string
+	index int
+}
+
+// PathPattern matches document paths. Both JSONPath-style patterns such as
+// $.customers[*].contact.name and dotted patterns such as
+// orders.*.shipping.street are accepted; both are anchored at the root.
+type PathPattern struct {
+	raw      string
+	segments []patternSegment
+}
+
+// ParsePathPattern compiles a path pattern.
+//
+//	$ or leading nothing  root of the document
+//	.key or key           object key
+//	[3]                   array index
+//	[*]                   any array index
+//	*                     any single key or index
+//	.. or **              any number of segments, including none
+func ParsePathPattern(pattern string) (*PathPattern, error) {
+	p := &PathPattern{raw: pattern}
+	s := strings.TrimPrefix(pattern, "$")
+	if s == "" {
+		return nil, fmt.Errorf("empty path pattern %q", pattern)
+	}
+
+	// A dotted pattern without "$" starts directly with a key
+	if s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	for len(s) > 0 {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			p.segments = append(p.segments, patternSegment{kind: patDescendant})
+			s = s[2:]
+			// "$..name" continues with a bare key
+			if len(s) > 0 && s[0] != '[' && s[0] != '.' {
+				s = "." + s
+			}
+		case s[0] == '.':
+			s = s[1:]
+			end := strings.IndexAny(s, ".[]")
+			if end < 0 {
+				end = len(s)
+			}
+			key := s[:end]
+			s = s[end:]
+			switch key {
+			case "":
+				return nil, fmt.Errorf("empty key in path pattern %q", pattern)
+			case "*":
+				p.segments = append(p.segments, patternSegment{kind: patAny})
+			case "**":
+				p.segments = append(p.segments, patternSegment{kind: patDescendant})
+			default:
+				p.segments = append(p.segments, patternSegment{kind: patKey, key: key})
+			}
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated bracket in path pattern %q", pattern)
+			}
+			inner := s[1:end]
+			s = s[end+1:]
+			switch {
+			case inner == "*":
+				p.segments = append(p.segments, patternSegment{kind: patAnyIndex})
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				p.segments = append(p.segments, patternSegment{kind: patKey, key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid index %q in path pattern %q", inner, pattern)
+				}
+				p.segments = append(p.segments, patternSegment{kind: patIndex, index: index})
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %q in path pattern %q", s[:1], pattern)
+		}
+	}
+
+	if p.segments[len(p.segments)-1].kind == patDescendant {
+		return nil, fmt.Errorf("path pattern %q must not end with a descendant wildcard", pattern)
+	}
+
+	return p, nil
+}
+
+// String returns the pattern as written
+func (p *PathPattern) String() string {
+	return p.raw
+}
+
+// Match reports whether the whole path matches the pattern
+func (p *PathPattern) Match(path Path) bool {
+	return matchSegments(p.segments, path)
+}
+
+func matchSegments(pattern []patternSegment, path Path) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+
+	seg := pattern[0]
+	if seg.kind == patDescendant {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(path) == 0 {
+		return false
+	}
+
+	step := path[0]
+	switch seg.kind {
+	case patKey:
+		if step.IsIndex || step.Key != seg.key {
+			return false
+		}
+	case patIndex:
+		if !step.IsIndex || step.Index != seg.index {
+			return false
+		}
+	case patAnyIndex:
+		if !step.IsIndex {
+			return false
+		}
+	case patAny:
+		// Any key or index matches
+	}
+
+	return matchSegments(pattern[1:], path[1:])
+}
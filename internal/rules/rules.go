@@ -0,0 +1,401 @@
+package rules
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"simulacrum/internal/config"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Kind names the generator used to produce a fake value
+type Kind string
+
+const (
+	KindName          Kind = "name"
+	KindFirstName     Kind = "first_name"
+	KindLastName      Kind = "last_name"
+	KindMiddleName    Kind = "middle_name"
+	KindEmail         Kind = "email"
+	KindPhone         Kind = "phone"
+	KindAddress       Kind = "address"
+	KindStreet        Kind = "street"
+	KindCity          Kind = "city"
+	KindState         Kind = "state"
+	KindZipCode       Kind = "zip_code"
+	KindCounty        Kind = "county"
+	KindCountry       Kind = "country"
+	KindTaxID         Kind = "tax_id"
+	KindSSN           Kind = "ssn"
+	KindDateOfBirth   Kind = "date_of_birth"
+	KindGender        Kind = "gender"
+	KindInteger       Kind = "integer"
+	KindFloat         Kind = "float"
+	KindPassport      Kind = "passport"
+	KindDriverLicense Kind = "driver_license"
+	KindBankAccounts  Kind = "bank_accounts"
+	KindWord          Kind = "word"
+)
+
+// Strategy decides what happens to a matched value
+type Strategy string
+
+const (
+	// StrategyGenerate replaces the value with a realistic deterministic fake
+	StrategyGenerate Strategy = "generate"
+	// StrategyMask hides all but the first/last characters, e.g. ***-**-1234
+	StrategyMask Strategy = "mask"
+	// StrategyRedact replaces the value with a constant, e.g. [REDACTED]
+	StrategyRedact Strategy = "redact"
+	// StrategyNullify replaces the value with null
+	StrategyNullify Strategy = "nullify"
+	// StrategyRemove drops the key from its object
+	StrategyRemove Strategy = "remove"
+	// StrategyHash replaces the value with an opaque keyed-hash token
+	StrategyHash Strategy = "hash"
+	// StrategyKeep passes the value through unchanged
+	StrategyKeep Strategy = "keep"
+)
+
+// Values of the "format" option of phone, ssn, tax_id, passport,
+// driver_license and bank_accounts rules
+const (
+	// FormatFixed generates values in one fixed shape, e.g. 555-123-4567
+	FormatFixed = "fixed"
+	// FormatPreserve keeps the real value's separators, length, digit and
+	// letter positions and country code, e.g. (212) 555-0199 stays (ddd) ddd-dddd
+	FormatPreserve = "preserve"
+)
+
+// strategyOptions lists the options each strategy accepts in addition to the kind's
+var strategyOptions = map[Strategy][]string{
+	StrategyGenerate: nil,
+	StrategyMask:     {"keep_first", "keep_last", "mask_char"},
+	StrategyRedact:   {"text"},
+	StrategyNullify:  nil,
+	StrategyRemove:   nil,
+	StrategyHash:     nil,
+	StrategyKeep:     nil,
+}
+
+// kindOptions lists the options each kind accepts
+var kindOptions = map[Kind][]string{
+	KindName:          nil,
+	KindFirstName:     nil,
+	KindLastName:      nil,
+	KindMiddleName:    nil,
+	KindEmail:         {"domains", "unique"},
+	KindPhone:         {"format", "unique"},
+	KindAddress:       nil,
+	KindStreet:        nil,
+	KindCity:          nil,
+	KindState:         nil,
+	KindZipCode:       nil,
+	KindCounty:        nil,
+	KindCountry:       nil,
+	KindTaxID:         {"format", "unique"},
+	KindSSN:           {"format", "unique"},
+	KindDateOfBirth:   nil,
+	KindGender:        nil,
+	KindInteger:       {"unique"},
+	KindFloat:         nil,
+	KindPassport:      {"format", "unique"},
+	KindDriverLicense: {"format", "unique"},
+	KindBankAccounts:  {"format", "unique"},
+	KindWord:          {"dictionary"},
+}
+
+// uniqueKinds returns the sorted kinds accepting the unique option
+func uniqueKinds() []string {
+	var kinds []string
+	for kind, opts := range kindOptions {
+		if slices.Contains(opts, "unique") {
+			kinds = append(kinds, string(kind))
+		}
+	}
+	slices.Sort(kinds)
+	return kinds
+}
+
+//go:embed default_rules.yaml
+var defaultRules []byte
+
+// Rule maps field names, glob patterns and document paths to a generator kind
+// and the strategy applied to matched values
+type Rule struct {
+	// Kind is the generator used by the generate strategy; other strategies
+	// may leave it empty
+	Kind Kind `yaml:"kind"`
+	// Strategy defaults to generate
+	Strategy Strategy `yaml:"strategy"`
+	// Fields are exact key names, including any aliases
+	Fields []string `yaml:"fields"`
+	// Patterns are glob patterns (see path.Match) matched against key names
+	Patterns []string `yaml:"patterns"`
+	// Paths are document paths (see ParsePathPattern), e.g. $.customers[*].name
+	Paths []string `yaml:"paths"`
+	// Options tune the generator or strategy, e.g. "domains" for email or
+	// "keep_last" for mask
+	Options map[string]string `yaml:"options"`
+
+	paths []*PathPattern
+}
+
+// RuleSet is an ordered list of rules with a lookup index
+type RuleSet struct {
+	Rules []*Rule `yaml:"rules"`
+	// Exclude lists document paths that are never obscured, e.g. $.products[*].name
+	Exclude []string `yaml:"exclude"`
+
+	byField  map[string]*Rule
+	excludes []*PathPattern
+}
+
+// Default returns the built-in rule set
+func Default() *RuleSet {
+	rs, err := Parse(defaultRules)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in rules: %v", err))
+	}
+	return rs
+}
+
+// LoadFromFile reads a YAML rules file
+func LoadFromFile(filepath string) (*RuleSet, error) {
+	data, err := os.ReadFile(filepath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read rules file: %w", err)
+	}
+
+	rs, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules file %s: %w", filepath, err)
+	}
+	return rs, nil
+}
+
+// Parse decodes and validates a YAML rule set
+func Parse(data []byte) (*RuleSet, error) {
+	var rs RuleSet
+	if err := yaml.Unmarshal(data, &rs); err != nil {
+		return nil, fmt.Errorf("failed to parse rules: %w", err)
+	}
+	if err := rs.compile(); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// compile validates the rules and builds the field index
+func (rs *RuleSet) compile() error {
+	if len(rs.Rules) == 0 {
+		return fmt.Errorf("no rules defined")
+	}
+
+	rs.byField = make(map[string]*Rule)
+	for i, rule := range rs.Rules {
+		if rule == nil {
+			return fmt.Errorf("rule %d: empty rule", i)
+		}
+
+		if rule.Strategy == "" {
+			rule.Strategy = StrategyGenerate
+		}
+		allowed, ok := strategyOptions[rule.Strategy]
+		if !ok {
+			return fmt.Errorf("rule %d: unknown strategy %q", i, rule.Strategy)
+		}
+
+		if rule.Kind != "" || rule.Strategy == StrategyGenerate {
+			kindOpts, ok := kindOptions[rule.Kind]
+			if !ok {
+				return fmt.Errorf("rule %d: unknown kind %q", i, rule.Kind)
+			}
+			if rule.Strategy == StrategyGenerate {
+				allowed = kindOpts
+			}
+		}
+		if len(rule.Fields) == 0 && len(rule.Patterns) == 0 && len(rule.Paths) == 0 {
+			return fmt.Errorf("rule %d (%s): no fields, patterns or paths", i, rule.Kind)
+		}
+
+		if _, ok := rule.Options["unique"]; ok && rule.Strategy == StrategyGenerate && !slices.Contains(allowed, "unique") {
+			return fmt.Errorf("rule %d (%s): option unique is only supported for %s; other kinds pick fakes from a limited list, "+
+				"which can't keep distinct values distinct, use strategy hash instead", i, rule.Kind, strings.Join(uniqueKinds(), ", "))
+		}
+		for name := range rule.Options {
+			if !slices.Contains(allowed, name) {
+				return fmt.Errorf("rule %d (%s): unknown option %q for strategy %s", i, rule.Kind, name, rule.Strategy)
+			}
+		}
+		if err := rule.validateOptions(); err != nil {
+			return fmt.Errorf("rule %d (%s): %w", i, rule.Kind, err)
+		}
+
+		for _, field := range rule.Fields {
+			if field == "" {
+				return fmt.Errorf("rule %d (%s): empty field name", i, rule.Kind)
+			}
+			if prev, ok := rs.byField[field]; ok {
+				return fmt.Errorf("rule %d (%s): field %q already mapped to %s", i, rule.Kind, field, prev.Kind)
+			}
+			rs.byField[field] = rule
+		}
+
+		for _, pattern := range rule.Patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("rule %d (%s): invalid pattern %q: %w", i, rule.Kind, pattern, err)
+			}
+		}
+
+		rule.paths = nil
+		for _, raw := range rule.Paths {
+			pattern, err := ParsePathPattern(raw)
+			if err != nil {
+				return fmt.Errorf("rule %d (%s): %w", i, rule.Kind, err)
+			}
+			rule.paths = append(rule.paths, pattern)
+		}
+	}
+
+	rs.excludes = nil
+	for _, raw := range rs.Exclude {
+		pattern, err := ParsePathPattern(raw)
+		if err != nil {
+			return fmt.Errorf("exclude: %w", err)
+		}
+		rs.excludes = append(rs.excludes, pattern)
+	}
+
+	return nil
+}
+
+// Excluded reports whether the value at path must be left untouched, including
+// anything nested below it
+func (rs *RuleSet) Excluded(p Path) bool {
+	for _, pattern := range rs.excludes {
+		if pattern.Match(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match returns the rule for the value at path, or nil if it should not be
+// obscured. Path rules are tried first, then exact field names, then key
+// patterns; within each group rules are tried in order. Exclusions are not
+// considered, see Excluded.
+func (rs *RuleSet) Match(p Path) *Rule {
+	for _, rule := range rs.Rules {
+		for _, pattern := range rule.paths {
+			if pattern.Match(p) {
+				return rule
+			}
+		}
+	}
+
+	if len(p) == 0 || p[len(p)-1].IsIndex {
+		return nil
+	}
+	return rs.MatchKey(p.Key())
+}
+
+// MatchKey returns the rule for a bare key name, ignoring path rules
+func (rs *RuleSet) MatchKey(key string) *Rule {
+	if rule, ok := rs.byField[key]; ok {
+		return rule
+	}
+
+	for _, rule := range rs.Rules {
+		for _, pattern := range rule.Patterns {
+			if ok, _ := path.Match(pattern, key); ok {
+				return rule
+			}
+		}
+	}
+
+	return nil
+}
+
+// Option returns a rule option, or def if it is not set
+func (r *Rule) Option(name, def string) string {
+	if v, ok := r.Options[name]; ok {
+		return v
+	}
+	return def
+}
+
+// IntOption returns a non-negative integer rule option, or def if it is not set or invalid
+func (r *Rule) IntOption(name string, def int) int {
+	v, ok := r.Options[name]
+	if !ok {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return def
+	}
+	return n
+}
+
+// validateOptions checks option values that must be well-formed
+func (r *Rule) validateOptions() error {
+	for _, name := range []string{"keep_first", "keep_last"} {
+		if v, ok := r.Options[name]; ok {
+			if n, err := strconv.Atoi(v); err != nil || n < 0 {
+				return fmt.Errorf("option %s must be a non-negative integer, got %q", name, v)
+			}
+		}
+	}
+	if v, ok := r.Options["mask_char"]; ok && utf8.RuneCountInString(v) != 1 {
+		return fmt.Errorf("option mask_char must be a single character, got %q", v)
+	}
+	if v, ok := r.Options["format"]; ok && v != FormatFixed && v != FormatPreserve {
+		return fmt.Errorf("option format must be %s or %s, got %q", FormatFixed, FormatPreserve, v)
+	}
+	if v, ok := r.Options["unique"]; ok {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("option unique must be true or false, got %q", v)
+		}
+	}
+	// Unique values are encrypted in their own shape, so options choosing
+	// another shape can't apply
+	if r.Unique() {
+		if _, ok := r.Options["domains"]; ok {
+			return fmt.Errorf("option domains can't be combined with unique")
+		}
+		if r.Option("format", FormatPreserve) == FormatFixed {
+			return fmt.Errorf("option format %s can't be combined with unique", FormatFixed)
+		}
+	}
+	if r.Kind == KindWord && r.Strategy == StrategyGenerate && r.Option("dictionary", "") == "" {
+		return fmt.Errorf("option dictionary is required")
+	}
+	return nil
+}
+
+// PreservesFormat reports whether generated values keep the shape of the
+// real ones, see FormatPreserve
+func (r *Rule) PreservesFormat() bool {
+	return r.Option("format", FormatFixed) == FormatPreserve
+}
+
+// Unique reports whether distinct real values must get distinct fakes. Such
+// values are encrypted in their own shape and seeded by their kind rather
+// than their record, so equal values get equal fakes across records.
+func (r *Rule) Unique() bool {
+	unique, _ := strconv.ParseBool(r.Option("unique", "false"))
+	return unique
+}
+
+// ListOption returns a comma-separated rule option as a list
+func (r *Rule) ListOption(name string) []string {
+	return config.SplitList(r.Options[name])
+}
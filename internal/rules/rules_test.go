@@ -0,0 +1,360 @@
+package rules
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDefaultRules(t *testing.T) {
+	rs := Default()
+
+	tests := map[string]Kind{
+		"name":          KindName,
+		"email":         KindEmail,
+		"phone_number":  KindPhone,
+		"ssn":           KindSSN,
+		"bank_accounts": KindBankAccounts,
+		"integer_value": KindInteger,
+	}
+	for key, kind := range tests {
+		rule := rs.MatchKey(key)
+		if rule == nil {
+			t.Errorf("Expected %s to match a rule", key)
+			continue
+		}
+		if rule.Kind != kind {
+			t.Errorf("Expected %s to map to %s, got %s", key, kind, rule.Kind)
+		}
+	}
+
+	if rule := rs.MatchKey("custom_field"); rule != nil {
+		t.Errorf("Expected custom_field not to match, got %s", rule.Kind)
+	}
+}
+
+func TestParseAliasesAndPatterns(t *testing.T) {
+	rs, err := Parse([]byte(`
+rules:
+  - kind: first_name
+    fields: [first_name, firstName]
+  - kind: email
+    fields: [e_mail]
+    patterns: ["*_email"]
+    options:
+      domains: "corp.test, example.org"
+  - kind: phone
+    patterns: ["*phone*", "mobile"]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	tests := map[string]Kind{
+		"firstName":    KindFirstName,
+		"e_mail":       KindEmail,
+		"work_email":   KindEmail,
+		"mobile":       KindPhone,
+		"home_phone_2": KindPhone,
+	}
+	for key, kind := range tests {
+		rule := rs.MatchKey(key)
+		if rule == nil || rule.Kind != kind {
+			t.Errorf("Expected %s to map to %s, got %v", key, kind, rule)
+		}
+	}
+
+	// The built-in table is fully replaced
+	if rule := rs.MatchKey("name"); rule != nil {
+		t.Errorf("Expected name not to match a custom rule set, got %s", rule.Kind)
+	}
+
+	domains := rs.MatchKey("e_mail").ListOption("domains")
+	if len(domains) != 2 || domains[0] != "corp.test" || domains[1] != "example.org" {
+		t.Errorf("Expected domains [corp.test example.org], got %v", domains)
+	}
+}
+
+func TestParseExactFieldBeatsPattern(t *testing.T) {
+	rs, err := Parse([]byte(`
+rules:
+  - kind: phone
+    patterns: ["*_number"]
+  - kind: ssn
+    fields: [ssn_number]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	if rule := rs.MatchKey("ssn_number"); rule == nil || rule.Kind != KindSSN {
+		t.Errorf("Expected exact field to take precedence over pattern, got %v", rule)
+	}
+}
+
+func TestParseInvalidRules(t *testing.T) {
+	tests := map[string]string{
+		"empty":           ``,
+		"unknown kind":    "rules:\n  - kind: shoe_size\n    fields: [shoe]\n",
+		"no fields":       "rules:\n  - kind: name\n",
+		"duplicate field": "rules:\n  - kind: name\n    fields: [name]\n  - kind: email\n    fields: [name]\n",
+		"bad pattern":     "rules:\n  - kind: name\n    patterns: [\"[\"]\n",
+		"unknown option":  "rules:\n  - kind: ssn\n    fields: [ssn]\n    options:\n      domains: x\n",
+		"invalid yaml":    "rules: [",
+	}
+	for name, input := range tests {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadFromFile(t *testing.T) {
+	tmpFile := t.TempDir() + "/rules.yaml"
+	os.WriteFile(tmpFile, []byte("rules:\n  - kind: date_of_birth\n    fields: [dob]\n"), 0600)
+
+	rs, err := LoadFromFile(tmpFile)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	if rule := rs.MatchKey("dob"); rule == nil || rule.Kind != KindDateOfBirth {
+		t.Errorf("Expected dob to map to date_of_birth, got %v", rule)
+	}
+
+	_, err = LoadFromFile(t.TempDir() + "/missing.yaml")
+	if err == nil || !strings.Contains(err.Error(), "failed to read rules file") {
+		t.Errorf("Expected read error for missing file, got %v", err)
+	}
+}
+
+// buildPath is a test helper turning keys and ints into a Path
+func buildPath(steps ...any) Path {
+	var p Path
+	for _, step := range steps {
+		switch v := step.(type) {
+		case string:
+			p = p.AppendKey(v)
+		case int:
+			p = p.AppendIndex(v)
+		}
+	}
+	return p
+}
+
+func TestPathPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    Path
+		want    bool
+	}{
+		{"$.customers[*].contact.name", buildPath("customers", 0, "contact", "name"), true},
+		{"$.customers[*].contact.name", buildPath("customers", "x", "contact", "name"), false},
+		{"$.customers[*].contact.name", buildPath("customers", 0, "contact", "name", "first"), false},
+		{"$.customers[1].name", buildPath("customers", 1, "name"), true},
+		{"$.customers[1].name", buildPath("customers", 2, "name"), false},
+		{"orders.*.shipping.street", buildPath("orders", 3, "shipping", "street"), true},
+		{"orders.*.shipping.street", buildPath("orders", "a", "shipping", "street"), true},
+		{"orders.*.shipping.street", buildPath("data", "orders", 3, "shipping", "street"), false},
+		{"$..name", buildPath("name"), true},
+		{"$..name", buildPath("a", 0, "b", "name"), true},
+		{"$..name", buildPath("a", "name", "b"), false},
+		{"**.contact.email", buildPath("x", 1, "contact", "email"), true},
+		{"$['odd.key'].name", buildPath("odd.key", "name"), true},
+		{"$[*].name", buildPath(0, "name"), true},
+	}
+
+	for _, tt := range tests {
+		pattern, err := ParsePathPattern(tt.pattern)
+		if err != nil {
+			t.Errorf("Failed to parse %s: %v", tt.pattern, err)
+			continue
+		}
+		if got := pattern.Match(tt.path); got != tt.want {
+			t.Errorf("%s against %s: expected %v, got %v", tt.pattern, tt.path, tt.want, got)
+		}
+	}
+}
+
+func TestParsePathPatternInvalid(t *testing.T) {
+	for _, pattern := range []string{"", "$", "$.a[", "$.a[x]", "$.a[-1]", "$.a..", "$.a.", "$.a]"} {
+		if _, err := ParsePathPattern(pattern); err == nil {
+			t.Errorf("Expected error for pattern %q", pattern)
+		}
+	}
+}
+
+func TestPathString(t *testing.T) {
+	if s := buildPath("customers", 2, "name").String(); s != "$.customers[2].name" {
+		t.Errorf("Expected $.customers[2].name, got %s", s)
+	}
+	if s := buildPath(AnyIndex, "email").String(); s != "$[*].email" {
+		t.Errorf("Expected $[*].email, got %s", s)
+	}
+}
+
+func TestPathPatternAnyIndex(t *testing.T) {
+	p := buildPath(AnyIndex, "email")
+	for pattern, want := range map[string]bool{
+		"$[*].email":   true,
+		"$.*.email":    true,
+		"$..email":     true,
+		"$[0].email":   false,
+		"$.rows.email": false,
+	} {
+		pp, err := ParsePathPattern(pattern)
+		if err != nil {
+			t.Fatalf("ParsePathPattern(%q) failed: %v", pattern, err)
+		}
+		if got := pp.Match(p); got != want {
+			t.Errorf("%s matching %s: expected %v, got %v", pattern, p, want, got)
+		}
+	}
+}
+
+func TestRuleSetPathsAndExclusions(t *testing.T) {
+	rs, err := Parse([]byte(`
+exclude:
+  - $.products[*].name
+rules:
+  - kind: name
+    fields: [name]
+  - kind: street
+    paths: [orders.*.shipping.street]
+  - kind: city
+    paths: ["$.customers[*].contact.name"]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	if !rs.Excluded(buildPath("products", 0, "name")) {
+		t.Error("Expected product name to be excluded")
+	}
+	if rs.Excluded(buildPath("customers", 0, "name")) {
+		t.Error("Expected customer name not to be excluded")
+	}
+
+	if rule := rs.Match(buildPath("orders", 0, "shipping", "street")); rule == nil || rule.Kind != KindStreet {
+		t.Errorf("Expected path rule to match street, got %v", rule)
+	}
+	if rule := rs.Match(buildPath("street")); rule != nil {
+		t.Errorf("Expected path-only rule not to match bare key, got %s", rule.Kind)
+	}
+
+	// Path rules take precedence over field names
+	if rule := rs.Match(buildPath("customers", 0, "contact", "name")); rule == nil || rule.Kind != KindCity {
+		t.Errorf("Expected path rule to beat field rule, got %v", rule)
+	}
+	if rule := rs.Match(buildPath("customers", 0, "name")); rule == nil || rule.Kind != KindName {
+		t.Errorf("Expected field rule to match, got %v", rule)
+	}
+
+	// Array elements only match path rules
+	if rule := rs.Match(buildPath("name", 0)); rule != nil {
+		t.Errorf("Expected array element not to match a field rule, got %s", rule.Kind)
+	}
+}
+
+func TestParseInvalidPaths(t *testing.T) {
+	inputs := []string{
+		"rules:\n  - kind: name\n    paths: [\"$.a[\"]\n",
+		"exclude: [\"$\"]\nrules:\n  - kind: name\n    fields: [name]\n",
+	}
+	for _, input := range inputs {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestParseStrategies(t *testing.T) {
+	rs, err := Parse([]byte(`
+rules:
+  - kind: ssn
+    fields: [ssn]
+    strategy: mask
+    options:
+      keep_last: "4"
+  - fields: [notes]
+    strategy: redact
+    options:
+      text: "<hidden>"
+  - fields: [id]
+    strategy: keep
+  - kind: email
+    fields: [email]
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+
+	if rule := rs.MatchKey("ssn"); rule.Strategy != StrategyMask || rule.IntOption("keep_last", 0) != 4 {
+		t.Errorf("Expected ssn to be masked keeping 4, got %s", rule.Strategy)
+	}
+	if rule := rs.MatchKey("notes"); rule.Strategy != StrategyRedact || rule.Option("text", "") != "<hidden>" {
+		t.Errorf("Expected notes to be redacted with custom text, got %s", rule.Strategy)
+	}
+	if rule := rs.MatchKey("id"); rule.Strategy != StrategyKeep {
+		t.Errorf("Expected id to be kept, got %s", rule.Strategy)
+	}
+	if rule := rs.MatchKey("email"); rule.Strategy != StrategyGenerate {
+		t.Errorf("Expected generate by default, got %s", rule.Strategy)
+	}
+}
+
+func TestParseInvalidStrategies(t *testing.T) {
+	tests := map[string]string{
+		"unknown strategy":   "rules:\n  - kind: ssn\n    fields: [ssn]\n    strategy: shred\n",
+		"generate no kind":   "rules:\n  - fields: [ssn]\n",
+		"bad keep_last":      "rules:\n  - fields: [ssn]\n    strategy: mask\n    options:\n      keep_last: x\n",
+		"bad mask_char":      "rules:\n  - fields: [ssn]\n    strategy: mask\n    options:\n      mask_char: ab\n",
+		"option of strategy": "rules:\n  - fields: [ssn]\n    strategy: redact\n    options:\n      keep_last: \"4\"\n",
+		"kind option":        "rules:\n  - kind: email\n    fields: [email]\n    strategy: hash\n    options:\n      domains: x\n",
+		"bad format":         "rules:\n  - kind: phone\n    fields: [phone]\n    options:\n      format: keep\n",
+		"format of kind":     "rules:\n  - kind: name\n    fields: [name]\n    options:\n      format: preserve\n",
+		"word no dictionary": "rules:\n  - kind: word\n    fields: [company]\n",
+		"bad unique":         "rules:\n  - kind: ssn\n    fields: [ssn]\n    options:\n      unique: always\n",
+		"unique of kind":     "rules:\n  - kind: gender\n    fields: [gender]\n    options:\n      unique: true\n",
+		"unique name":        "rules:\n  - kind: name\n    fields: [name]\n    options:\n      unique: true\n",
+		"unique domains":     "rules:\n  - kind: email\n    fields: [email]\n    options:\n      unique: true\n      domains: x\n",
+		"unique fixed":       "rules:\n  - kind: ssn\n    fields: [ssn]\n    options:\n      unique: true\n      format: fixed\n",
+	}
+	for name, input := range tests {
+		if _, err := Parse([]byte(input)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestParseUniqueOfListKind(t *testing.T) {
+	_, err := Parse([]byte("rules:\n  - kind: city\n    fields: [city]\n    options:\n      unique: true\n"))
+	if err == nil || !strings.Contains(err.Error(), "only supported for") || !strings.Contains(err.Error(), "email") {
+		t.Errorf("Expected the error to list the kinds supporting unique, got %v", err)
+	}
+}
+
+func TestRulePreservesFormat(t *testing.T) {
+	rs, err := Parse([]byte("rules:\n  - kind: phone\n    fields: [phone]\n    options:\n      format: preserve\n  - kind: ssn\n    fields: [ssn]\n    options:\n      format: fixed\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if !rs.MatchKey("phone").PreservesFormat() {
+		t.Error("Expected phone to preserve its format")
+	}
+	if rs.MatchKey("ssn").PreservesFormat() {
+		t.Error("Expected ssn to use the fixed format")
+	}
+}
+
+func TestRuleUnique(t *testing.T) {
+	rs, err := Parse([]byte("rules:\n  - kind: email\n    fields: [email]\n    options:\n      unique: true\n  - kind: ssn\n    fields: [ssn]\n"))
+	if err != nil {
+		t.Fatalf("Failed to parse rules: %v", err)
+	}
+	if !rs.MatchKey("email").Unique() {
+		t.Error("Expected email to be unique")
+	}
+	if rs.MatchKey("ssn").Unique() {
+		t.Error("Expected ssn not to be unique by default")
+	}
+}
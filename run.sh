@@ -4,7 +4,7 @@ set -e
 
 echo "Building simulacrum..."
 go build \
-  -o simulacrum \
+  -o server \
   ./cmd/server
 
 echo "Starting server with environment variables..."
@@ -12,4 +12,4 @@ export SERVER_PORT=8080
 export SERVER_ENVIRONMENT=development
 export GIN_MODE=debug
 export AUTH_PUBLIC_KEYS_FILE=public_keys.pem
-./simulacrum
+./server
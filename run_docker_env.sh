@@ -1,5 +1,12 @@
 #!/bin/bash
 
+# The hashing secret is required in production; create one on first run
+SECRET_KEY_FILE="secret.key"
+if [ ! -f "$SECRET_KEY_FILE" ]; then
+  echo "Creating hashing secret $SECRET_KEY_FILE..."
+  (umask 077 && openssl rand -hex 32 > "$SECRET_KEY_FILE")
+fi
+
 # Build the image
 echo "Building Docker image..."
 docker build \
@@ -12,6 +19,8 @@ echo "Running Simulacrum with environment variables..."
 docker run --rm \
   --publish 8080:8080 \
   --volume "$(pwd)/public_keys.pem:/app/public_keys.pem" \
+  --volume "$(pwd)/$SECRET_KEY_FILE:/app/secret.key:ro" \
+  --env OBSCURE_SECRET_KEY_FILE=/app/secret.key \
   --env SERVER_ENVIRONMENT=production \
   --env GIN_MODE=release \
   --env TLS_ENABLED=false \
@@ -1,11 +1,19 @@
 #!/bin/bash
 
-# Define certificate/key files
+# Define certificate/key files, e.g. created with:
+#   simulacrum keys && simulacrum certs
 PUBLIC_KEYS_FILE="public_keys.pem"
 CERT_FILE="server.crt"
 KEY_FILE="server.key"
 CA_CERT_FILE="ca.crt"
 
+# The hashing secret is required in production; create one on first run
+SECRET_KEY_FILE="secret.key"
+if [ ! -f "$SECRET_KEY_FILE" ]; then
+  echo "Creating hashing secret $SECRET_KEY_FILE..."
+  (umask 077 && openssl rand -hex 32 > "$SECRET_KEY_FILE")
+fi
+
 # Build the image
 echo "Building Docker image..."
 docker build \
@@ -21,7 +29,9 @@ docker build \
 echo "Running Simulacrum with environment variables (Production + TLS)..."
 docker run --rm \
   --publish 8080:8080 \
+  --volume "$(pwd)/$SECRET_KEY_FILE:/app/secret.key:ro" \
   --env SERVER_PORT=8080 \
+  --env OBSCURE_SECRET_KEY_FILE=/app/secret.key \
   --env SERVER_ENVIRONMENT=production \
   --env GIN_MODE=release \
   --env TLS_ENABLED=true \